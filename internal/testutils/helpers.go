@@ -18,14 +18,14 @@ import (
 	u "github.com/aisbergg/gonja/pkg/gonja/utils"
 )
 
-func TestEnv(root string) *gonja.Environment {
-	env := gonja.NewEnvironment(
+func TestEnv(root string, opts ...gonja.Option) *gonja.Environment {
+	env := gonja.NewEnvironment(append([]gonja.Option{
 		gonja.OptLoader(loaders.MustNewFileSystemLoader(root)),
 
 		gonja.OptKeepTrailingNewline(),
 		gonja.OptAutoescape(),
 		gonja.OptSetGlobal("lorem", u.LoremIpsum), // Predictable random content
-	)
+	}, opts...)...)
 	return env
 }
 
@@ -47,6 +47,7 @@ var Filters = exec.FilterSet{
 	"indent":         filterIndent,
 	"int":            filterInteger,
 	"integer":        filterInteger,
+	"items":          filterItems,
 	"join":           filterJoin,
 	"last":           filterLast,
 	"length":         filterLength,
@@ -63,6 +64,10 @@ var Filters = exec.FilterSet{
 	"reverse":        filterReverse,
 	"round":          filterRound,
 	"safe":           filterSafe,
+	"safe_attr":      filterSafeAttr,
+	"safe_css":       filterSafeCSS,
+	"safe_js":        filterSafeJS,
+	"safe_url":       filterSafeURL,
 	"select":         filterSelect,
 	"selectattr":     filterSelectAttr,
 	"slice":          filterSlice,
@@ -271,7 +276,8 @@ func sortByValue(in exec.Value, caseSensitive, reverse bool) [][2]exec.Value {
 			return strings.ToLower(items[i].Value.String()) < strings.ToLower(items[j].Value.String())
 		}
 	}
-	sort.Slice(items, sorter)
+	// items with equal values keep their order
+	sort.SliceStable(items, sorter)
 	for _, item := range items {
 		out = append(out, [2]exec.Value{item.Key, item.Value})
 	}
@@ -380,8 +386,20 @@ func filterFirst(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Va
 		errors.ThrowFilterArgumentError("first()", p.Error())
 	}
 
-	if in.IsSliceable() && in.Len() > 0 {
-		return in.Index(0)
+	if in.IsSliceable() {
+		if in.Len() > 0 {
+			return in.Index(0)
+		}
+	} else if in.IsIterable() {
+		// lazy sequences are only consumed up to the first item
+		var first exec.Value
+		in.Iterate(func(idx, count int, key, value exec.Value) bool {
+			first = key
+			return false
+		}, func() {})
+		if first != nil {
+			return first
+		}
 	}
 	return e.ValueFactory.Value("")
 }
@@ -519,6 +537,30 @@ func filterInteger(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.
 	return e.ValueFactory.Value(in.Integer())
 }
 
+func filterItems(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
+	if debug.Enabled {
+		fm := debug.FuncMarker()
+		defer fm.End()
+	}
+	debug.Print("call filter with raw args: items(%s)", params.String())
+	if p := params.ExpectNothing(); p.IsError() {
+		errors.ThrowFilterArgumentError("items()", p.Error())
+	}
+
+	out := [][2]exec.Value{}
+	if !exec.IsDefined(in) {
+		return e.ValueFactory.Value(out)
+	}
+	if !in.IsDict() {
+		errors.ThrowFilterArgumentError("items()", "expected a dict, got '%s'", in.String())
+	}
+	in.Iterate(func(idx, count int, key, value exec.Value) bool {
+		out = append(out, [2]exec.Value{key, value})
+		return true
+	}, func() {})
+	return e.ValueFactory.Value(out)
+}
+
 func filterJoin(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
 	if debug.Enabled {
 		fm := debug.FuncMarker()
@@ -534,15 +576,15 @@ func filterJoin(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Val
 	}
 	debug.Print("call filter with evaluated args: join(%s)", p.String())
 
-	if !in.IsSliceable() {
+	if !in.IsSliceable() && (!in.IsIterable() || in.IsDict()) {
 		return in
 	}
 	sep := p.GetKwarg("d").String()
-	l := in.Len()
-	sl := make([]string, 0, l)
-	for i := 0; i < l; i++ {
-		sl = append(sl, in.Index(i).String())
-	}
+	sl := []string{}
+	in.Iterate(func(idx, count int, key, value exec.Value) bool {
+		sl = append(sl, key.String())
+		return true
+	}, func() {})
 	return e.ValueFactory.Value(strings.Join(sl, sep))
 }
 
@@ -558,6 +600,15 @@ func filterLast(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Val
 
 	if in.IsSliceable() {
 		return in.Index(-1)
+	} else if in.IsIterable() {
+		var last exec.Value
+		in.Iterate(func(idx, count int, key, value exec.Value) bool {
+			last = key
+			return true
+		}, func() {})
+		if last != nil {
+			return last
+		}
 	}
 	return e.ValueFactory.Value("")
 }
@@ -1001,6 +1052,54 @@ func filterSafe(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Val
 	return e.ValueFactory.SafeValue(in.Interface())
 }
 
+func filterSafeAttr(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
+	if debug.Enabled {
+		fm := debug.FuncMarker()
+		defer fm.End()
+	}
+	debug.Print("call filter with raw args: safe_attr(%s)", params.String())
+	if p := params.ExpectNothing(); p.IsError() {
+		errors.ThrowFilterArgumentError("safe_attr()", p.Error())
+	}
+	return e.ValueFactory.Value(exec.SafeAttr(in.String()))
+}
+
+func filterSafeCSS(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
+	if debug.Enabled {
+		fm := debug.FuncMarker()
+		defer fm.End()
+	}
+	debug.Print("call filter with raw args: safe_css(%s)", params.String())
+	if p := params.ExpectNothing(); p.IsError() {
+		errors.ThrowFilterArgumentError("safe_css()", p.Error())
+	}
+	return e.ValueFactory.Value(exec.SafeCSS(in.String()))
+}
+
+func filterSafeJS(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
+	if debug.Enabled {
+		fm := debug.FuncMarker()
+		defer fm.End()
+	}
+	debug.Print("call filter with raw args: safe_js(%s)", params.String())
+	if p := params.ExpectNothing(); p.IsError() {
+		errors.ThrowFilterArgumentError("safe_js()", p.Error())
+	}
+	return e.ValueFactory.Value(exec.SafeJS(in.String()))
+}
+
+func filterSafeURL(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
+	if debug.Enabled {
+		fm := debug.FuncMarker()
+		defer fm.End()
+	}
+	debug.Print("call filter with raw args: safe_url(%s)", params.String())
+	if p := params.ExpectNothing(); p.IsError() {
+		errors.ThrowFilterArgumentError("safe_url()", p.Error())
+	}
+	return e.ValueFactory.Value(exec.SafeURL(in.String()))
+}
+
 func filterSelect(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
 	if debug.Enabled {
 		fm := debug.FuncMarker()
@@ -1260,13 +1359,13 @@ func filterToJSON(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.V
 	indent := p.GetKwarg("indent")
 	var out string
 	if indent.IsNil() {
-		b, err := json.Marshal(in.Interface())
+		b, err := json.Marshal(exec.JSONValue(in))
 		if err != nil {
 			errors.ThrowFilterArgumentError("tojson(indent=nil)", "unable to marhsall to json: %s", err.Error())
 		}
 		out = string(b)
 	} else if indent.IsInteger() {
-		b, err := json.MarshalIndent(in.Interface(), "", strings.Repeat(" ", indent.Integer()))
+		b, err := json.MarshalIndent(exec.JSONValue(in), "", strings.Repeat(" ", indent.Integer()))
 		if err != nil {
 			errors.ThrowFilterArgumentError("tojson(indent=nil)", "unable to marhsall to json: %s", err.Error())
 		}
@@ -1274,7 +1373,8 @@ func filterToJSON(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.V
 	} else {
 		errors.ThrowFilterArgumentError("tojson(indent=nil)", "expected an integer for 'indent', got '%s'", indent.String())
 	}
-	return e.ValueFactory.SafeValue(out)
+	// the JSON is safe in HTML as well, since json.Marshal escapes '<', '>' and '&'
+	return e.ValueFactory.SafeValue(exec.SafeJS(out))
 }
 
 func filterTruncate(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
@@ -1,6 +1,10 @@
 package builtins
 
 import (
+	"fmt"
+	"math"
+	"reflect"
+
 	"github.com/aisbergg/gonja/pkg/gonja/errors"
 	"github.com/aisbergg/gonja/pkg/gonja/exec"
 	"github.com/aisbergg/gonja/pkg/gonja/utils"
@@ -15,35 +19,101 @@ var Globals = map[string]any{
 	"range":     Range,
 }
 
-func Range(va *exec.VarArgs) <-chan int {
-	var (
-		start = 0
-		stop  = -1
-		step  = 1
-	)
+// Range returns the lazy sequence of integers from start (inclusive) to stop
+// (exclusive) by step: `range([start, ]stop[, step])`. The step may be
+// negative.
+func Range(va *exec.VarArgs) *IntRange {
+	rng := &IntRange{Step: 1}
 	switch len(va.Args) {
 	case 1:
-		stop = va.Args[0].Integer()
+		rng.Stop = va.Args[0].Integer()
 	case 2:
-		start = va.Args[0].Integer()
-		stop = va.Args[1].Integer()
+		rng.Start = va.Args[0].Integer()
+		rng.Stop = va.Args[1].Integer()
 	case 3:
-		start = va.Args[0].Integer()
-		stop = va.Args[1].Integer()
-		step = va.Args[2].Integer()
-		// default:
-		// 	return nil, errors.New("range expect signature range([start, ]stop[, step])")
-	}
-	chnl := make(chan int)
-	go func() {
-		for i := start; i < stop; i += step {
-			chnl <- i
-		}
-
-		// Ensure that at the end of the loop we close the channel!
-		close(chnl)
-	}()
-	return chnl
+		rng.Start = va.Args[0].Integer()
+		rng.Stop = va.Args[1].Integer()
+		rng.Step = va.Args[2].Integer()
+	default:
+		errors.ThrowTemplateRuntimeError("range expects the signature range([start, ]stop[, step])")
+	}
+	if rng.Step == 0 {
+		errors.ThrowTemplateRuntimeError("range() step must not be zero")
+	}
+	if length := rng.Len(); length > 0 {
+		va.ValueFactory.Sandbox().CheckRange(length)
+	}
+	return rng
+}
+
+// IntRange is the lazy sequence of integers returned by `range`. The integers
+// are generated while iterating, so that no goroutine or memory is needed.
+type IntRange struct {
+	Start, Stop, Step int
+}
+
+var _ exec.Iterable = (*IntRange)(nil)
+
+// Iterator returns a new iterator over the integers of the range.
+func (rng *IntRange) Iterator() exec.Iterator {
+	return &intRangeIterator{rng: rng, next: rng.Start}
+}
+
+// Len returns the number of integers of the range.
+func (rng *IntRange) Len() int {
+	var length int
+	if rng.Step > 0 {
+		length = (rng.Stop - rng.Start + rng.Step - 1) / rng.Step
+	} else {
+		length = (rng.Start - rng.Stop - rng.Step - 1) / -rng.Step
+	}
+	if length < 0 {
+		return 0
+	}
+	return length
+}
+
+// Contains reports whether the integer is part of the range.
+func (rng *IntRange) Contains(item any) bool {
+	var i int
+	switch val := reflect.ValueOf(item); {
+	case val.CanInt():
+		i = int(val.Int())
+	case val.CanUint():
+		i = int(val.Uint())
+	case val.CanFloat() && val.Float() == math.Trunc(val.Float()):
+		i = int(val.Float())
+	default:
+		return false
+	}
+	if rng.Step > 0 && (i < rng.Start || i >= rng.Stop) || rng.Step < 0 && (i > rng.Start || i <= rng.Stop) {
+		return false
+	}
+	return (i-rng.Start)%rng.Step == 0
+}
+
+// String returns the range in Python notation.
+func (rng *IntRange) String() string {
+	if rng.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", rng.Start, rng.Stop)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", rng.Start, rng.Stop, rng.Step)
+}
+
+// intRangeIterator iterates over the integers of an [IntRange].
+type intRangeIterator struct {
+	rng  *IntRange
+	next int
+}
+
+// Next returns the next integer of the range.
+func (it *intRangeIterator) Next() (any, bool) {
+	if it.rng.Step > 0 && it.next >= it.rng.Stop || it.rng.Step < 0 && it.next <= it.rng.Stop {
+		return nil, false
+	}
+	i := it.next
+	it.next += it.rng.Step
+	return i, true
 }
 
 func Dict(va *exec.VarArgs) exec.Value {
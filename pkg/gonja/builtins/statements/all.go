@@ -1,8 +1,30 @@
 package statements
 
 import (
+	"encoding/gob"
+
 	"github.com/aisbergg/gonja/pkg/gonja/exec"
 )
 
 // All holds all built-in statements.
 var All = exec.StatementSet{}
+
+// register the statements for the serialization of parsed templates
+func init() {
+	gob.Register(&AutoescapeStmt{})
+	gob.Register(&BlockStmt{})
+	gob.Register(&CallStmt{})
+	gob.Register(&DoStmt{})
+	gob.Register(&ExtendsStmt{})
+	gob.Register(&FilterStmt{})
+	gob.Register(&ForStmt{})
+	gob.Register(&IfStmt{})
+	gob.Register(&ImportStmt{})
+	gob.Register(&FromImportStmt{})
+	gob.Register(&IncludeStmt{})
+	gob.Register(&LoopControlStmt{})
+	gob.Register(&MacroStmt{})
+	gob.Register(&RawStmt{})
+	gob.Register(&SetStmt{})
+	gob.Register(&WithStmt{})
+}
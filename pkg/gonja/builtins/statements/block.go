@@ -42,7 +42,11 @@ func (stmt *BlockStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode)
 	sub.Ctx.Set("self", exec.Self(sub))
 
 	if err := sub.ExecuteWrapper(block); err != nil {
-		// pass error up the call stack
+		// pass error up the call stack; blocks overridden by a child template
+		// are added to the traceback
+		if rerr, ok := err.(errors.TemplateRuntimeError); ok && block.Location.Template != stmt.Location.Template {
+			rerr.PushFrame("block", stmt.Name, tag.Location.ErrorToken())
+		}
 		panic(err)
 	}
 }
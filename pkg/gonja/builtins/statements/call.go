@@ -0,0 +1,85 @@
+package statements
+
+import (
+	"fmt"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"github.com/aisbergg/gonja/pkg/gonja/parse"
+)
+
+// CallStmt is a call block `{% call macro() %}...{% endcall %}`. The body of
+// the block is passed to the called macro as special `caller` argument.
+type CallStmt struct {
+	Location *parse.Token
+	Call     *parse.CallNode
+	Caller   *parse.MacroNode
+}
+
+var (
+	_ parse.Statement = (*CallStmt)(nil)
+	_ exec.Statement  = (*CallStmt)(nil)
+)
+
+// Position returns the position of the statement.
+func (stmt *CallStmt) Position() *parse.Token { return stmt.Location }
+
+func (stmt *CallStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("CallStmt(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+// Execute executes the call block.
+func (stmt *CallStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
+	r.Current = stmt
+	caller := exec.NewMacroValue(stmt.Caller, r)
+	value := r.EvalCall(stmt.Call, exec.KVPair{Key: "caller", Value: caller})
+	r.RenderValue(value)
+}
+
+func callParser(p, args *parse.Parser) parse.Statement {
+	stmt := &CallStmt{
+		Location: p.Current(),
+		Caller: &parse.MacroNode{
+			Location: p.Current(),
+			Name:     "caller",
+			Args:     []string{},
+			Kwargs:   []*parse.PairNode{},
+		},
+	}
+
+	// arguments of the caller: {% call(user) list_users(users) %}
+	if args.Match(parse.TokenLparen) != nil {
+		parseMacroArgs(args, stmt.Caller)
+	}
+
+	expr := args.ParseExpression()
+	call, ok := expr.(*parse.CallNode)
+	if !ok {
+		errors.ThrowSyntaxError(expr.Position().ErrorToken(), "expected a call expression")
+	}
+	stmt.Call = call
+
+	if !args.End() {
+		errors.ThrowSyntaxError(args.Current().ErrorToken(), "malformed call-tag.")
+	}
+
+	// loop controls can't reach loops outside of the caller
+	loopLevel := p.LoopLevel
+	p.LoopLevel = 0
+	start := p.Stream.Consumed()
+	wrapper, endargs := p.WrapUntil("endcall")
+	p.LoopLevel = loopLevel
+	stmt.Caller.Wrapper = wrapper
+	stmt.Caller.Caller = referencesCaller(p.Stream.ConsumedSince(start))
+
+	if !endargs.End() {
+		errors.ThrowSyntaxError(endargs.Current().ErrorToken(), "arguments not allowed here")
+	}
+
+	return stmt
+}
+
+func init() {
+	All.MustRegister("call", callParser)
+}
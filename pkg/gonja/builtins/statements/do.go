@@ -0,0 +1,56 @@
+package statements
+
+import (
+	"fmt"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"github.com/aisbergg/gonja/pkg/gonja/parse"
+)
+
+// DoStmt evaluates an expression and discards the result, e.g.
+// `{% do list.append(x) %}`.
+type DoStmt struct {
+	Location   *parse.Token
+	Expression parse.Expression
+}
+
+var (
+	_ parse.Statement = (*DoStmt)(nil)
+	_ exec.Statement  = (*DoStmt)(nil)
+)
+
+// Position returns the position of the statement.
+func (stmt *DoStmt) Position() *parse.Token { return stmt.Location }
+
+func (stmt *DoStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("DoStmt(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+// Execute evaluates the expression.
+func (stmt *DoStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
+	r.Current = stmt
+	r.Eval(stmt.Expression)
+}
+
+func doParser(p, args *parse.Parser) parse.Statement {
+	stmt := &DoStmt{
+		Location: p.Current(),
+	}
+
+	if args.End() {
+		errors.ThrowSyntaxError(p.Current().ErrorToken(), "do-tag needs an expression")
+	}
+	stmt.Expression = args.ParseExpression()
+
+	if !args.End() {
+		errors.ThrowSyntaxError(args.Current().ErrorToken(), "malformed do-tag")
+	}
+
+	return stmt
+}
+
+func init() {
+	All.MustRegister("do", doParser)
+}
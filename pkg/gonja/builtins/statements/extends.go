@@ -4,16 +4,24 @@ import (
 	"fmt"
 
 	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
 	"github.com/aisbergg/gonja/pkg/gonja/parse"
 )
 
+// ExtendsStmt extends a parent template. A parent given as string literal at
+// root level is loaded while parsing. Otherwise, the parent is evaluated at
+// runtime, e.g. `{% extends layout %}` or `{% extends ["a.html", "b.html"] %}`.
 type ExtendsStmt struct {
 	Location    *parse.Token
 	Filename    string
+	Expression  parse.Expression
 	WithContext bool
 }
 
-var _ parse.Statement = (*ExtendsStmt)(nil)
+var (
+	_ parse.Statement = (*ExtendsStmt)(nil)
+	_ exec.Statement  = (*ExtendsStmt)(nil)
+)
 
 func (stmt *ExtendsStmt) Position() *parse.Token { return stmt.Location }
 func (stmt *ExtendsStmt) String() string {
@@ -21,29 +29,119 @@ func (stmt *ExtendsStmt) String() string {
 	return fmt.Sprintf("ExtendsStmt(Filename=%s Line=%d Col=%d)", stmt.Filename, t.Line, t.Col)
 }
 
+// Execute sets the parent template, if it is evaluated at runtime.
+func (stmt *ExtendsStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
+	r.Current = stmt
+	if stmt.Expression == nil {
+		// parent has been loaded while parsing
+		return
+	}
+	parent, err := selectTemplate(r, r.Eval(stmt.Expression))
+	if err != nil {
+		panic(err)
+	}
+	// the tag is the location of the parent in the traceback of errors
+	r.Current = tag
+	r.Extend(parent.Root)
+}
+
+// selectTemplate returns the template given by value, which is either a
+// template name, an already loaded template or a list of those. For lists, the
+// first template that exists is returned. If none of the templates exists, a
+// [errors.TemplateNotFoundError] or [errors.TemplatesNotFoundError] is
+// returned. Other load errors are thrown as runtime errors.
+func selectTemplate(r *exec.Renderer, value exec.Value) (*exec.Template, error) {
+	if tpl, ok := value.Interface().(*exec.Template); ok {
+		return tpl, nil
+	}
+
+	candidates := []exec.Value{value}
+	if value.IsList() {
+		candidates = candidates[:0]
+		value.Iterate(func(idx, count int, key, value exec.Value) bool {
+			candidates = append(candidates, key)
+			return true
+		}, func() {})
+	}
+
+	names := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if tpl, ok := candidate.Interface().(*exec.Template); ok {
+			return tpl, nil
+		}
+		name := candidate.String()
+		tpl, err := r.LoadTemplate(name)
+		if err == nil {
+			return tpl, nil
+		}
+		if _, ok := err.(errors.TemplateNotFoundError); !ok {
+			errors.ThrowTemplateRuntimeError("unable to load template '%s': %s", name, err)
+		}
+		names = append(names, name)
+	}
+
+	if !value.IsList() {
+		return nil, errors.NewTemplateNotFoundError(names[0])
+	}
+	return nil, errors.NewTemplatesNotFoundError(names)
+}
+
+// parseIgnoreMissing parses the optional `ignore missing` modifier.
+func parseIgnoreMissing(args *parse.Parser) bool {
+	if args.MatchName("ignore") != nil {
+		if args.MatchName("missing") != nil {
+			return true
+		}
+		args.Stream.Backup()
+	}
+	return false
+}
+
+// rethrowSyntaxError passes a syntax error of a loaded template on, so that it
+// points to the location in the loaded template. The loading statement is
+// added to the traceback of the error.
+func rethrowSyntaxError(err error, kind, name string, location *parse.Token) {
+	if serr, ok := err.(errors.TemplateSyntaxError); ok {
+		serr.PushFrame(kind, name, location.ErrorToken())
+		panic(err)
+	}
+}
+
+// isNotFound reports whether err is caused by a missing template.
+func isNotFound(err error) bool {
+	switch err.(type) {
+	case errors.TemplateNotFoundError, errors.TemplatesNotFoundError:
+		return true
+	}
+	return false
+}
+
 func extendsParser(p, args *parse.Parser) parse.Statement {
 	stmt := &ExtendsStmt{
 		Location: p.Current(),
 	}
 
-	if p.Level > 1 {
-		errors.ThrowSyntaxError(p.Current().ErrorToken(), "the 'extends' statement can only be defined at root level")
-	}
-
 	if p.Template.Parent != nil {
 		errors.ThrowSyntaxError(p.Current().ErrorToken(), "the template can only be extended once")
 	}
 
-	if filename := args.Match(parse.TokenString); filename != nil {
+	if args.End() {
+		errors.ThrowSyntaxError(p.Current().ErrorToken(), "tag 'extends' requires a template name")
+	}
+	expr := args.ParseExpression()
+
+	// a literal filename at root level can be loaded right away, other parents
+	// are evaluated at runtime (e.g. for conditional extends)
+	if filename, ok := expr.(*parse.StringNode); ok && p.Level == 1 {
 		stmt.Filename = filename.Val
 		tpl, err := p.TemplateParseFn(stmt.Filename)
 		if err != nil {
+			rethrowSyntaxError(err, "extends", stmt.Filename, filename.Location)
 			errors.ThrowSyntaxError(p.Current().ErrorToken(), "unable to load parent template '%s': %s", stmt.Filename, err)
 		}
 		p.Template.Parent = tpl
-
 	} else {
-		errors.ThrowSyntaxError(p.Current().ErrorToken(), "tag 'extends' requires a template filename as string.")
+		stmt.Expression = expr
 	}
 
 	if tok := args.MatchName("with", "without"); tok != nil {
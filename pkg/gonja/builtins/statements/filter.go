@@ -10,11 +10,15 @@ import (
 )
 
 // FilterStmt is a statement that applies a filter chain to the output of a
-// previous statement.
+// previous statement. The fields are exported, so that the statement can be
+// encoded into a bundle.
 type FilterStmt struct {
-	position    *parse.Token
-	bodyWrapper *parse.WrapperNode
-	filterChain []*parse.FilterCall
+	// Location is the token of the statement.
+	Location *parse.Token
+	// BodyWrapper is the block whose output is filtered.
+	BodyWrapper *parse.WrapperNode
+	// FilterChain are the filters applied to the output.
+	FilterChain []*parse.FilterCall
 }
 
 var (
@@ -23,7 +27,7 @@ var (
 )
 
 // Position returns the token position of the statement.
-func (stmt *FilterStmt) Position() *parse.Token { return stmt.position }
+func (stmt *FilterStmt) Position() *parse.Token { return stmt.Location }
 
 func (stmt *FilterStmt) String() string {
 	t := stmt.Position()
@@ -37,13 +41,13 @@ func (stmt *FilterStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode)
 	sub := r.Inherit()
 	sub.Out = &out
 
-	if err := sub.ExecuteWrapper(stmt.bodyWrapper); err != nil {
+	if err := sub.ExecuteWrapper(stmt.BodyWrapper); err != nil {
 		// pass error up the call stack
 		panic(err)
 	}
 
 	value := r.ValueFactory.Value(out.String())
-	for _, call := range stmt.filterChain {
+	for _, call := range stmt.FilterChain {
 		value = r.Evaluator().ExecuteFilter(call, value)
 	}
 	r.WriteString(value.String())
@@ -51,15 +55,15 @@ func (stmt *FilterStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode)
 
 func filterParser(p, args *parse.Parser) parse.Statement {
 	stmt := &FilterStmt{
-		position: p.Current(),
+		Location: p.Current(),
 	}
 
 	wrapper, _ := p.WrapUntil("endfilter")
-	stmt.bodyWrapper = wrapper
+	stmt.BodyWrapper = wrapper
 
 	for !args.End() {
 		filterCall := args.ParseFilter()
-		stmt.filterChain = append(stmt.filterChain, filterCall)
+		stmt.FilterChain = append(stmt.FilterChain, filterCall)
 
 		if args.Match(parse.TokenPipe) == nil {
 			break
@@ -3,21 +3,32 @@ package statements
 import (
 	"fmt"
 	"math"
+	"strings"
 
 	"github.com/aisbergg/gonja/pkg/gonja/errors"
 	"github.com/aisbergg/gonja/pkg/gonja/exec"
 	"github.com/aisbergg/gonja/pkg/gonja/parse"
 )
 
+// ForStmt is a `for` loop, e.g. `{% for key, value in map if value %}`. The
+// fields are exported, so that the statement can be encoded into a bundle.
 type ForStmt struct {
-	key string
-	// value is only used for maps: for key, value in map
-	value           string
-	objectEvaluator parse.Expression
-	ifCondition     parse.Expression
-
-	bodyWrapper  *parse.WrapperNode
-	emptyWrapper *parse.WrapperNode
+	// Key is the name of the loop variable, or of the key when iterating over
+	// pairs.
+	Key string
+	// Value is only used for maps: for key, value in map
+	Value string
+	// ObjectEvaluator is the expression of the iterated object.
+	ObjectEvaluator parse.Expression
+	// IfCondition filters the items, if set.
+	IfCondition parse.Expression
+	// Recursive is set for loops that may be called recursively by `loop()`.
+	Recursive bool
+
+	// BodyWrapper is the loop body.
+	BodyWrapper *parse.WrapperNode
+	// EmptyWrapper is the `else` block, rendered if nothing was iterated.
+	EmptyWrapper *parse.WrapperNode
 }
 
 var (
@@ -25,7 +36,7 @@ var (
 	_ exec.Statement  = (*ForStmt)(nil)
 )
 
-func (stmt *ForStmt) Position() *parse.Token { return stmt.bodyWrapper.Position() }
+func (stmt *ForStmt) Position() *parse.Token { return stmt.BodyWrapper.Position() }
 func (stmt *ForStmt) String() string {
 	t := stmt.Position()
 	return fmt.Sprintf("ForStmt(Line=%d Col=%d)", t.Line, t.Col)
@@ -44,6 +55,7 @@ type LoopInfos struct {
 	PrevItem   exec.Value `gonja:"previtem"`
 	NextItem   exec.Value `gonja:"nextitem"`
 	_lastValue exec.Value
+	recurse    func(va *exec.VarArgs) exec.Value
 }
 
 func (li *LoopInfos) Cycle(va *exec.VarArgs) exec.Value {
@@ -56,54 +68,140 @@ func (li *LoopInfos) Changed(value exec.Value) bool {
 	return !same
 }
 
+// loopValue is the value of the special `loop` variable. It exposes the
+// [LoopInfos] and can be called to recurse into a recursive loop.
+type loopValue struct {
+	*exec.GenericValue
+	infos *LoopInfos
+	vf    *exec.ValueFactory
+}
+
+// GetItem returns the loop attribute with the given name.
+func (lv *loopValue) GetItem(key any) exec.Value {
+	switch key {
+	case "cycle":
+		return lv.vf.Value(lv.infos.Cycle)
+	case "changed":
+		return lv.vf.Value(lv.infos.Changed)
+	case "length", "revindex", "revindex0":
+		if lv.infos.Length < 0 {
+			return lv.vf.NewUndefined("loop."+key.(string), "the length of a lazy sequence is unknown, use the 'list' filter to materialize it")
+		}
+	}
+	return lv.vf.Value(lv.infos).GetItem(key)
+}
+
 func (stmt *ForStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
 	r.Current = stmt
-	obj := r.Eval(stmt.objectEvaluator)
+	stmt.execute(r, tag, r.Eval(stmt.ObjectEvaluator), 1)
+}
 
-	// Create loop struct
-	items := exec.NewDict()
+// execute renders the loop body for each item of obj. The depth is greater
+// than one when recursing into a recursive loop.
+//
+// Items are rendered while iterating, so that lazy sequences are only consumed
+// as far as needed. Only if the items are filtered by a condition, they are
+// collected first, so that the length of the loop is known. The length of a
+// loop over a lazy sequence is unknown.
+func (stmt *ForStmt) execute(r *exec.Renderer, tag *parse.StatementBlockNode, obj exec.Value, depth int) {
+	loop := &LoopInfos{
+		First:  true,
+		Index0: -1,
+		Length: -1,
+		Depth:  depth,
+		Depth0: depth - 1,
+	}
+	loop.recurse = func(va *exec.VarArgs) exec.Value {
+		if !stmt.Recursive {
+			errors.ThrowTemplateRuntimeError("tried to call non recursive loop, you may have forgotten the 'recursive' modifier")
+		}
+		p := va.ExpectArgs(1)
+		if p.IsError() {
+			errors.ThrowTemplateRuntimeError("wrong signature for loop(): %s", p.Error())
+		}
+		var out strings.Builder
+		sub := r.Inherit()
+		sub.Out = &out
+		stmt.execute(sub, tag, p.First(), depth+1)
+		return r.ValueFactory.SafeValue(out.String())
+	}
+	loopVal := &loopValue{
+		GenericValue: r.ValueFactory.Value(loop.recurse).(*exec.GenericValue),
+		infos:        loop,
+		vf:           r.ValueFactory,
+	}
 
-	// First iteration: filter values to ensure proper LoopInfos
-	obj.Iterate(func(idx, count int, key, value exec.Value) bool {
+	// an item is rendered once the next item is known (or the iteration ends),
+	// so that loop.last and loop.nextitem can be provided
+	var (
+		pending *exec.Pair
+		idx     int
+	)
+	render := func(next *exec.Pair) bool {
+		r.CheckContext()
+		r.EndTag(tag.Trim)
 		sub := r.Inherit()
-		ctx := sub.Ctx
-		pair := &exec.Pair{}
-
-		// There's something to iterate over (correct type and at least 1 item)
-		// Update loop infos and public context
-		if stmt.value != "" && !key.IsString() && key.Len() == 2 {
-			key.Iterate(func(idx, count int, key, value exec.Value) bool {
-				switch idx {
-				case 0:
-					ctx.Set(stmt.key, key)
-					pair.Key = key
-				case 1:
-					ctx.Set(stmt.value, key)
-					pair.Value = key
-				}
-				return true
-			}, func() {})
+		stmt.setVars(sub.Ctx, pending)
+		sub.Ctx.Set("loop", loopVal)
+
+		loop.Index0 = idx
+		loop.Index = idx + 1
+		loop.First = idx == 0
+		loop.Last = next == nil
+		if loop.Length >= 0 {
+			loop.RevIndex = loop.Length - idx
+			loop.RevIndex0 = loop.Length - (idx + 1)
+		}
+		if idx == 0 {
+			loop.PrevItem = r.ValueFactory.NewUndefined("loop.previtem", "there is no previous item")
+		}
+		if next == nil {
+			loop.NextItem = r.ValueFactory.NewUndefined("loop.nextitem", "there is no next item")
 		} else {
-			ctx.Set(stmt.key, key)
-			pair.Key = key
-			if value != nil {
-				ctx.Set(stmt.value, value)
-				pair.Value = value
-			}
+			loop.NextItem = stmt.itemValue(r, next)
 		}
 
-		if stmt.ifCondition != nil {
-			if !sub.Eval(stmt.ifCondition).Bool() {
+		// Render elements with updated context
+		ctrl := executeLoopBody(sub, stmt.BodyWrapper)
+		loop.PrevItem = stmt.itemValue(r, pending)
+		pending = next
+		idx++
+		return ctrl != loopBreak
+	}
+	push := func(pair *exec.Pair) bool {
+		if pending == nil {
+			pending = pair
+			return true
+		}
+		return render(pair)
+	}
+
+	var collected []*exec.Pair
+	stopped := false
+	obj.Iterate(func(_, count int, key, value exec.Value) bool {
+		r.CountLoopIteration()
+		pair := stmt.pair(key, value)
+
+		if stmt.IfCondition != nil {
+			sub := r.Inherit()
+			stmt.setVars(sub.Ctx, pair)
+			if !sub.Eval(stmt.IfCondition).Bool() {
 				return true
 			}
+			if count >= 0 {
+				collected = append(collected, pair)
+				return true
+			}
+		} else if count >= 0 {
+			loop.Length = count
 		}
-		items.Pairs = append(items.Pairs, pair)
-		return true
+		stopped = !push(pair)
+		return !stopped
 	}, func() {
 		// Nothing to iterate over (maybe wrong type or no items)
-		if stmt.emptyWrapper != nil {
+		if stmt.EmptyWrapper != nil {
 			sub := r.Inherit()
-			err := sub.ExecuteWrapper(stmt.emptyWrapper)
+			err := sub.ExecuteWrapper(stmt.EmptyWrapper)
 			if err != nil {
 				// pass error up the call stack
 				panic(err)
@@ -111,63 +209,71 @@ func (stmt *ForStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
 		}
 	})
 
-	// 2nd pass: all values are defined, render
-	length := len(items.Pairs)
-	loop := &LoopInfos{
-		First:  true,
-		Index0: -1,
+	if collected != nil {
+		loop.Length = len(collected)
+		for _, pair := range collected {
+			if stopped = !push(pair); stopped {
+				break
+			}
+		}
 	}
-	for idx, pair := range items.Pairs {
-		r.EndTag(tag.Trim)
-		sub := r.Inherit()
-		ctx := sub.Ctx
+	if !stopped && pending != nil {
+		render(nil)
+	}
+}
 
-		ctx.Set(stmt.key, pair.Key)
-		if pair.Value != nil {
-			ctx.Set(stmt.value, pair.Value)
-		}
+// pair returns the loop variables of an item. With two loop variables, items
+// of a sequence are unpacked into key and value.
+func (stmt *ForStmt) pair(key, value exec.Value) *exec.Pair {
+	pair := &exec.Pair{Key: key, Value: value}
+	if stmt.Value != "" && value == nil && !key.IsString() && key.IsIterable() && key.Len() == 2 {
+		key.Iterate(func(idx, count int, key, value exec.Value) bool {
+			switch idx {
+			case 0:
+				pair.Key = key
+			case 1:
+				pair.Value = key
+			}
+			return true
+		}, func() {})
+	}
+	return pair
+}
 
-		ctx.Set("loop", loop)
-		loop.Index0 = idx
-		loop.Index = loop.Index0 + 1
-		if idx == 1 {
-			loop.First = false
-		}
-		if idx+1 == length {
-			loop.Last = true
-		}
-		loop.RevIndex = length - idx
-		loop.RevIndex0 = length - (idx + 1)
+// setVars sets the loop variables of an item in the context.
+func (stmt *ForStmt) setVars(ctx *exec.Context, pair *exec.Pair) {
+	ctx.Set(stmt.Key, pair.Key)
+	if pair.Value != nil && stmt.Value != "" {
+		ctx.Set(stmt.Value, pair.Value)
+	}
+}
 
-		if idx == 0 {
-			loop.PrevItem = r.ValueFactory.NewUndefined("loop.previtem", "there is no previous item")
-		} else {
-			pp := items.Pairs[idx-1]
-			if pp.Value != nil {
-				loop.PrevItem = r.ValueFactory.Value([2]exec.Value{pp.Key, pp.Value})
-			} else {
-				loop.PrevItem = pp.Key
-			}
-		}
+// itemValue returns an item as value of loop.previtem or loop.nextitem.
+func (stmt *ForStmt) itemValue(r *exec.Renderer, pair *exec.Pair) exec.Value {
+	if pair.Value != nil {
+		return r.ValueFactory.Value([2]exec.Value{pair.Key, pair.Value})
+	}
+	return pair.Key
+}
 
-		if idx == length-1 {
-			loop.NextItem = r.ValueFactory.NewUndefined("loop.nextitem", "there is no next item")
-		} else {
-			np := items.Pairs[idx+1]
-			if np.Value != nil {
-				loop.NextItem = r.ValueFactory.Value([2]exec.Value{np.Key, np.Value})
-			} else {
-				loop.NextItem = np.Key
+// executeLoopBody renders the body of a loop and returns the loop control
+// statement that ended the rendering, if any.
+func executeLoopBody(r *exec.Renderer, wrapper *parse.WrapperNode) (ctrl loopControl) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			c, ok := rec.(loopControl)
+			if !ok {
+				panic(rec)
 			}
+			ctrl = c
 		}
-
-		// Render elements with updated context
-		err := sub.ExecuteWrapper(stmt.bodyWrapper)
-		if err != nil {
-			// pass error up the call stack
-			panic(err)
-		}
+	}()
+	err := r.ExecuteWrapper(wrapper)
+	if err != nil {
+		// pass error up the call stack
+		panic(err)
 	}
+	return loopNone
 }
 
 func forParser(p, args *parse.Parser) parse.Statement {
@@ -193,15 +299,19 @@ func forParser(p, args *parse.Parser) parse.Statement {
 	}
 
 	objectEvaluator := args.ParseExpression()
-	stmt.objectEvaluator = objectEvaluator
-	stmt.key = keyToken.Val
+	stmt.ObjectEvaluator = objectEvaluator
+	stmt.Key = keyToken.Val
 	if valueToken != nil {
-		stmt.value = valueToken.Val
+		stmt.Value = valueToken.Val
 	}
 
 	if args.MatchName("if") != nil {
 		ifCondition := args.ParseExpression()
-		stmt.ifCondition = ifCondition
+		stmt.IfCondition = ifCondition
+	}
+
+	if args.MatchName("recursive") != nil {
+		stmt.Recursive = true
 	}
 
 	if !args.End() {
@@ -209,8 +319,10 @@ func forParser(p, args *parse.Parser) parse.Statement {
 	}
 
 	// Body wrapping
+	p.LoopLevel++
 	wrapper, endargs := p.WrapUntil("else", "endfor")
-	stmt.bodyWrapper = wrapper
+	p.LoopLevel--
+	stmt.BodyWrapper = wrapper
 
 	if !endargs.End() {
 		errors.ThrowSyntaxError(p.Current().ErrorToken(), "arguments not allowed here")
@@ -219,7 +331,7 @@ func forParser(p, args *parse.Parser) parse.Statement {
 	if wrapper.EndTag == "else" {
 		// if there's an else in the if-statement, we need the else-Block as well
 		wrapper, endargs = p.WrapUntil("endfor")
-		stmt.emptyWrapper = wrapper
+		stmt.EmptyWrapper = wrapper
 
 		if !endargs.End() {
 			errors.ThrowSyntaxError(p.Current().ErrorToken(), "arguments not allowed here")
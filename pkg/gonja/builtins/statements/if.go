@@ -9,10 +9,16 @@ import (
 	"github.com/aisbergg/gonja/pkg/gonja/parse"
 )
 
+// IfStmt is an `if` statement with optional `elif` and `else` blocks. The
+// fields are exported, so that the statement can be encoded into a bundle.
 type IfStmt struct {
-	Location   *parse.Token
-	conditions []parse.Expression
-	wrappers   []*parse.WrapperNode
+	// Location is the token of the statement.
+	Location *parse.Token
+	// Conditions are the conditions of the `if` and `elif` blocks.
+	Conditions []parse.Expression
+	// Wrappers are the blocks of the conditions, followed by the `else` block
+	// if there is one.
+	Wrappers []*parse.WrapperNode
 }
 
 var (
@@ -28,11 +34,11 @@ func (stmt *IfStmt) String() string {
 
 func (stmt *IfStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
 	r.Current = stmt
-	for i, condition := range stmt.conditions {
+	for i, condition := range stmt.Conditions {
 		result := r.Eval(condition)
 
 		if result.Bool() {
-			if err := r.ExecuteWrapper(stmt.wrappers[i]); err != nil {
+			if err := r.ExecuteWrapper(stmt.Wrappers[i]); err != nil {
 				panic(err)
 			}
 			return
@@ -40,8 +46,8 @@ func (stmt *IfStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
 	}
 
 	// else block (has no condition)
-	if len(stmt.wrappers) > len(stmt.conditions) {
-		if err := r.ExecuteWrapper(stmt.wrappers[len(stmt.wrappers)-1]); err != nil {
+	if len(stmt.Wrappers) > len(stmt.Conditions) {
+		if err := r.ExecuteWrapper(stmt.Wrappers[len(stmt.Wrappers)-1]); err != nil {
 			panic(err)
 		}
 	}
@@ -60,7 +66,7 @@ func ifParser(p, args *parse.Parser) parse.Statement {
 
 	// Parse first and main IF condition
 	condition := args.ParseExpression()
-	ifNode.conditions = append(ifNode.conditions, condition)
+	ifNode.Conditions = append(ifNode.Conditions, condition)
 
 	if !args.End() {
 		errors.ThrowSyntaxError(args.Current().ErrorToken(), "if-condition is malformed")
@@ -69,12 +75,12 @@ func ifParser(p, args *parse.Parser) parse.Statement {
 	// Check the rest
 	for {
 		wrapper, tagArgs := p.WrapUntil("elif", "else", "endif")
-		ifNode.wrappers = append(ifNode.wrappers, wrapper)
+		ifNode.Wrappers = append(ifNode.Wrappers, wrapper)
 
 		if wrapper.EndTag == "elif" {
 			// elif can take a condition
 			condition = tagArgs.ParseExpression()
-			ifNode.conditions = append(ifNode.conditions, condition)
+			ifNode.Conditions = append(ifNode.Conditions, condition)
 
 			if !tagArgs.End() {
 				errors.ThrowSyntaxError(tagArgs.Current().ErrorToken(), "elif-condition is malformed")
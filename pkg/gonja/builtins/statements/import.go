@@ -9,14 +9,16 @@ import (
 )
 
 // ImportStmt is a statement that imports a template and makes its macros
-// available.
+// available. With `ignore missing`, a missing template results in an empty set
+// of macros, e.g. `{% import "macros.html" ignore missing as macros %}`.
 type ImportStmt struct {
-	Location     *parse.Token
-	Filename     string
-	FilenameExpr parse.Expression
-	As           string
-	WithContext  bool
-	Template     *parse.TemplateNode
+	Location      *parse.Token
+	Filename      string
+	FilenameExpr  parse.Expression
+	As            string
+	WithContext   bool
+	IgnoreMissing bool
+	Template      *parse.TemplateNode
 }
 
 var (
@@ -35,38 +37,27 @@ func (stmt *ImportStmt) String() string {
 // Execute executes the import statement.
 func (stmt *ImportStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
 	r.Current = stmt
-	var imported map[string]*parse.MacroNode
-	macros := map[string]exec.Macro{}
-
-	if stmt.FilenameExpr != nil {
-		filename := r.Eval(stmt.FilenameExpr).String()
-		tpl, err := r.TemplateLoadFn(filename)
-		if err != nil {
-			errors.ThrowTemplateRuntimeError("unable to load template '%s': %s", filename, err)
-		}
-		imported = tpl.Root.Macros
-
-	} else {
-		imported = stmt.Template.Macros
-	}
-
+	imported := importMacros(r, stmt.FilenameExpr, stmt.Template, stmt.IgnoreMissing)
+	macros := map[string]exec.Value{}
 	for name, macro := range imported {
-		fn := exec.MacroNodeToFunc(macro, r)
-		macros[name] = fn
+		macros[name] = exec.NewMacroValue(macro, r)
 	}
 
 	r.Ctx.Set(stmt.As, macros)
 }
 
 // FromImportStmt is a statement that imports macros from another template.
+// With `ignore missing`, nothing is imported from a missing template, e.g.
+// `{% from "macros.html" ignore missing import input %}`.
 type FromImportStmt struct {
-	Location     *parse.Token
-	Filename     string
-	FilenameExpr parse.Expression
-	WithContext  bool
-	Template     *parse.TemplateNode
-	As           map[string]string
-	Macros       map[string]*parse.MacroNode // alias/name -> macro instance
+	Location      *parse.Token
+	Filename      string
+	FilenameExpr  parse.Expression
+	WithContext   bool
+	IgnoreMissing bool
+	Template      *parse.TemplateNode
+	As            map[string]string
+	Macros        map[string]*parse.MacroNode // alias/name -> macro instance
 }
 
 // Position returns the position of the statement.
@@ -79,25 +70,54 @@ func (stmt *FromImportStmt) String() string {
 
 // Execute executes the import statement.
 func (stmt *FromImportStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
-	var imported map[string]*parse.MacroNode
+	r.Current = stmt
+	imported := importMacros(r, stmt.FilenameExpr, stmt.Template, stmt.IgnoreMissing)
+	if imported == nil {
+		// template is missing
+		return
+	}
 
-	if stmt.FilenameExpr != nil {
-		filename := r.Eval(stmt.FilenameExpr).String()
-		tpl, err := r.TemplateLoadFn(filename)
-		if err != nil {
-			errors.ThrowTemplateRuntimeError("unable to load template '%s': %s", filename, err)
+	for alias, name := range stmt.As {
+		node := imported[name]
+		r.Ctx.Set(alias, exec.NewMacroValue(node, r))
+	}
+}
+
+// importMacros returns the macros of the imported template, which is either
+// preloaded or evaluated from expr. It returns nil, if the template is missing
+// and ignoreMissing is set.
+func importMacros(r *exec.Renderer, expr parse.Expression, preloaded *parse.TemplateNode, ignoreMissing bool) map[string]*parse.MacroNode {
+	if expr == nil {
+		if preloaded == nil {
+			// missing template has been ignored while parsing
+			return nil
 		}
-		imported = tpl.Root.Macros
+		return preloaded.Macros
+	}
 
-	} else {
-		imported = stmt.Template.Macros
+	tpl, err := selectTemplate(r, r.Eval(expr))
+	if err != nil {
+		if ignoreMissing {
+			return nil
+		}
+		panic(err)
 	}
+	return tpl.Root.Macros
+}
 
-	for alias, name := range stmt.As {
-		node := imported[name]
-		fn := exec.MacroNodeToFunc(node, r)
-		r.Ctx.Set(alias, fn)
+// preloadImport loads a statically imported template while parsing. It returns
+// nil, if the template is missing and ignoreMissing is set. The location is the
+// token of the template name.
+func preloadImport(p *parse.Parser, location *parse.Token, filename string, ignoreMissing bool) *parse.TemplateNode {
+	tpl, err := p.TemplateParseFn(filename)
+	if err != nil {
+		if ignoreMissing && isNotFound(err) {
+			return nil
+		}
+		rethrowSyntaxError(err, "import", filename, location)
+		errors.ThrowSyntaxError(location.ErrorToken(), "unable to parse imported template '%s': %s", filename, err)
 	}
+	return tpl
 }
 
 func importParser(p, args *parse.Parser) parse.Statement {
@@ -110,12 +130,14 @@ func importParser(p, args *parse.Parser) parse.Statement {
 		errors.ThrowSyntaxError(args.Current().ErrorToken(), "you must at least specify one macro to import.")
 	}
 
+	location := args.Current()
 	if tok := args.Match(parse.TokenString); tok != nil {
 		stmt.Filename = tok.Val
 	} else {
 		expr := args.ParseExpression()
 		stmt.FilenameExpr = expr
 	}
+	stmt.IgnoreMissing = parseIgnoreMissing(args)
 	if args.MatchName("as") == nil {
 		errors.ThrowSyntaxError(args.Current().ErrorToken(), "expected 'as' keyword, got '%s'", args.Current().Val)
 	}
@@ -136,11 +158,7 @@ func importParser(p, args *parse.Parser) parse.Statement {
 
 	// Preload static template
 	if stmt.Filename != "" {
-		tpl, err := p.TemplateParseFn(stmt.Filename)
-		if err != nil {
-			errors.ThrowSyntaxError(args.Current().ErrorToken(), "unable to parse imported template '%s'", stmt.Filename)
-		}
-		stmt.Template = tpl
+		stmt.Template = preloadImport(p, location, stmt.Filename, stmt.IgnoreMissing)
 	}
 
 	return stmt
@@ -157,12 +175,14 @@ func fromParser(p, args *parse.Parser) parse.Statement {
 		errors.ThrowSyntaxError(args.Current().ErrorToken(), "you must at least specify one macro to import")
 	}
 
+	location := args.Current()
 	if tok := args.Match(parse.TokenString); tok != nil {
 		stmt.Filename = tok.Val
 	} else {
 		filename := args.ParseExpression()
 		stmt.FilenameExpr = filename
 	}
+	stmt.IgnoreMissing = parseIgnoreMissing(args)
 
 	if args.MatchName("import") == nil {
 		errors.ThrowSyntaxError(args.Current().ErrorToken(), "expected 'import' keyword, got '%s'", args.Current().Val)
@@ -213,11 +233,7 @@ func fromParser(p, args *parse.Parser) parse.Statement {
 
 	// Preload static template
 	if stmt.Filename != "" {
-		tpl, err := p.TemplateParseFn(stmt.Filename)
-		if err != nil {
-			errors.ThrowSyntaxError(args.Current().ErrorToken(), "unable to parse imported template '%s'", stmt.Filename)
-		}
-		stmt.Template = tpl
+		stmt.Template = preloadImport(p, location, stmt.Filename, stmt.IgnoreMissing)
 	}
 
 	return stmt
@@ -8,7 +8,9 @@ import (
 	"github.com/aisbergg/gonja/pkg/gonja/parse"
 )
 
-// IncludeStmt is a statement that includes another template.
+// IncludeStmt is a statement that includes another template. The template may
+// be given as a list of templates, of which the first existing one is included,
+// e.g. `{% include ["custom.html", "default.html"] %}`.
 type IncludeStmt struct {
 	Location      *parse.Token
 	Filename      string
@@ -38,16 +40,17 @@ func (stmt *IncludeStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode
 	if stmt.IsEmpty {
 		return
 	}
+	r.EnterInclude()
+	defer r.LeaveInclude()
 	sub := r.Inherit()
 
 	if stmt.FilenameExpr != nil {
-		filename := r.Eval(stmt.FilenameExpr).String()
-		included, err := r.TemplateLoadFn(filename)
+		included, err := selectTemplate(r, r.Eval(stmt.FilenameExpr))
 		if err != nil {
 			if stmt.IgnoreMissing {
 				return
 			}
-			errors.ThrowTemplateRuntimeError("unable to load template '%s': %s", filename, err)
+			panic(err)
 		}
 		sub.Template = included
 		sub.Root = included.Root
@@ -58,6 +61,9 @@ func (stmt *IncludeStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode
 
 	if err := sub.Execute(); err != nil {
 		// pass error up the stack
+		if rerr, ok := err.(errors.TemplateRuntimeError); ok {
+			rerr.PushFrame("include", sub.Root.Name, tag.Location.ErrorToken())
+		}
 		panic(err)
 	}
 }
@@ -73,6 +79,7 @@ func includeParser(p, args *parse.Parser) parse.Statement {
 		Location: p.Current(),
 	}
 
+	location := args.Current()
 	if tok := args.Match(parse.TokenString); tok != nil {
 		stmt.Filename = tok.Val
 	} else {
@@ -80,13 +87,7 @@ func includeParser(p, args *parse.Parser) parse.Statement {
 		stmt.FilenameExpr = filename
 	}
 
-	if args.MatchName("ignore") != nil {
-		if args.MatchName("missing") != nil {
-			stmt.IgnoreMissing = true
-		} else {
-			args.Stream.Backup()
-		}
-	}
+	stmt.IgnoreMissing = parseIgnoreMissing(args)
 
 	if tok := args.MatchName("with", "without"); tok != nil {
 		if args.MatchName("context") != nil {
@@ -100,10 +101,11 @@ func includeParser(p, args *parse.Parser) parse.Statement {
 	if stmt.Filename != "" {
 		tpl, err := p.TemplateParseFn(stmt.Filename)
 		if err != nil {
-			if stmt.IgnoreMissing {
+			if stmt.IgnoreMissing && isNotFound(err) {
 				stmt.IsEmpty = true
 			} else {
-				errors.ThrowSyntaxError(stmt.Location.ErrorToken(), "unable to parse included template '%s'", stmt.Filename)
+				rethrowSyntaxError(err, "include", stmt.Filename, location)
+				errors.ThrowSyntaxError(stmt.Location.ErrorToken(), "unable to parse included template '%s': %s", stmt.Filename, err)
 			}
 		} else {
 			stmt.Template = tpl
@@ -0,0 +1,78 @@
+package statements
+
+import (
+	"fmt"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"github.com/aisbergg/gonja/pkg/gonja/parse"
+)
+
+// loopControl is used to unwind the rendering of a loop body to the enclosing
+// loop.
+type loopControl int
+
+const (
+	loopNone loopControl = iota
+	loopBreak
+	loopContinue
+)
+
+func (c loopControl) String() string {
+	switch c {
+	case loopBreak:
+		return "break"
+	case loopContinue:
+		return "continue"
+	}
+	return "none"
+}
+
+// LoopControlStmt is a `break` or `continue` statement inside a loop. The
+// fields are exported, so that the statement can be encoded into a bundle.
+type LoopControlStmt struct {
+	// Location is the token of the statement.
+	Location *parse.Token
+	// Control is the kind of the statement.
+	Control loopControl
+}
+
+var (
+	_ parse.Statement = (*LoopControlStmt)(nil)
+	_ exec.Statement  = (*LoopControlStmt)(nil)
+)
+
+// Position returns the position of the statement.
+func (stmt *LoopControlStmt) Position() *parse.Token { return stmt.Location }
+
+func (stmt *LoopControlStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("LoopControlStmt(Control=%s Line=%d Col=%d)", stmt.Control, t.Line, t.Col)
+}
+
+// Execute stops the rendering of the current loop body.
+func (stmt *LoopControlStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
+	r.Current = stmt
+	panic(stmt.Control)
+}
+
+func loopControlParser(control loopControl) parse.StatementParser {
+	return func(p, args *parse.Parser) parse.Statement {
+		stmt := &LoopControlStmt{
+			Location: p.Current(),
+			Control:  control,
+		}
+		if p.LoopLevel == 0 {
+			errors.ThrowSyntaxError(p.Current().ErrorToken(), "'%s' outside of a loop", control)
+		}
+		if !args.End() {
+			errors.ThrowSyntaxError(args.Current().ErrorToken(), "arguments not allowed here")
+		}
+		return stmt
+	}
+}
+
+func init() {
+	All.MustRegister("break", loopControlParser(loopBreak))
+	All.MustRegister("continue", loopControlParser(loopContinue))
+}
@@ -25,7 +25,7 @@ func (stmt *MacroStmt) String() string {
 
 func (stmt *MacroStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
 	r.Current = stmt
-	macro := exec.MacroNodeToFunc(stmt.MacroNode, r)
+	macro := exec.NewMacroValue(stmt.MacroNode, r)
 	r.Ctx.Set(stmt.Name, macro)
 }
 
@@ -93,32 +93,7 @@ func macroParser(p, args *parse.Parser) parse.Statement {
 	if args.Match(parse.TokenLparen) == nil {
 		errors.ThrowSyntaxError(args.Current().ErrorToken(), "unexpected '%s', expected '('", args.Current().Val)
 	}
-
-	for args.Match(parse.TokenRparen) == nil {
-		argName := args.Match(parse.TokenName)
-		if argName == nil {
-			errors.ThrowSyntaxError(args.Current().ErrorToken(), "expected argument name as identifier.")
-		}
-
-		if args.Match(parse.TokenAssign) != nil {
-			// Default expression follows
-			expr := args.ParseExpression()
-			stmt.Kwargs = append(stmt.Kwargs, &parse.PairNode{
-				Key:   &parse.StringNode{argName, argName.Val},
-				Value: expr,
-			})
-			// stmt.Kwargs[argName.Val] = expr
-		} else {
-			stmt.Args = append(stmt.Args, argName.Val)
-		}
-
-		if args.Match(parse.TokenRparen) != nil {
-			break
-		}
-		if args.Match(parse.TokenComma) == nil {
-			errors.ThrowSyntaxError(args.Current().ErrorToken(), "unexpected '%s', expected ',' or ')'", args.Current().Val)
-		}
-	}
+	parseMacroArgs(args, stmt)
 
 	// if args.MatchName("export") != nil {
 	// 	stmt.exported = true
@@ -129,8 +104,14 @@ func macroParser(p, args *parse.Parser) parse.Statement {
 	}
 
 	// Body wrapping
+	// loop controls can't reach loops outside of the macro
+	loopLevel := p.LoopLevel
+	p.LoopLevel = 0
+	start := p.Stream.Consumed()
 	wrapper, endargs := p.WrapUntil("endmacro")
+	p.LoopLevel = loopLevel
 	stmt.Wrapper = wrapper
+	stmt.Caller = referencesCaller(p.Stream.ConsumedSince(start))
 
 	if !endargs.End() {
 		errors.ThrowSyntaxError(endargs.Current().ErrorToken(), "arguments not allowed here")
@@ -150,6 +131,47 @@ func macroParser(p, args *parse.Parser) parse.Statement {
 	return &MacroStmt{stmt}
 }
 
+// parseMacroArgs parses the argument list of a macro or call block up to and
+// including the closing parenthesis.
+func parseMacroArgs(args *parse.Parser, stmt *parse.MacroNode) {
+	for args.Match(parse.TokenRparen) == nil {
+		argName := args.Match(parse.TokenName)
+		if argName == nil {
+			errors.ThrowSyntaxError(args.Current().ErrorToken(), "expected argument name as identifier.")
+		}
+
+		if args.Match(parse.TokenAssign) != nil {
+			// Default expression follows
+			expr := args.ParseExpression()
+			stmt.Kwargs = append(stmt.Kwargs, &parse.PairNode{
+				Key:   &parse.StringNode{argName, argName.Val},
+				Value: expr,
+			})
+			// stmt.Kwargs[argName.Val] = expr
+		} else {
+			stmt.Args = append(stmt.Args, argName.Val)
+		}
+
+		if args.Match(parse.TokenRparen) != nil {
+			break
+		}
+		if args.Match(parse.TokenComma) == nil {
+			errors.ThrowSyntaxError(args.Current().ErrorToken(), "unexpected '%s', expected ',' or ')'", args.Current().Val)
+		}
+	}
+}
+
+// referencesCaller reports whether the given tokens reference the special
+// `caller` variable.
+func referencesCaller(tokens []*parse.Token) bool {
+	for _, tok := range tokens {
+		if tok.Type == parse.TokenName && tok.Val == "caller" {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	All.MustRegister("macro", macroParser)
 }
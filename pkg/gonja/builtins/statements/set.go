@@ -33,11 +33,25 @@ func (stmt *SetStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
 
 	switch n := stmt.Target.(type) {
 	case *parse.NameNode:
-		r.Ctx.Set(n.Name.Val, value.Interface())
+		// custom values (e.g. 'loop') provide their attributes only on the
+		// wrapper, hence they are stored as they are
+		if gv, ok := value.(*exec.GenericValue); ok {
+			if gv.IsNil() {
+				r.Ctx.Set(n.Name.Val, nil)
+			} else {
+				r.Ctx.Set(n.Name.Val, gv.Interface())
+			}
+		} else {
+			r.Ctx.Set(n.Name.Val, value)
+		}
 
 	case *parse.GetItemNode:
 		target := r.Eval(n.Node)
-		target.SetItem(n.Arg, value.Interface())
+		key := n.Arg
+		if n.Key != nil {
+			key = r.Eval(n.Key).String()
+		}
+		target.SetItem(key, value.Interface())
 
 	default:
 		errors.ThrowTemplateRuntimeError("illegal set target node %s", n)
@@ -0,0 +1,115 @@
+package gonja
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"github.com/aisbergg/gonja/pkg/gonja/loaders"
+)
+
+// CompileBundle loads the templates with the given names and writes them as
+// bundle of precompiled templates to w. The bundle can be loaded with a
+// [loaders.BundleLoader].
+func (env *Environment) CompileBundle(w io.Writer, names ...string) error {
+	templates := make([]*exec.Template, 0, len(names))
+	for _, name := range names {
+		tpl, err := env.FromFile(name)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, tpl)
+	}
+	return loaders.WriteBundle(w, templates...)
+}
+
+// CompileBundleDir compiles the files in dir and its subdirectories into a
+// bundle of precompiled templates and writes it to w. Only files with one of
+// the given extensions (e.g. ".html") are compiled, or all files if no
+// extensions are given. The templates are named by their paths relative to
+// dir. Hidden files and directories are skipped, symlinks are followed. The options are used for
+// parsing the templates, the loader is set to load from dir.
+//
+// It is meant to be used with `go generate` to embed the templates into a
+// binary:
+//
+//	//go:generate go run ./gen
+//	//go:embed templates.bundle
+//	var bundle []byte
+//
+// where `gen/main.go` writes the bundle:
+//
+//	f, err := os.Create("templates.bundle")
+//	...
+//	err = gonja.CompileBundleDir(f, "templates", []string{".html"})
+func CompileBundleDir(w io.Writer, dir string, extensions []string, options ...Option) error {
+	loader, err := loaders.NewFileSystemLoader(dir)
+	if err != nil {
+		return err
+	}
+	env := NewEnvironment(append(options, OptLoader(loader))...)
+
+	names, err := collectTemplates(dir, "", extensions, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	return env.CompileBundle(w, names...)
+}
+
+// collectTemplates returns the names of the template files in dir and its
+// subdirectories. Symlinks are followed; visited contains the resolved paths
+// of the visited directories to prevent cycles.
+func collectTemplates(dir, prefix string, extensions []string, visited map[string]bool) ([]string, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	if visited[resolved] {
+		return nil, nil
+	}
+	visited[resolved] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		fullPath := filepath.Join(dir, entry.Name())
+		stat, err := os.Stat(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		name := path.Join(prefix, entry.Name())
+		if stat.IsDir() {
+			subNames, err := collectTemplates(fullPath, name, extensions, visited)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, subNames...)
+		} else if stat.Mode().IsRegular() && hasExtension(name, extensions) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// hasExtension reports whether the path has one of the extensions. Any path
+// matches, if no extensions are given.
+func hasExtension(path string, extensions []string) bool {
+	if len(extensions) == 0 {
+		return true
+	}
+	for _, ext := range extensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,8 @@
 package gonja
 
 import (
-	"sync"
-
 	"github.com/aisbergg/gonja/pkg/gonja/builtins"
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
 	"github.com/aisbergg/gonja/pkg/gonja/exec"
 	"github.com/aisbergg/gonja/pkg/gonja/loaders"
 )
@@ -15,8 +14,8 @@ type Environment struct {
 	*exec.EvalConfig
 	loader loaders.Loader
 
-	cache      map[string]*exec.Template
-	cacheMutex sync.Mutex
+	// autoReload enables the auto reloading template cache
+	autoReload bool
 }
 
 // NewEnvironment creates a new [Environment].
@@ -24,11 +23,8 @@ func NewEnvironment(options ...Option) *Environment {
 	env := &Environment{
 		EvalConfig: exec.NewEvalConfig(),
 		loader:     loaders.NewNullLoader(),
-		cache:      map[string]*exec.Template{},
-	}
-	env.EvalConfig.TemplateLoadFn = func(name string) (*exec.Template, error) {
-		return env.loader.Load(name, env.EvalConfig)
 	}
+	env.EvalConfig.TemplateLoadFn = env.load
 	env.Filters.Update(builtins.Filters)
 	env.Statements.Update(builtins.Statements)
 	env.Tests.Update(builtins.Tests)
@@ -40,22 +36,40 @@ func NewEnvironment(options ...Option) *Environment {
 	for _, option := range options {
 		option(env)
 	}
+	if env.autoReload {
+		env.loader = loaders.NewCachedLoaderWithOptions(env.loader, true)
+	}
 	return env
 }
 
 // FromString loads a template from string and returns a Template instance.
 func (env *Environment) FromString(tpl string) (*exec.Template, error) {
-	return exec.NewTemplate("string", tpl, env.EvalConfig)
+	return exec.NewTemplate(exec.StringTemplateName, tpl, env.EvalConfig)
 }
 
 // FromBytes loads a template from bytes and returns a Template instance.
 func (env *Environment) FromBytes(tpl []byte) (*exec.Template, error) {
-	return exec.NewTemplate("bytes", string(tpl), env.EvalConfig)
+	return exec.NewTemplate(exec.BytesTemplateName, string(tpl), env.EvalConfig)
 }
 
 // FromFile loads a template from a path and returns a Template instance. It
 // uses the configured loader, so make sure you provided a loader that will find
 // and load the template.
 func (env *Environment) FromFile(path string) (*exec.Template, error) {
-	return env.loader.Load(path, env.EvalConfig)
+	return env.load(path)
+}
+
+// load loads a template using the configured loader. Panics of the loader are
+// returned as [errors.TemplateLoadError].
+func (env *Environment) load(name string) (tpl *exec.Template, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			tpl = nil
+			err = errors.FromPanic(rec)
+			if _, ok := err.(errors.TemplateError); !ok {
+				err = errors.NewTemplateLoadError(name, "unable to load template '%s': %s", name, err)
+			}
+		}
+	}()
+	return env.loader.Load(name, env.EvalConfig)
 }
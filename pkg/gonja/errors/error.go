@@ -13,12 +13,43 @@ type TemplateError interface {
 	TemplateError()
 }
 
+// FromPanic returns a recovered panic value as error. Values that are not
+// errors are formatted using [fmt.Errorf].
+func FromPanic(rec any) error {
+	if err, ok := rec.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", rec)
+}
+
 // Token is a token representation for error reporting.
 type Token struct {
-	Val  string
-	Pos  int
-	Line int
-	Col  int
+	// Template is the name of the template the token belongs to.
+	Template string `json:"template,omitempty"`
+	Val      string `json:"value"`
+	Pos      int    `json:"pos"`
+	Line     int    `json:"line"`
+	Col      int    `json:"col"`
+	// SourceLine is the line of the template source containing the token, if
+	// known. It is used for the excerpts of a [Report].
+	SourceLine string `json:"source,omitempty"`
+}
+
+// Location returns the location of the token in the form `template:line:col`.
+// The template name is omitted, if unknown.
+func (t Token) Location() string {
+	if t.Template == "" {
+		return fmt.Sprintf("%d:%d", t.Line, t.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", t.Template, t.Line, t.Col)
+}
+
+// formatError formats an error message with the location of the token.
+func formatError(msg string, token *Token) string {
+	if token == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s (near: '%s')", token.Location(), msg, token.Val)
 }
 
 // String returns a string representation of the token.
@@ -27,7 +58,7 @@ func (t Token) String() string {
 	if len(val) > 1000 {
 		val = fmt.Sprintf("%s...%s", val[:10], val[len(val)-5:])
 	}
-	return fmt.Sprintf("<Token Val='%s' Pos=%d Line=%d Col=%d>", val, t.Pos, t.Line, t.Col)
+	return fmt.Sprintf("<Token Template='%s' Val='%s' Pos=%d Line=%d Col=%d>", t.Template, val, t.Pos, t.Line, t.Col)
 }
 
 // stack is an array of stack frames stored in a human readable format.
@@ -17,13 +17,18 @@ type TemplateRuntimeError interface {
 	TemplateRuntimeError()
 	Enrich(*Token)
 	Token() *Token
+	Message() string
+	Frames() []Frame
+	PushFrame(kind, name string, tk *Token)
 }
 
 var _ TemplateRuntimeError = (*templateRuntimeError)(nil)
 
 type templateRuntimeError struct {
+	traceback
 	msg   string
 	token *Token
+	cause error
 }
 
 // TemplateError is a marker interface for template errors.
@@ -33,20 +38,31 @@ func (e *templateRuntimeError) TemplateError() {}
 func (e *templateRuntimeError) TemplateRuntimeError() {}
 
 func (e *templateRuntimeError) Error() string {
-	if e.token == nil {
-		return e.msg
-	}
-	return fmt.Sprintf("%s (pos: %d, line: %d, column: %d, near: '%s')", e.msg, e.token.Pos, e.token.Line, e.token.Col, e.token.Val)
+	return formatError(e.msg, e.token)
 }
 
 func (e *templateRuntimeError) Token() *Token {
 	return e.token
 }
 
+// Message returns the error message without location.
+func (e *templateRuntimeError) Message() string {
+	return e.msg
+}
+
+// Unwrap returns the error that caused the runtime error, if any.
+func (e *templateRuntimeError) Unwrap() error {
+	return e.cause
+}
+
+// Enrich sets the location of the error or, if already set, the location of
+// the outermost frame that lacks one.
 func (e *templateRuntimeError) Enrich(tk *Token) {
 	if e.token == nil {
 		e.token = tk
+		return
 	}
+	e.enrichFrame(tk)
 }
 
 // NewTemplateRuntimeError creates a new TemplateRuntimeError.
@@ -62,6 +78,21 @@ func NewTemplateRuntimeError(format string, args ...any) TemplateRuntimeError {
 	}
 }
 
+// WrapTemplateRuntimeError creates a new TemplateRuntimeError caused by the
+// given error. The cause can be retrieved using [errors.Unwrap].
+func WrapTemplateRuntimeError(cause error, format string, args ...any) TemplateRuntimeError {
+	if debug.Enabled {
+		// in debug mode we include a stack trace with the error message
+		stackTrace := getStackTrace(1)
+		format = format + "\n\n%s"
+		args = append(args, stackTrace)
+	}
+	return &templateRuntimeError{
+		msg:   fmt.Sprintf(format, args...),
+		cause: cause,
+	}
+}
+
 // ThrowTemplateRuntimeError throws a generic template runtime error.
 func ThrowTemplateRuntimeError(format string, args ...any) {
 	if debug.Enabled {
@@ -156,3 +187,120 @@ func ThrowUndefinedError(varName, hint string) {
 func (e *undefinedError) VariableName() string {
 	return e.variableName
 }
+
+// -----------------------------------------------------------------------------
+// LimitExceededError
+// -----------------------------------------------------------------------------
+
+// LimitExceededError is thrown when a resource limit (e.g. the number of loop
+// iterations) is exceeded while rendering a template.
+type LimitExceededError interface {
+	TemplateRuntimeError
+	LimitExceededError()
+	Limit() string
+	Max() int
+}
+
+var _ LimitExceededError = (*limitExceededError)(nil)
+
+type limitExceededError struct {
+	templateRuntimeError
+	limit string
+	max   int
+}
+
+// LimitExceededError is a marker interface for limit exceeded errors.
+func (e *limitExceededError) LimitExceededError() {}
+
+// Limit returns the name of the exceeded limit.
+func (e *limitExceededError) Limit() string {
+	return e.limit
+}
+
+// Max returns the maximum value of the exceeded limit.
+func (e *limitExceededError) Max() int {
+	return e.max
+}
+
+// NewLimitExceededError creates a new LimitExceededError.
+func NewLimitExceededError(limit string, max int) LimitExceededError {
+	return &limitExceededError{
+		templateRuntimeError: templateRuntimeError{
+			msg: fmt.Sprintf("%s limit of %d exceeded", limit, max),
+		},
+		limit: limit,
+		max:   max,
+	}
+}
+
+// ThrowLimitExceededError throws a limit exceeded error.
+func ThrowLimitExceededError(limit string, max int) {
+	panic(NewLimitExceededError(limit, max))
+}
+
+// -----------------------------------------------------------------------------
+// ContextError
+// -----------------------------------------------------------------------------
+
+// ContextError is thrown when the rendering of a template is aborted, because
+// its context was canceled or its deadline exceeded. The context error can be
+// retrieved using Unwrap.
+type ContextError interface {
+	TemplateRuntimeError
+	ContextError()
+	Unwrap() error
+}
+
+var _ ContextError = (*contextError)(nil)
+
+type contextError struct {
+	templateRuntimeError
+	err error
+}
+
+// ContextError is a marker interface for context errors.
+func (e *contextError) ContextError() {}
+
+// Unwrap returns the underlying context error.
+func (e *contextError) Unwrap() error {
+	return e.err
+}
+
+// ThrowContextError throws a context error.
+func ThrowContextError(err error) {
+	panic(&contextError{
+		templateRuntimeError: templateRuntimeError{
+			msg: fmt.Sprintf("rendering aborted: %s", err),
+		},
+		err: err,
+	})
+}
+
+// -----------------------------------------------------------------------------
+// UnsafeOperationError
+// -----------------------------------------------------------------------------
+
+// UnsafeOperationError is thrown when a sandboxed template tries to perform an
+// operation that is not allowed by the sandbox.
+type UnsafeOperationError interface {
+	TemplateRuntimeError
+	UnsafeOperationError()
+}
+
+var _ UnsafeOperationError = (*unsafeOperationError)(nil)
+
+type unsafeOperationError struct {
+	templateRuntimeError
+}
+
+// UnsafeOperationError is a marker interface for unsafe operation errors.
+func (e *unsafeOperationError) UnsafeOperationError() {}
+
+// ThrowUnsafeOperationError throws an unsafe operation error.
+func ThrowUnsafeOperationError(format string, args ...any) {
+	panic(&unsafeOperationError{
+		templateRuntimeError: templateRuntimeError{
+			msg: fmt.Sprintf(format, args...),
+		},
+	})
+}
@@ -49,16 +49,22 @@ func NewTemplateLoadError(name, format string, args ...any) TemplateLoadError {
 // TemplateNotFoundError
 // -----------------------------------------------------------------------------
 
-// TemplateNotFoundError is thrown when a filter is called with invalid arguments.
+// TemplateNotFoundError is thrown when a template cannot be found. When thrown
+// while rendering (e.g. by a dynamic `extends`), it is a TemplateRuntimeError
+// as well.
 type TemplateNotFoundError interface {
 	TemplateError
 	TemplateNotFoundError()
 	Name() string
 }
 
-var _ TemplateNotFoundError = (*templateNotFoundError)(nil)
+var (
+	_ TemplateNotFoundError = (*templateNotFoundError)(nil)
+	_ TemplateRuntimeError  = (*templateNotFoundError)(nil)
+)
 
 type templateNotFoundError struct {
+	templateRuntimeError
 	name string
 }
 
@@ -69,7 +75,7 @@ func (e *templateNotFoundError) TemplateError() {}
 func (e *templateNotFoundError) TemplateNotFoundError() {}
 
 func (e *templateNotFoundError) Error() string {
-	return fmt.Sprintf("template '%s' not found", e.name)
+	return e.templateRuntimeError.Error()
 }
 
 func (e *templateNotFoundError) Name() string {
@@ -79,6 +85,9 @@ func (e *templateNotFoundError) Name() string {
 // NewTemplateNotFoundError creates a new TemplateNotFoundError.
 func NewTemplateNotFoundError(name string) TemplateNotFoundError {
 	return &templateNotFoundError{
+		templateRuntimeError: templateRuntimeError{
+			msg: fmt.Sprintf("template '%s' not found", name),
+		},
 		name: name,
 	}
 }
@@ -87,16 +96,22 @@ func NewTemplateNotFoundError(name string) TemplateNotFoundError {
 // TemplatesNotFoundError
 // -----------------------------------------------------------------------------
 
-// TemplatesNotFoundError is thrown when a filter is called with invalid arguments.
+// TemplatesNotFoundError is thrown when none of multiple templates can be
+// found. When thrown while rendering (e.g. by a dynamic `extends`), it is a
+// TemplateRuntimeError as well.
 type TemplatesNotFoundError interface {
 	TemplateError
 	TemplatesNotFoundError()
 	Names() []string
 }
 
-var _ TemplatesNotFoundError = (*templatesNotFoundError)(nil)
+var (
+	_ TemplatesNotFoundError = (*templatesNotFoundError)(nil)
+	_ TemplateRuntimeError   = (*templatesNotFoundError)(nil)
+)
 
 type templatesNotFoundError struct {
+	templateRuntimeError
 	names []string
 }
 
@@ -107,10 +122,20 @@ func (e *templatesNotFoundError) TemplateError() {}
 func (e *templatesNotFoundError) TemplatesNotFoundError() {}
 
 func (e *templatesNotFoundError) Error() string {
-	return fmt.Sprintf("non of the given templates could be found: %s", strings.Join(e.names, ", "))
+	return e.templateRuntimeError.Error()
 }
 
 // Names returns the names of the templates that were not found.
 func (e *templatesNotFoundError) Names() []string {
 	return e.names
 }
+
+// NewTemplatesNotFoundError creates a new TemplatesNotFoundError.
+func NewTemplatesNotFoundError(names []string) TemplatesNotFoundError {
+	return &templatesNotFoundError{
+		templateRuntimeError: templateRuntimeError{
+			msg: fmt.Sprintf("none of the given templates could be found: %s", strings.Join(names, ", ")),
+		},
+		names: names,
+	}
+}
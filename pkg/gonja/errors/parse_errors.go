@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"strings"
 
 	debug "github.com/aisbergg/gonja/internal/debug/parse"
 )
@@ -15,13 +16,19 @@ type TemplateSyntaxError interface {
 	TemplateError
 	TemplateSyntaxError()
 	Pos() int
+	Token() *Token
+	Message() string
+	Frames() []Frame
+	PushFrame(kind, name string, tk *Token)
 }
 
 var _ TemplateSyntaxError = (*templateSyntaxError)(nil)
 
 type templateSyntaxError struct {
+	traceback
 	msg   string
 	token *Token
+	cause error
 }
 
 // TemplateError is a marker interface for template errors.
@@ -31,7 +38,7 @@ func (e *templateSyntaxError) TemplateError() {}
 func (e *templateSyntaxError) TemplateSyntaxError() {}
 
 func (e *templateSyntaxError) Error() string {
-	return fmt.Sprintf("%s (pos: %d, line: %d, column: %d, near: '%s')", e.msg, e.token.Pos, e.token.Line, e.token.Col, e.token.Val)
+	return formatError(e.msg, e.token)
 }
 
 // Pos returns the position of the error.
@@ -39,10 +46,43 @@ func (e *templateSyntaxError) Pos() int {
 	return e.token.Pos
 }
 
+// Token returns the location of the error.
+func (e *templateSyntaxError) Token() *Token {
+	return e.token
+}
+
+// Message returns the error message without location.
+func (e *templateSyntaxError) Message() string {
+	return e.msg
+}
+
+// Unwrap returns the error that caused the syntax error, if any.
+func (e *templateSyntaxError) Unwrap() error {
+	return e.cause
+}
+
 // Enrich enriches the error with a token.
 func (e *templateSyntaxError) Enrich(tk *Token) {
 	if e.token == nil {
 		e.token = tk
+		return
+	}
+	e.enrichFrame(tk)
+}
+
+// WrapSyntaxError creates a new TemplateSyntaxError caused by the given error.
+// The cause can be retrieved using [errors.Unwrap].
+func WrapSyntaxError(cause error, token *Token, format string, args ...any) TemplateSyntaxError {
+	if debug.Enabled {
+		// in debug mode we include a stack trace with the error message
+		stackTrace := getStackTrace(1)
+		format = format + "\n\n%s"
+		args = append(args, stackTrace)
+	}
+	return &templateSyntaxError{
+		msg:   fmt.Sprintf(format, args...),
+		token: token,
+		cause: cause,
 	}
 }
 
@@ -93,3 +133,77 @@ func ThrowTemplateAssertionError(fname, format string, args ...any) {
 		},
 	})
 }
+
+// -----------------------------------------------------------------------------
+// TemplateSyntaxErrors
+// -----------------------------------------------------------------------------
+
+// TemplateSyntaxErrors is returned when a template contains multiple syntax
+// errors. It behaves like its first error, so it can be handled like a single
+// [TemplateSyntaxError].
+type TemplateSyntaxErrors interface {
+	TemplateSyntaxError
+	Errors() []TemplateSyntaxError
+}
+
+var _ TemplateSyntaxErrors = (*templateSyntaxErrors)(nil)
+
+type templateSyntaxErrors struct {
+	errs []TemplateSyntaxError
+}
+
+// NewTemplateSyntaxErrors combines the given syntax errors. A single error is
+// returned unchanged.
+func NewTemplateSyntaxErrors(errs []TemplateSyntaxError) TemplateSyntaxError {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return &templateSyntaxErrors{errs: errs}
+}
+
+// TemplateError is a marker interface for template errors.
+func (e *templateSyntaxErrors) TemplateError() {}
+
+// TemplateSyntaxError is a marker interface for template syntax errors.
+func (e *templateSyntaxErrors) TemplateSyntaxError() {}
+
+// Error returns the messages of all errors, one per line.
+func (e *templateSyntaxErrors) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Errors returns the syntax errors in the order of their occurrence.
+func (e *templateSyntaxErrors) Errors() []TemplateSyntaxError {
+	return e.errs
+}
+
+// Pos returns the position of the first error.
+func (e *templateSyntaxErrors) Pos() int {
+	return e.errs[0].Pos()
+}
+
+// Token returns the location of the first error.
+func (e *templateSyntaxErrors) Token() *Token {
+	return e.errs[0].Token()
+}
+
+// Message returns the message of the first error.
+func (e *templateSyntaxErrors) Message() string {
+	return e.errs[0].Message()
+}
+
+// Frames returns the traceback of the first error.
+func (e *templateSyntaxErrors) Frames() []Frame {
+	return e.errs[0].Frames()
+}
+
+// PushFrame adds an outer frame to the traceback of all errors.
+func (e *templateSyntaxErrors) PushFrame(kind, name string, tk *Token) {
+	for _, err := range e.errs {
+		err.PushFrame(kind, name, tk)
+	}
+}
@@ -0,0 +1,238 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// -----------------------------------------------------------------------------
+// Traceback
+// -----------------------------------------------------------------------------
+
+// Frame is a frame of the template traceback of an error, e.g. an `include`
+// statement or a macro call, that led to the failure.
+type Frame struct {
+	// Kind is the kind of the frame: "include", "import", "extends", "block" or
+	// "macro".
+	Kind string `json:"kind"`
+	// Name is the name of the included template, block or macro.
+	Name string `json:"name"`
+	// Token is the location the frame has been entered from, e.g. the include
+	// statement or the call of the macro.
+	Token *Token `json:"location,omitempty"`
+}
+
+// traceback holds the template traceback of an error. The innermost frame
+// comes first.
+type traceback struct {
+	frames []Frame
+}
+
+// Frames returns the template traceback of the error. The innermost frame
+// comes first.
+func (tb *traceback) Frames() []Frame {
+	return tb.frames
+}
+
+// PushFrame adds an outer frame to the traceback. If the token is nil, it is
+// set by the next call of Enrich.
+func (tb *traceback) PushFrame(kind, name string, tk *Token) {
+	tb.frames = append(tb.frames, Frame{Kind: kind, Name: name, Token: tk})
+}
+
+// enrichFrame sets the token of the outermost frame, if it is missing.
+func (tb *traceback) enrichFrame(tk *Token) {
+	if n := len(tb.frames); n > 0 && tb.frames[n-1].Token == nil {
+		tb.frames[n-1].Token = tk
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Report
+// -----------------------------------------------------------------------------
+
+// Report is a detailed report of an error. It renders as text with an
+// annotated source excerpt and the template traceback, and it can be encoded
+// as JSON, e.g. for editor integrations.
+//
+// Example:
+//
+//	if err := tpl.Execute(w, ctx); err != nil {
+//		fmt.Println(errors.NewReport(err))
+//	}
+type Report struct {
+	// Kind is the kind of the error: "syntax", "runtime", "load" or "error".
+	Kind string `json:"kind"`
+	// Message is the error message without location.
+	Message string `json:"message"`
+	// Token is the location of the error, if known.
+	Token *Token `json:"location,omitempty"`
+	// Frames is the template traceback. The innermost frame comes first.
+	Frames []Frame `json:"traceback,omitempty"`
+	// Errors are the reports of the individual errors, if the error consists of
+	// multiple errors (see [TemplateSyntaxErrors]).
+	Errors []*Report `json:"errors,omitempty"`
+}
+
+// NewReport creates a new [Report] of an error. It returns nil if err is nil.
+func NewReport(err error) *Report {
+	if err == nil {
+		return nil
+	}
+	report := &Report{Kind: "error", Message: err.Error()}
+	switch e := err.(type) {
+	case TemplateSyntaxErrors:
+		report.Kind = "syntax"
+		report.Message = fmt.Sprintf("%d syntax errors", len(e.Errors()))
+		for _, serr := range e.Errors() {
+			report.Errors = append(report.Errors, NewReport(serr))
+		}
+	case TemplateSyntaxError:
+		report.Kind = "syntax"
+		report.Message = e.Message()
+		report.Token = e.Token()
+		report.Frames = e.Frames()
+	case TemplateRuntimeError:
+		report.Kind = "runtime"
+		report.Message = e.Message()
+		report.Token = e.Token()
+		report.Frames = e.Frames()
+	case TemplateLoadError:
+		report.Kind = "load"
+	}
+	return report
+}
+
+// String returns the report as text. The location of the error and of every
+// frame is followed by an excerpt of the source line, if available. Multiple
+// errors are listed one after another.
+//
+//	mail/partial.html:2:6: undefined variable: missing
+//	    2 | Hi {{ missing.name }}
+//	      |       ^~~~~~~
+//	  included from mail/index.html:1:4
+//	    1 | {% include 'mail/partial.html' %}
+//	      |    ^~~~~~~
+//
+// A nil report renders as an empty string.
+func (r *Report) String() string {
+	if r == nil {
+		return ""
+	}
+	var sb strings.Builder
+	if len(r.Errors) > 0 {
+		for _, report := range r.Errors {
+			sb.WriteString(report.String())
+		}
+		return sb.String()
+	}
+	if r.Token != nil {
+		sb.WriteString(r.Token.Location())
+		sb.WriteString(": ")
+	}
+	sb.WriteString(r.Message)
+	sb.WriteByte('\n')
+	writeExcerpt(&sb, r.Token)
+	for _, frame := range r.Frames {
+		switch frame.Kind {
+		case "include":
+			sb.WriteString("  included from ")
+		case "import":
+			sb.WriteString("  imported from ")
+		case "extends":
+			sb.WriteString("  extended from ")
+		case "block":
+			fmt.Fprintf(&sb, "  in block '%s' rendered from ", frame.Name)
+		default:
+			fmt.Fprintf(&sb, "  in %s '%s' called from ", frame.Kind, frame.Name)
+		}
+		if frame.Token != nil {
+			sb.WriteString(frame.Token.Location())
+		} else {
+			sb.WriteString("<unknown>")
+		}
+		sb.WriteByte('\n')
+		writeExcerpt(&sb, frame.Token)
+	}
+	return sb.String()
+}
+
+// JSON returns the report encoded as JSON.
+func (r *Report) JSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// writeExcerpt writes the source line of the token with a caret marking the
+// token.
+func writeExcerpt(sb *strings.Builder, tk *Token) {
+	if tk == nil || tk.SourceLine == "" {
+		return
+	}
+	line := strings.TrimRight(tk.SourceLine, "\r\n")
+	number := fmt.Sprint(tk.Line)
+	gutter := strings.Repeat(" ", len(number))
+	fmt.Fprintf(sb, "    %s | %s\n", number, line)
+
+	// keep tabs, so that the caret lines up with the source
+	var indent strings.Builder
+	col := 1
+	for _, r := range line {
+		if col >= tk.Col {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteByte(' ')
+		}
+		col++
+	}
+	width := utf8.RuneCountInString(strings.SplitN(tk.Val, "\n", 2)[0])
+	if width < 1 {
+		width = 1
+	}
+	fmt.Fprintf(sb, "    %s | %s^%s\n", gutter, indent.String(), strings.Repeat("~", width-1))
+}
+
+// AttachSources sets the source lines of the tokens of an error and its
+// traceback. The source function returns the source of a template by name.
+// Tokens that already have a source line are skipped.
+func AttachSources(err error, source func(template string) (string, bool)) {
+	var tokens []*Token
+	switch e := err.(type) {
+	case TemplateSyntaxErrors:
+		for _, serr := range e.Errors() {
+			AttachSources(serr, source)
+		}
+		return
+	case TemplateSyntaxError:
+		tokens = append(tokens, e.Token())
+		for _, frame := range e.Frames() {
+			tokens = append(tokens, frame.Token)
+		}
+	case TemplateRuntimeError:
+		tokens = append(tokens, e.Token())
+		for _, frame := range e.Frames() {
+			tokens = append(tokens, frame.Token)
+		}
+	}
+
+	lines := map[string][]string{}
+	for _, tk := range tokens {
+		if tk == nil || tk.SourceLine != "" || tk.Line < 1 {
+			continue
+		}
+		srcLines, ok := lines[tk.Template]
+		if !ok {
+			if src, found := source(tk.Template); found {
+				srcLines = strings.Split(src, "\n")
+			}
+			lines[tk.Template] = srcLines
+		}
+		if tk.Line <= len(srcLines) {
+			tk.SourceLine = srcLines[tk.Line-1]
+		}
+	}
+}
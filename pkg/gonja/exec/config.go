@@ -17,6 +17,16 @@ type EvalConfig struct {
 	Tests          *TestSet
 	TemplateLoadFn TemplateLoadFn
 
+	// JoinPath joins the name of a referenced template (e.g. in `include` or
+	// `extends`) with the name of the referencing template. If nil, the names
+	// are used as they are. See [JoinPathRelative].
+	JoinPath JoinPathFn
+
+	// NamePrefix is prepended to the names of the templates created with the
+	// configuration, e.g. by loaders that load templates by a shortened name
+	// (see loaders.PrefixLoader).
+	NamePrefix string
+
 	// ExtensionConfig stores configuration for extensions.
 	ExtensionConfig map[string]ext.Inheritable
 
@@ -51,6 +61,40 @@ type EvalConfig struct {
 	// Autoescape will escape XML/HTML automatically, if set to true. Defaults
 	// to false.
 	Autoescape bool
+
+	// ContextualAutoescape escapes values according to their context in the
+	// HTML output (text, attribute, JavaScript, CSS or URL) like Go's
+	// html/template, if Autoescape is enabled as well. Defaults to false.
+	ContextualAutoescape bool
+
+	// Escaper escapes the rendered values, if Autoescape is enabled. Defaults
+	// to nil, which selects HTML escaping.
+	Escaper EscapeFn
+
+	// AutoescapeFn selects the autoescaping of each template by its name, if
+	// set. It overrides Autoescape and Escaper, e.g. to escape `.html`
+	// templates only (see [SelectAutoescape]).
+	AutoescapeFn AutoescapeFn
+
+	// MaxLoopIterations limits the total number of loop iterations during a
+	// single render. Zero means no limit.
+	MaxLoopIterations int
+
+	// MaxMacroDepth limits the depth of nested macro calls. Zero means no
+	// limit.
+	MaxMacroDepth int
+
+	// MaxIncludeDepth limits the depth of nested includes. Zero means no
+	// limit.
+	MaxIncludeDepth int
+
+	// MaxOutputSize limits the size of the rendered output in bytes. The
+	// buffers of macros, `filter` blocks or recursive loops are limited to the
+	// same size each. Zero means no limit.
+	MaxOutputSize int
+
+	// Sandbox restricts what templates may access, if set. See [NewSandbox].
+	Sandbox *Sandbox
 }
 
 // NewEvalConfig creates a new evaluator configuration.
@@ -88,23 +132,32 @@ func (cfg EvalConfig) Inherit() *EvalConfig {
 		Statements:     cfg.Statements,
 		Tests:          cfg.Tests,
 		TemplateLoadFn: cfg.TemplateLoadFn,
-
-		ExtensionConfig:     extCfg,
-		CustomTypes:         cfg.CustomTypes,
-		Undefined:           cfg.Undefined,
-		NewlineSequence:     cfg.NewlineSequence,
-		TrimBlocks:          cfg.TrimBlocks,
-		LstripBlocks:        cfg.LstripBlocks,
-		KeepTrailingNewline: cfg.KeepTrailingNewline,
-		Autoescape:          cfg.Autoescape,
+		JoinPath:       cfg.JoinPath,
+		NamePrefix:     cfg.NamePrefix,
+
+		ExtensionConfig:      extCfg,
+		CustomTypes:          cfg.CustomTypes,
+		Undefined:            cfg.Undefined,
+		NewlineSequence:      cfg.NewlineSequence,
+		TrimBlocks:           cfg.TrimBlocks,
+		LstripBlocks:         cfg.LstripBlocks,
+		KeepTrailingNewline:  cfg.KeepTrailingNewline,
+		Autoescape:           cfg.Autoescape,
+		ContextualAutoescape: cfg.ContextualAutoescape,
+		Escaper:              cfg.Escaper,
+		AutoescapeFn:         cfg.AutoescapeFn,
+		MaxLoopIterations:    cfg.MaxLoopIterations,
+		MaxMacroDepth:        cfg.MaxMacroDepth,
+		MaxIncludeDepth:      cfg.MaxIncludeDepth,
+		MaxOutputSize:        cfg.MaxOutputSize,
+		Sandbox:              cfg.Sandbox,
 	}
 }
 
-// templateParseFn returns the parsed template for the given filename.
-func (cfg *EvalConfig) templateParseFn(filename string) (*parse.TemplateNode, error) {
-	tpl, err := cfg.TemplateLoadFn(filename)
-	if err != nil {
-		return nil, err
+// joinPath joins a template name with the name of the referencing template.
+func (cfg *EvalConfig) joinPath(name, parent string) string {
+	if cfg.JoinPath == nil {
+		return name
 	}
-	return tpl.Root, nil
+	return cfg.JoinPath(name, parent)
 }
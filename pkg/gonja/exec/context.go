@@ -2,6 +2,7 @@ package exec
 
 import (
 	"fmt"
+	"reflect"
 
 	debug "github.com/aisbergg/gonja/internal/debug/exec"
 )
@@ -50,7 +51,9 @@ func (ctx *Context) Get(name string) Value {
 
 	value, exists := ctx.data[name]
 	if exists {
-		return ctx.valueFactory.Value(value)
+		return withAssign(ctx.valueFactory.Value(value), func(value reflect.Value) {
+			ctx.data[name] = value.Interface()
+		})
 	} else if ctx.parent != nil {
 		return ctx.parent.Get(name)
 	} else if ctx.userData != nil {
@@ -61,7 +64,9 @@ func (ctx *Context) Get(name string) Value {
 		// save the item in the context so that we do not have to resolve it
 		// again
 		ctx.data[name] = item
-		return item
+		return withAssign(item, func(value reflect.Value) {
+			ctx.data[name] = value.Interface()
+		})
 	}
 
 	undefined := ctx.valueFactory.NewUndefined(name, fmt.Sprintf("'%s' not found in context", name))
@@ -0,0 +1,794 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// -----------------------------------------------------------------------------
+// Escapers
+// -----------------------------------------------------------------------------
+
+// EscapeFn escapes a value rendered by a template.
+type EscapeFn func(value Value) string
+
+// AutoescapeFn selects the autoescaping of a template by the name of the
+// template. It returns whether values are escaped and the escaper to use. A nil
+// escaper selects the default HTML escaping.
+type AutoescapeFn func(name string) (bool, EscapeFn)
+
+// SelectAutoescape returns an [AutoescapeFn] that enables HTML escaping for
+// templates with one of the given file extensions (e.g. ".html") and for
+// templates created from strings, similar to Jinja's select_autoescape.
+// Autoescaping is disabled for all other templates.
+func SelectAutoescape(extensions ...string) AutoescapeFn {
+	escapers := make(map[string]EscapeFn, len(extensions))
+	for _, ext := range extensions {
+		escapers[ext] = nil
+	}
+	return SelectEscaper(escapers)
+}
+
+// SelectEscaper returns an [AutoescapeFn] that selects the escaper of a
+// template by its file extension. A nil escaper selects the default HTML
+// escaping. Templates created from strings are HTML escaped, autoescaping is
+// disabled for templates with other extensions. Use [EscaperSelector] to
+// change these defaults.
+//
+// Example:
+//
+//	exec.SelectEscaper(map[string]exec.EscapeFn{
+//		".html": nil,
+//		".sh":   exec.EscapeShell,
+//		".yaml": exec.EscapeYAML,
+//	})
+func SelectEscaper(escapers map[string]EscapeFn) AutoescapeFn {
+	return EscaperSelector{Escapers: escapers, DefaultForString: true}.Fn()
+}
+
+// EscaperSelector selects the escaper of a template by its file extension.
+type EscaperSelector struct {
+	// Escapers maps file extensions (e.g. ".html") to escapers. A nil escaper
+	// selects the default HTML escaping.
+	Escapers map[string]EscapeFn
+
+	// Disabled lists the file extensions of templates, which are never
+	// escaped, even if Default is set.
+	Disabled []string
+
+	// DefaultForString enables autoescaping of templates created from strings
+	// or bytes (see [StringTemplateName]).
+	DefaultForString bool
+
+	// Default enables autoescaping of templates with other extensions.
+	Default bool
+
+	// DefaultEscaper escapes the templates selected by DefaultForString or
+	// Default. Nil selects the default HTML escaping.
+	DefaultEscaper EscapeFn
+}
+
+// Fn returns the [AutoescapeFn] of the selector.
+func (s EscaperSelector) Fn() AutoescapeFn {
+	escapers := make(map[string]EscapeFn, len(s.Escapers))
+	for ext, escaper := range s.Escapers {
+		escapers[strings.ToLower(ext)] = escaper
+	}
+	disabled := make(map[string]bool, len(s.Disabled))
+	for _, ext := range s.Disabled {
+		disabled[strings.ToLower(ext)] = true
+	}
+	return func(name string) (bool, EscapeFn) {
+		if name == StringTemplateName || name == BytesTemplateName {
+			return s.DefaultForString, s.DefaultEscaper
+		}
+		ext := strings.ToLower(path.Ext(name))
+		if escaper, ok := escapers[ext]; ok {
+			return true, escaper
+		}
+		if disabled[ext] {
+			return false, nil
+		}
+		return s.Default, s.DefaultEscaper
+	}
+}
+
+// EscapeHTML escapes strings for HTML, unless they are marked as safe.
+func EscapeHTML(value Value) string {
+	if value.IsString() && !value.IsSafe() {
+		return value.Escaped()
+	}
+	return value.String()
+}
+
+// EscapeShell quotes values as single words for POSIX shells, unless they are
+// marked as safe.
+func EscapeShell(value Value) string {
+	if value.IsSafe() {
+		return value.String()
+	}
+	return "'" + strings.ReplaceAll(value.String(), "'", `'\''`) + "'"
+}
+
+// EscapeYAML writes values as YAML scalars, unless they are marked as safe.
+// Strings are double-quoted, lists and dicts are written in flow style.
+func EscapeYAML(value Value) string {
+	switch {
+	case value.IsSafe():
+		return value.String()
+	case value.IsNil():
+		return "null"
+	case value.IsBool():
+		return strconv.FormatBool(value.Bool())
+	case value.IsNumber():
+		return value.String()
+	case value.IsList(), value.IsDict():
+		if b, err := json.Marshal(JSONValue(value)); err == nil {
+			return string(b)
+		}
+	}
+	b, _ := json.Marshal(value.String())
+	return string(b)
+}
+
+// -----------------------------------------------------------------------------
+// Safe Types
+// -----------------------------------------------------------------------------
+
+// SafeJS is a trusted JavaScript expression, e.g. a JSON document. The
+// contextual autoescaper renders it as-is in JavaScript contexts.
+type SafeJS string
+
+// SafeCSS is a trusted CSS fragment, e.g. a declaration or a property value.
+// The contextual autoescaper renders it as-is in CSS contexts.
+type SafeCSS string
+
+// SafeURL is a trusted URL. The contextual autoescaper renders it without
+// filtering its scheme in URL attributes.
+type SafeURL string
+
+// SafeAttr is a trusted list of attributes, e.g. `dir="ltr"`. The contextual
+// autoescaper renders it as-is inside tags.
+type SafeAttr string
+
+// unsafeReplacement replaces values that cannot be made safe in their context
+// (same as in Go's html/template).
+const unsafeReplacement = "ZgotmplZ"
+
+// -----------------------------------------------------------------------------
+// Output Context
+// -----------------------------------------------------------------------------
+
+// htmlState is the state of the HTML tokenizer of an [outputContext].
+type htmlState uint8
+
+const (
+	stateText          htmlState = iota // text between tags
+	stateTagOpen                        // after '<' or '</'
+	stateTagName                        // in the name of a tag
+	stateTag                            // in a tag, between attributes
+	stateAttrName                       // in the name of an attribute
+	stateAfterAttrName                  // after the name of an attribute
+	stateBeforeValue                    // after the '=' of an attribute
+	stateAttrValue                      // in the value of an attribute
+	stateMarkupDecl                     // after '<!'
+	stateComment                        // in a comment
+	stateRawText                        // in the content of script, style, title or textarea
+)
+
+// attrType is the type of an attribute value.
+type attrType uint8
+
+const (
+	attrPlain attrType = iota
+	attrJS
+	attrCSS
+	attrURL
+)
+
+// scriptState is the state of the JavaScript or CSS tokenizer of an
+// [outputContext].
+type scriptState uint8
+
+const (
+	scriptCode scriptState = iota
+	scriptString
+	scriptLineComment
+	scriptBlockComment
+)
+
+// urlPart is the part of a URL written so far.
+type urlPart uint8
+
+const (
+	urlNone urlPart = iota
+	urlPath
+	urlQuery
+)
+
+// rawTextElements are the elements, whose content is not parsed as HTML.
+var rawTextElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"textarea": true,
+	"title":    true,
+}
+
+// urlAttrs are the attributes, whose values are URLs.
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"archive":    true,
+	"background": true,
+	"cite":       true,
+	"classid":    true,
+	"codebase":   true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"icon":       true,
+	"longdesc":   true,
+	"manifest":   true,
+	"poster":     true,
+	"profile":    true,
+	"src":        true,
+	"srcset":     true,
+	"usemap":     true,
+	"xmlns":      true,
+}
+
+// outputContext tracks the HTML context of the output of a renderer, so that
+// values are escaped according to where they are written (see
+// [EvalConfig.ContextualAutoescape]). The tokenizer is deliberately simple:
+// character references in attribute values and regular expression literals in
+// scripts are not decoded.
+type outputContext struct {
+	// out is the writer the context belongs to
+	out io.Writer
+
+	state   htmlState
+	endTag  bool
+	element string
+	// name is the name of the tag or attribute being parsed
+	name []byte
+	attr attrType
+	// delim is the delimiter of the attribute value or 0, if it is unquoted
+	delim byte
+	// dashes counts the dashes at the start or end of a comment
+	dashes int
+	// match counts the characters of the end tag of a raw text element seen
+	match int
+
+	script  scriptState
+	quote   byte
+	escaped bool
+	prev    byte
+	url     urlPart
+}
+
+// clone returns a copy of the context for another writer.
+func (c *outputContext) clone(out io.Writer) *outputContext {
+	clone := *c
+	clone.out = out
+	clone.name = append([]byte(nil), c.name...)
+	return &clone
+}
+
+// feed advances the context by the text written to the output.
+func (c *outputContext) feed(txt string) {
+	for i := 0; i < len(txt); i++ {
+		c.next(txt[i])
+	}
+}
+
+func (c *outputContext) next(b byte) {
+	switch c.state {
+	case stateText:
+		if b == '<' {
+			c.state = stateTagOpen
+			c.endTag = false
+		}
+
+	case stateTagOpen:
+		switch {
+		case b == '/' && !c.endTag:
+			c.endTag = true
+		case b == '!' && !c.endTag:
+			c.state = stateMarkupDecl
+			c.dashes = 0
+		case isASCIILetter(b):
+			c.state = stateTagName
+			c.name = append(c.name[:0], toLower(b))
+		case b == '<':
+			c.endTag = false
+		default:
+			c.state = stateText
+		}
+
+	case stateTagName:
+		switch {
+		case isSpace(b) || b == '/':
+			c.element = string(c.name)
+			c.state = stateTag
+		case b == '>':
+			c.element = string(c.name)
+			c.endOfTag()
+		default:
+			c.name = append(c.name, toLower(b))
+		}
+
+	case stateTag:
+		switch {
+		case isSpace(b) || b == '/':
+		case b == '>':
+			c.endOfTag()
+		default:
+			c.state = stateAttrName
+			c.name = append(c.name[:0], toLower(b))
+		}
+
+	case stateAttrName:
+		switch {
+		case isSpace(b):
+			c.state = stateAfterAttrName
+		case b == '=':
+			c.state = stateBeforeValue
+		case b == '/':
+			c.state = stateTag
+		case b == '>':
+			c.endOfTag()
+		default:
+			c.name = append(c.name, toLower(b))
+		}
+
+	case stateAfterAttrName:
+		switch {
+		case isSpace(b):
+		case b == '=':
+			c.state = stateBeforeValue
+		case b == '/':
+			c.state = stateTag
+		case b == '>':
+			c.endOfTag()
+		default:
+			c.state = stateAttrName
+			c.name = append(c.name[:0], toLower(b))
+		}
+
+	case stateBeforeValue:
+		switch {
+		case isSpace(b):
+		case b == '"' || b == '\'':
+			c.startValue(b)
+		case b == '>':
+			c.endOfTag()
+		default:
+			c.startValue(0)
+			c.valueByte(b)
+		}
+
+	case stateAttrValue:
+		switch {
+		case c.delim != 0 && b == c.delim, c.delim == 0 && isSpace(b):
+			c.state = stateTag
+		case c.delim == 0 && b == '>':
+			c.endOfTag()
+		default:
+			c.valueByte(b)
+		}
+
+	case stateMarkupDecl:
+		switch {
+		case b == '-' && c.dashes >= 0:
+			c.dashes++
+			if c.dashes == 2 {
+				c.state = stateComment
+				c.dashes = 0
+			}
+		case b == '>':
+			c.state = stateText
+		default:
+			c.dashes = -1
+		}
+
+	case stateComment:
+		switch {
+		case b == '-':
+			c.dashes++
+		case b == '>' && c.dashes >= 2:
+			c.state = stateText
+			c.dashes = 0
+		default:
+			c.dashes = 0
+		}
+
+	case stateRawText:
+		c.rawTextByte(b)
+	}
+}
+
+// endOfTag switches to the content of the tag.
+func (c *outputContext) endOfTag() {
+	if !c.endTag && rawTextElements[c.element] {
+		c.state = stateRawText
+		c.match = 0
+		c.resetScript()
+		return
+	}
+	c.state = stateText
+}
+
+// startValue switches to the value of the current attribute.
+func (c *outputContext) startValue(delim byte) {
+	c.state = stateAttrValue
+	c.delim = delim
+	c.attr = attrTypeOf(string(c.name))
+	c.url = urlNone
+	c.resetScript()
+}
+
+// valueByte advances the context by a character of an attribute value.
+func (c *outputContext) valueByte(b byte) {
+	switch c.attr {
+	case attrJS:
+		c.scriptByte(b, false)
+	case attrCSS:
+		c.scriptByte(b, true)
+	case attrURL:
+		if b == '?' || b == '#' {
+			c.url = urlQuery
+		} else if c.url == urlNone {
+			c.url = urlPath
+		}
+	}
+}
+
+// rawTextByte advances the context by a character of the content of a raw text
+// element and detects its end tag.
+func (c *outputContext) rawTextByte(b byte) {
+	switch c.element {
+	case "script":
+		c.scriptByte(b, false)
+	case "style":
+		c.scriptByte(b, true)
+	}
+
+	// match `</element` followed by a space, '/' or '>'
+	if c.match == len(c.element)+2 {
+		c.match = 0
+		if isSpace(b) || b == '/' || b == '>' {
+			c.endTag = true
+			c.state = stateTag
+			if b == '>' {
+				c.endOfTag()
+			}
+			return
+		}
+	}
+	switch {
+	case c.match < 2 && b == "</"[c.match],
+		c.match >= 2 && toLower(b) == c.element[c.match-2]:
+		c.match++
+	case b == '<':
+		c.match = 1
+	default:
+		c.match = 0
+	}
+}
+
+// resetScript resets the JavaScript or CSS tokenizer.
+func (c *outputContext) resetScript() {
+	c.script = scriptCode
+	c.quote = 0
+	c.escaped = false
+	c.prev = 0
+}
+
+// scriptByte advances the JavaScript or CSS tokenizer by a character. Only
+// strings and comments are tracked.
+func (c *outputContext) scriptByte(b byte, css bool) {
+	switch c.script {
+	case scriptCode:
+		switch {
+		case b == '"' || b == '\'' || b == '`' && !css:
+			c.script = scriptString
+			c.quote = b
+		case b == '/' && c.prev == '/' && !css:
+			c.script = scriptLineComment
+		case b == '*' && c.prev == '/':
+			c.script = scriptBlockComment
+			// the '*' must not close the comment
+			b = 0
+		}
+	case scriptString:
+		switch {
+		case c.escaped:
+			c.escaped = false
+		case b == '\\':
+			c.escaped = true
+		case b == c.quote:
+			c.script = scriptCode
+		}
+	case scriptLineComment:
+		if b == '\n' {
+			c.script = scriptCode
+		}
+	case scriptBlockComment:
+		if b == '/' && c.prev == '*' {
+			c.script = scriptCode
+			b = 0
+		}
+	}
+	c.prev = b
+}
+
+// escape returns the value as string escaped for the current context.
+func (c *outputContext) escape(value Value) string {
+	switch c.state {
+	case stateTagOpen, stateTagName:
+		return escapeName(value, "")
+	case stateTag, stateAfterAttrName:
+		if _, ok := stringInterface(value).(SafeAttr); ok || value.IsSafe() {
+			return value.String()
+		}
+		return escapeName(value, "")
+	case stateAttrName:
+		return escapeName(value, string(c.name))
+	case stateBeforeValue:
+		return escapeAttrValue(value, attrTypeOf(string(c.name)), 0, scriptCode, urlNone)
+	case stateAttrValue:
+		return escapeAttrValue(value, c.attr, c.delim, c.script, c.url)
+	case stateRawText:
+		switch c.element {
+		case "script":
+			return escapeJS(value, c.script)
+		case "style":
+			return escapeCSS(value)
+		}
+	}
+	if value.IsSafe() {
+		return value.String()
+	}
+	return value.Escaped()
+}
+
+// -----------------------------------------------------------------------------
+// Context Escapers
+// -----------------------------------------------------------------------------
+
+// stringInterface returns the underlying value of a string value, so that its
+// type can be checked against the safe types.
+func stringInterface(value Value) any {
+	if !value.IsString() {
+		return nil
+	}
+	return value.Interface()
+}
+
+// escapeName escapes a value written as (part of) the name of a tag or an
+// attribute. Names of attributes with JavaScript, CSS or URL values are
+// rejected.
+func escapeName(value Value, prefix string) string {
+	s := value.String()
+	for i := 0; i < len(s); i++ {
+		if b := s[i]; !isASCIILetter(b) && !isASCIIDigit(b) && b != '-' && b != '_' && b != ':' {
+			return unsafeReplacement
+		}
+	}
+	if prefix+s != "" && attrTypeOf(strings.ToLower(prefix+s)) != attrPlain {
+		return unsafeReplacement
+	}
+	return s
+}
+
+// escapeAttrValue escapes a value written into an attribute value of the given
+// type.
+func escapeAttrValue(value Value, typ attrType, delim byte, script scriptState, url urlPart) string {
+	var s string
+	switch typ {
+	case attrJS:
+		s = escapeJS(value, script)
+	case attrCSS:
+		s = escapeCSS(value)
+	case attrURL:
+		s = escapeURL(value, url)
+	default:
+		if value.IsSafe() && delim != 0 {
+			return value.String()
+		}
+		s = value.String()
+	}
+	if delim == 0 {
+		return unquotedAttrEscaper.Replace(s)
+	}
+	return html.EscapeString(s)
+}
+
+// unquotedAttrEscaper escapes the values of unquoted attributes.
+var unquotedAttrEscaper = strings.NewReplacer(
+	"&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;", "'", "&#39;",
+	"`", "&#96;", "=", "&#61;", " ", "&#32;", "\t", "&#9;", "\n", "&#10;",
+	"\r", "&#13;", "\f", "&#12;",
+)
+
+// escapeJS escapes a value written into JavaScript code. Within strings, the
+// value is escaped as string content. Otherwise, it is written as JavaScript
+// literal, unless it is of type [SafeJS].
+func escapeJS(value Value, script scriptState) string {
+	switch script {
+	case scriptString, scriptLineComment, scriptBlockComment:
+		return escapeJSString(value.String())
+	}
+	if s, ok := stringInterface(value).(SafeJS); ok {
+		return string(s)
+	}
+
+	var s string
+	switch {
+	case value.IsNil():
+		s = "null"
+	case value.IsBool(), value.IsNumber(), value.IsList(), value.IsDict():
+		b, err := json.Marshal(JSONValue(value))
+		if err != nil {
+			b, _ = json.Marshal(value.String())
+		}
+		s = string(b)
+	default:
+		b, _ := json.Marshal(value.String())
+		s = string(b)
+	}
+	// avoid `x-{{ -1 }}` becoming a decrement
+	if strings.HasPrefix(s, "-") {
+		s = " " + s
+	}
+	return s
+}
+
+// escapeJSString escapes a string for JavaScript string literals. Quotes and
+// HTML special characters are escaped as unicode escapes.
+func escapeJSString(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"', '\'', '`', '$', '<', '>', '&', '/', '\u2028', '\u2029':
+			fmt.Fprintf(&sb, `\u%04x`, r)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// escapeCSS escapes a value written into CSS. All characters but letters,
+// digits and a few punctuation characters are written as CSS escapes, unless
+// the value is of type [SafeCSS].
+func escapeCSS(value Value) string {
+	if s, ok := stringInterface(value).(SafeCSS); ok {
+		return string(s)
+	}
+	var sb strings.Builder
+	for _, r := range value.String() {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), strings.ContainsRune(" #%,.-_", r):
+			sb.WriteRune(r)
+		default:
+			fmt.Fprintf(&sb, `\%x `, r)
+		}
+	}
+	return sb.String()
+}
+
+// escapeURL escapes a value written into a URL. A value starting the URL must
+// have a safe scheme (http, https or mailto), unless it is of type [SafeURL].
+// Values in the query or fragment are percent-encoded completely.
+func escapeURL(value Value, part urlPart) string {
+	safe, isSafe := stringInterface(value).(SafeURL)
+	s := value.String()
+	switch {
+	case isSafe:
+		s = string(safe)
+	case part == urlQuery:
+		return percentEncode(s, isURLUnreserved)
+	case part == urlNone && !hasSafeScheme(s):
+		return "#" + unsafeReplacement
+	}
+	return percentEncode(s, func(b byte) bool {
+		return isURLUnreserved(b) || strings.IndexByte(":/?#[]@!$&'()*+,;=%", b) >= 0
+	})
+}
+
+// hasSafeScheme reports whether the URL has no scheme or a scheme that cannot
+// execute code.
+func hasSafeScheme(s string) bool {
+	i := strings.IndexByte(s, ':')
+	if i < 0 || strings.IndexByte(s[:i], '/') >= 0 {
+		return true
+	}
+	switch strings.ToLower(s[:i]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// percentEncode percent-encodes all bytes of s, which are not kept.
+func percentEncode(s string, keep func(b byte) bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if b := s[i]; keep(b) {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// attrTypeOf returns the type of the values of an attribute by its (lower
+// case) name.
+func attrTypeOf(name string) attrType {
+	name = strings.TrimPrefix(name, "data-")
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return attrJS
+	case name == "style":
+		return attrCSS
+	case urlAttrs[name], strings.Contains(name, "url"), strings.Contains(name, "uri"):
+		return attrURL
+	}
+	return attrPlain
+}
+
+// sameWriter reports whether two writers are the same.
+func sameWriter(a, b io.Writer) bool {
+	typ := reflect.TypeOf(a)
+	if typ != reflect.TypeOf(b) {
+		return false
+	}
+	// writers of uncomparable types cannot be told apart
+	return typ == nil || !typ.Comparable() || a == b
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+func isASCIILetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func isASCIIDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+func isURLUnreserved(b byte) bool {
+	return isASCIILetter(b) || isASCIIDigit(b) || b == '-' || b == '.' || b == '_' || b == '~'
+}
+
+func toLower(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
@@ -77,6 +78,31 @@ func (r *Renderer) Eval(node parse.Expression) Value {
 	return e.Eval(node)
 }
 
+// EvalCall evaluates the given call expression, passing the given keyword
+// arguments to the called function in addition to the ones of the expression.
+func (r *Renderer) EvalCall(node *parse.CallNode, kwargs ...KVPair) Value {
+	if debug.Enabled {
+		fm := debug.FuncMarker()
+		defer fm.End()
+	}
+	debug.Print("eval: %s", node.String())
+
+	e := r.Evaluator()
+	// enrich runtime errors with token position
+	defer func() {
+		if r := recover(); r != nil {
+			if rerr, ok := r.(errors.TemplateRuntimeError); ok {
+				rerr.Enrich(e.Current.Position().ErrorToken())
+				panic(rerr)
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	return e.evalCall(node, kwargs...)
+}
+
 func (e *Evaluator) Eval(node parse.Expression) Value {
 	if debug.Enabled {
 		fm := debug.FuncMarker()
@@ -181,6 +207,7 @@ func (e *Evaluator) evalBinary(node *parse.BinaryExpressionNode) Value {
 			return e.ValueFactory.Value(left.Float() * right.Float())
 		}
 		if left.IsString() {
+			e.ValueFactory.Sandbox().CheckRepeat(len(left.String()), right.Integer())
 			return e.ValueFactory.Value(strings.Repeat(left.String(), right.Integer()))
 		}
 		// Result will be int
@@ -281,7 +308,8 @@ func (e *Evaluator) evalList(node *parse.ListNode) Value {
 		values = append(values, value)
 	}
 	e.Current = node
-	return e.ValueFactory.Value(values)
+	// lists are passed by reference, so that modifications are shared
+	return e.ValueFactory.Value(&values)
 }
 
 func (e *Evaluator) evalTuple(node *parse.TupleNode) Value {
@@ -353,17 +381,117 @@ func (e *Evaluator) evalGetItem(node *parse.GetItemNode) Value {
 
 	value := e.Eval(node.Node)
 	e.Current = node
-	if node.Arg != "" {
-		item := value.GetItem(node.Arg)
+	var item Value
+	switch {
+	case node.IsSlice:
+		item = e.evalSlice(value, node)
+	case node.Key != nil:
+		key := e.Eval(node.Key)
 		e.Current = node
-		return item
+		switch {
+		case key.IsInteger():
+			item = value.GetItem(key.Integer())
+		case key.IsString():
+			item = value.GetItem(key.String())
+		default:
+			item = value.GetItem(key.Interface())
+		}
+	case node.Arg != "":
+		item = value.GetItem(node.Arg)
+	default:
+		item = value.GetItem(node.Index)
 	}
-	item := value.GetItem(node.Index)
 	e.Current = node
 	return item
 }
 
-func (e *Evaluator) evalCall(node *parse.CallNode) Value {
+// evalSlice slices a list or string like Python does with `value[start:stop:step]`.
+func (e *Evaluator) evalSlice(value Value, node *parse.GetItemNode) Value {
+	if !value.IsSliceable() {
+		// let the value decide how to handle the slicing (undefined, nil, ...)
+		return value.Slice(0, 0)
+	}
+
+	step := 1
+	if node.Step != nil {
+		if i, ok := e.evalSliceIndex(node.Step); ok {
+			step = i
+		}
+		if step == 0 {
+			errors.ThrowTemplateRuntimeError("slice step cannot be zero")
+		}
+	}
+
+	// compute the bounds the same way Python does
+	length := value.Len()
+	bound := func(expr parse.Expression, def int) int {
+		i, ok := e.evalSliceIndex(expr)
+		if !ok {
+			return def
+		}
+		if i < 0 {
+			i += length
+			if i < 0 {
+				if step < 0 {
+					return -1
+				}
+				return 0
+			}
+		} else if i >= length {
+			if step < 0 {
+				return length - 1
+			}
+			return length
+		}
+		return i
+	}
+	var start, stop int
+	if step > 0 {
+		start, stop = bound(node.Start, 0), bound(node.Stop, length)
+	} else {
+		start, stop = bound(node.Start, length-1), bound(node.Stop, -1)
+	}
+	e.Current = node
+
+	if step == 1 {
+		if stop < start {
+			stop = start
+		}
+		return value.Slice(start, stop)
+	}
+
+	if value.IsString() {
+		runes := []rune(value.String())
+		sliced := make([]rune, 0, len(runes))
+		for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+			sliced = append(sliced, runes[i])
+		}
+		return e.ValueFactory.Value(string(sliced))
+	}
+	sliced := ValuesList{}
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		sliced = append(sliced, value.Index(i))
+	}
+	return e.ValueFactory.Value(sliced)
+}
+
+// evalSliceIndex evaluates a slice index. If the expression is missing or
+// evaluates to none, false is returned.
+func (e *Evaluator) evalSliceIndex(expr parse.Expression) (int, bool) {
+	if expr == nil {
+		return 0, false
+	}
+	value := e.Eval(expr)
+	if value.IsNil() {
+		return 0, false
+	}
+	if !value.IsInteger() {
+		errors.ThrowTemplateRuntimeError("slice indices must be integers or none, not '%s'", value.String())
+	}
+	return value.Integer(), true
+}
+
+func (e *Evaluator) evalCall(node *parse.CallNode, kwargs ...KVPair) Value {
 	if debug.Enabled {
 		fm := debug.FuncMarker()
 		defer fm.End()
@@ -374,29 +502,26 @@ func (e *Evaluator) evalCall(node *parse.CallNode) Value {
 	if !fn.IsCallable() {
 		errors.ThrowTemplateRuntimeError("'%s' is not callable", fn.String())
 	}
+	fnName := fn.String()
+	switch n := node.Func.(type) {
+	case *parse.NameNode:
+		fnName = n.Name.Val
+	case *parse.GetItemNode:
+		if n.Arg != "" {
+			fnName = n.Arg
+		}
+	}
 
 	fnVal := indirectReflectValue(fn.ReflectValue())
 	fnType := fnVal.Type()
 	numParamsOut := fnType.NumOut()
 	if !(numParamsOut == 1 || numParamsOut == 2) {
-		fnName := ""
-		if nameNode, ok := node.Func.(*parse.NameNode); ok {
-			fnName = nameNode.Name.Val
-		} else {
-			fnName = fn.String()
-		}
 		errors.ThrowTemplateRuntimeError(
 			"function %s must have one (value) or two (value, error) return parameters, not %d",
 			fnName,
 			numParamsOut,
 		)
 	} else if numParamsOut == 2 && fnType.Out(1) != rtError {
-		fnName := ""
-		if nameNode, ok := node.Func.(*parse.NameNode); ok {
-			fnName = nameNode.Name.Val
-		} else {
-			fnName = fn.String()
-		}
 		errors.ThrowTemplateRuntimeError(
 			"function %s must have an error as second return parameter, not %s",
 			fnName,
@@ -406,31 +531,25 @@ func (e *Evaluator) evalCall(node *parse.CallNode) Value {
 
 	var params []reflect.Value
 	if fnType.NumIn() == 1 && fnType.In(0) == reflect.TypeOf(&VarArgs{}) {
-		params = e.evalVarArgs(node)
+		params = e.evalVarArgs(node, kwargs)
 	} else {
+		if len(kwargs) > 0 {
+			errors.ThrowTemplateRuntimeError("'%s' does not accept the keyword argument '%s'", fn.String(), kwargs[0].Key)
+		}
 		params = e.evalParams(node, fn)
 	}
 
-	// Call it and get first return parameter back
-	values := fnVal.Call(params)
+	// Call it and get first return parameter back; errors raised by the
+	// function point to the call
+	e.Current = node
+	values := callFunction(fnName, fnVal, params)
 	rv := values[0]
 	if numParamsOut == 2 {
-		e := values[1].Interface()
-		if e != nil {
-			err := e.(error)
-			if err != nil {
-				fnName := ""
-				if nameNode, ok := node.Func.(*parse.NameNode); ok {
-					fnName = nameNode.Name.Val
-				} else {
-					fnName = fn.String()
-				}
-				errors.ThrowTemplateRuntimeError(
-					"call of function %s failed: %s",
-					fnName,
-					err,
-				)
+		if err, _ := values[1].Interface().(error); err != nil {
+			if rerr, ok := err.(errors.TemplateRuntimeError); ok {
+				panic(rerr)
 			}
+			panic(errors.WrapTemplateRuntimeError(err, "call of function %s failed: %s", fnName, err))
 		}
 	}
 
@@ -440,7 +559,38 @@ func (e *Evaluator) evalCall(node *parse.CallNode) Value {
 	return e.ValueFactory.Value(rv.Interface())
 }
 
-func (e *Evaluator) evalVarArgs(node *parse.CallNode) []reflect.Value {
+// callFunction calls a Go function. Panics of the function are thrown as
+// runtime errors, so that they are reported with the position of the call.
+func callFunction(name string, fn reflect.Value, params []reflect.Value) []reflect.Value {
+	defer recoverFunction("function", name)
+	return fn.Call(params)
+}
+
+// throwError throws an error returned by a Go function provided by the user.
+// Template errors are thrown unchanged, others as runtime errors.
+func throwError(err error) {
+	if _, ok := err.(errors.TemplateError); ok {
+		panic(err)
+	}
+	panic(errors.WrapTemplateRuntimeError(err, "%s", err))
+}
+
+// recoverFunction converts a panic of a Go function provided by the user (a
+// function, filter or test) into a runtime error and throws it. Template errors
+// are passed on unchanged. It must be deferred.
+func recoverFunction(kind, name string) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+	if _, ok := rec.(errors.TemplateError); ok {
+		panic(rec)
+	}
+	err := errors.FromPanic(rec)
+	panic(errors.WrapTemplateRuntimeError(err, "%s %s panicked: %s", kind, name, err))
+}
+
+func (e *Evaluator) evalVarArgs(node *parse.CallNode, kwargs []KVPair) []reflect.Value {
 	if debug.Enabled {
 		fm := debug.FuncMarker()
 		defer fm.End()
@@ -454,14 +604,35 @@ func (e *Evaluator) evalVarArgs(node *parse.CallNode) []reflect.Value {
 		params.Args = append(params.Args, value)
 	}
 
-	for key, param := range node.Kwargs {
-		value := e.Eval(param)
+	for _, key := range kwargNames(node.Kwargs) {
+		value := e.Eval(node.Kwargs[key])
 		params.SetKwarg(key, value)
 	}
+	for _, kv := range kwargs {
+		params.SetKwarg(kv.Key, kv.Value)
+	}
 
 	return []reflect.Value{reflect.ValueOf(params)}
 }
 
+// kwargNames returns the names of the keyword arguments in the order they
+// appear in the source, so that e.g. `dict(b=1, a=2)` keeps the order of its
+// keys.
+func kwargNames(kwargs map[string]parse.Expression) []string {
+	names := make([]string, 0, len(kwargs))
+	for name := range kwargs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := kwargs[names[i]].Position(), kwargs[names[j]].Position()
+		if pi == nil || pj == nil || pi.Pos == pj.Pos {
+			return names[i] < names[j]
+		}
+		return pi.Pos < pj.Pos
+	})
+	return names
+}
+
 func (e *Evaluator) evalParams(node *parse.CallNode, fn Value) []reflect.Value {
 	if debug.Enabled {
 		fm := debug.FuncMarker()
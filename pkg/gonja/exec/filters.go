@@ -9,6 +9,23 @@ import (
 // FilterFunction is the type filter functions must fulfil
 type FilterFunction func(e *Evaluator, in Value, params *VarArgs) Value
 
+// FilterFunctionWithError is a filter function that returns an error instead
+// of throwing it. Use [FilterWithError] to register it.
+type FilterFunctionWithError func(e *Evaluator, in Value, params *VarArgs) (Value, error)
+
+// FilterWithError converts a [FilterFunctionWithError] into a
+// [FilterFunction]. Returned errors are reported as runtime errors with the
+// position of the filter call.
+func FilterWithError(fn FilterFunctionWithError) FilterFunction {
+	return func(e *Evaluator, in Value, params *VarArgs) Value {
+		out, err := fn(e, in, params)
+		if err != nil {
+			throwError(err)
+		}
+		return out
+	}
+}
+
 type FilterSet map[string]FilterFunction
 
 // Exists returns true if the given filter is already registered
@@ -67,8 +84,8 @@ func (e *Evaluator) ExecuteFilter(fc *parse.FilterCall, v Value) Value {
 		params.Args = append(params.Args, value)
 	}
 
-	for key, param := range fc.Kwargs {
-		value := e.Eval(param)
+	for _, key := range kwargNames(fc.Kwargs) {
+		value := e.Eval(fc.Kwargs[key])
 		params.SetKwarg(key, value)
 	}
 	return e.ExecuteFilterByName(fc.Name, v, params)
@@ -81,5 +98,6 @@ func (e *Evaluator) ExecuteFilterByName(name string, in Value, params *VarArgs)
 	}
 	fn := (*e.Filters)[name]
 
+	defer recoverFunction("filter", name)
 	return fn(e, in, params)
 }
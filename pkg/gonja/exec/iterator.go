@@ -0,0 +1,180 @@
+package exec
+
+import (
+	"reflect"
+)
+
+// Iterator is a pull-based iterator, e.g. a generator or a database cursor.
+// Values implementing Iterator are iterated lazily: items are pulled one at a
+// time and the iteration stops as soon as the template stops consuming items
+// (e.g. on `{% break %}`). Iterators can only be consumed once; filters like
+// `length` or `list` consume all remaining items.
+type Iterator interface {
+	// Next returns the next item. It returns false, if there are no more
+	// items.
+	Next() (item any, ok bool)
+}
+
+// Iterable is a lazy sequence that can be iterated multiple times, e.g. the
+// integers of `range`. A new [Iterator] is created for each iteration. If the
+// sequence has a `Len() int` method, its length is known without consuming
+// it.
+type Iterable interface {
+	Iterator() Iterator
+}
+
+// sized is implemented by lazy sequences with a known length.
+type sized interface {
+	Len() int
+}
+
+// container is implemented by lazy sequences, which check for an item without
+// being consumed.
+type container interface {
+	Contains(item any) bool
+}
+
+var (
+	rtIterator = reflect.TypeOf((*Iterator)(nil)).Elem()
+	rtIterable = reflect.TypeOf((*Iterable)(nil)).Elem()
+	rtBool     = reflect.TypeOf(false)
+)
+
+// isLazy reports whether the value is an [Iterator], an [Iterable] or a
+// function of the form of Go's `iter.Seq` or `iter.Seq2`.
+func (v *GenericValue) isLazy() bool {
+	val := v.concreteValue()
+	if !val.IsValid() || !val.CanInterface() {
+		return false
+	}
+	typ := val.Type()
+	return typ.Implements(rtIterator) || typ.Implements(rtIterable) || seqArity(typ) > 0
+}
+
+// concreteValue returns the underlying value with interfaces resolved, e.g.
+// for items of a `map[string]any`.
+func (v *GenericValue) concreteValue() reflect.Value {
+	val := v.Value
+	for val.IsValid() && val.Kind() == reflect.Interface && !val.IsNil() {
+		val = val.Elem()
+	}
+	return val
+}
+
+// seqArity returns 1 for functions of the form `func(yield func(V) bool)`
+// (iter.Seq), 2 for `func(yield func(K, V) bool)` (iter.Seq2) and 0 for all
+// other types.
+func seqArity(typ reflect.Type) int {
+	if typ.Kind() != reflect.Func || typ.NumIn() != 1 || typ.NumOut() != 0 {
+		return 0
+	}
+	yield := typ.In(0)
+	if yield.Kind() != reflect.Func || yield.NumOut() != 1 || !yield.Out(0).ConvertibleTo(rtBool) {
+		return 0
+	}
+	if n := yield.NumIn(); n == 1 || n == 2 {
+		return n
+	}
+	return 0
+}
+
+// lazyLen returns the length of a lazy sequence, if it is known without
+// consuming the sequence.
+func (v *GenericValue) lazyLen() (int, bool) {
+	if s, ok := v.concreteValue().Interface().(sized); ok {
+		return s.Len(), true
+	}
+	return 0, false
+}
+
+// lazyContains reports whether a lazy sequence contains the item. Sequences
+// without a `Contains(item any) bool` method are consumed until the item is
+// found.
+func (v *GenericValue) lazyContains(item Value) bool {
+	if c, ok := v.concreteValue().Interface().(container); ok {
+		return c.Contains(item.Interface())
+	}
+	found := false
+	v.iterateLazy(func(idx, count int, key, value Value) bool {
+		found = item.EqualValueTo(key)
+		return !found
+	}, func() {})
+	return found
+}
+
+// iterateLazy iterates through a lazy sequence. The count passed to fn is -1,
+// if the number of items is unknown.
+func (v *GenericValue) iterateLazy(fn func(idx, count int, key, value Value) bool, empty func()) {
+	idx := 0
+	count, ok := v.lazyLen()
+	if !ok {
+		count = -1
+	}
+	val := v.concreteValue()
+	it, isIterator := val.Interface().(Iterator)
+	if iterable, ok := val.Interface().(Iterable); ok && !isIterator {
+		it, isIterator = iterable.Iterator(), true
+	}
+	if isIterator {
+		for {
+			item, ok := it.Next()
+			if !ok {
+				break
+			}
+			cont := fn(idx, count, v.valueFactory.Value(item), nil)
+			idx++
+			if !cont {
+				return
+			}
+		}
+	} else {
+		yieldType := val.Type().In(0)
+		stopped := false
+		yield := reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+			// sequences must not yield after the iteration has been stopped,
+			// but be forgiving
+			cont := false
+			switch {
+			case stopped:
+			case len(args) == 2:
+				cont = fn(idx, count, v.valueFactory.Value(args[0]), v.valueFactory.Value(args[1]))
+			default:
+				cont = fn(idx, count, v.valueFactory.Value(args[0]), nil)
+			}
+			idx++
+			stopped = !cont
+			return []reflect.Value{reflect.ValueOf(cont).Convert(yieldType.Out(0))}
+		})
+		val.Call([]reflect.Value{yield})
+	}
+	if idx == 0 {
+		empty()
+	}
+}
+
+// materialize consumes a lazy sequence and returns its items as list or, for
+// sequences of key-value pairs, as dict.
+func (v *GenericValue) materialize() Value {
+	var (
+		items ValuesList
+		dict  *Dict
+	)
+	v.iterateLazy(func(idx, count int, key, value Value) bool {
+		if value == nil {
+			items = append(items, key)
+			return true
+		}
+		if dict == nil {
+			dict = NewDict()
+		}
+		dict.Pairs = append(dict.Pairs, &Pair{Key: key, Value: value})
+		return true
+	}, func() {})
+	if dict != nil {
+		return v.valueFactory.Value(dict)
+	}
+	if items == nil {
+		items = ValuesList{}
+	}
+	return v.valueFactory.Value(items)
+}
@@ -42,19 +42,45 @@ func (ms *MacroSet) Replace(name string, fn Macro) error {
 	return nil
 }
 
+// MacroNodeToFunc turns a macro definition into a callable function. The macro
+// body is rendered in the scope of the given renderer.
 func MacroNodeToFunc(node *parse.MacroNode, r *Renderer) Macro {
-	// Compute default values once
+	return macroFunc(node, r, evalMacroDefaults(node, r))
+}
+
+// evalMacroDefaults evaluates the default values of the macro arguments.
+func evalMacroDefaults(node *parse.MacroNode, r *Renderer) []*Kwarg {
 	defaultKwargs := []*Kwarg{}
 	for _, pair := range node.Kwargs {
 		key := r.Eval(pair.Key).String()
 		value := r.Eval(pair.Value)
 		defaultKwargs = append(defaultKwargs, &Kwarg{key, value.Interface()})
 	}
+	return defaultKwargs
+}
 
+func macroFunc(node *parse.MacroNode, r *Renderer, defaultKwargs []*Kwarg) Macro {
 	return func(params *VarArgs) Value {
+		r.enterMacro()
+		defer r.leaveMacro()
 		var out strings.Builder
 		sub := r.Inherit()
 		sub.Out = &out
+
+		// the body of a call block is passed as special `caller` argument
+		for idx, kv := range params.Kwargs {
+			if kv.Key != "caller" {
+				continue
+			}
+			if !node.Caller {
+				errors.ThrowTemplateRuntimeError("macro '%s' takes no keyword argument 'caller'", node.Name)
+			}
+			sub.Ctx.Set("caller", kv.Value)
+			kwargs := append([]KVPair{}, params.Kwargs[:idx]...)
+			params = NewVarArgsWithValues(params.ValueFactory, params.Args, append(kwargs, params.Kwargs[idx+1:]...))
+			break
+		}
+
 		p := params.Expect(len(node.Args), defaultKwargs)
 		if p.IsError() {
 			errors.ThrowTemplateAssertionError("wrong '%s' macro signature: %s", node.Name, p.Error())
@@ -67,9 +93,61 @@ func MacroNodeToFunc(node *parse.MacroNode, r *Renderer) Macro {
 		}
 		err := sub.ExecuteWrapper(node.Wrapper)
 		if err != nil {
-			// pass error up the call stack
+			// pass error up the call stack; the location of the call is set
+			// by the evaluator of the calling expression
+			if rerr, ok := err.(errors.TemplateRuntimeError); ok {
+				rerr.PushFrame("macro", node.Name, nil)
+			}
 			panic(err)
 		}
 		return r.ValueFactory.SafeValue(out.String())
 	}
 }
+
+// MacroValue is the value of a macro. Besides being callable, it exposes the
+// attributes `name`, `arguments`, `defaults` and `caller` like Jinja does.
+type MacroValue struct {
+	*GenericValue
+	node     *parse.MacroNode
+	defaults []*Kwarg
+}
+
+var _ Value = (*MacroValue)(nil)
+
+// NewMacroValue turns a macro definition into a callable value. The macro
+// body is rendered in the scope of the given renderer.
+func NewMacroValue(node *parse.MacroNode, r *Renderer) *MacroValue {
+	defaults := evalMacroDefaults(node, r)
+	return &MacroValue{
+		GenericValue: r.ValueFactory.Value(macroFunc(node, r, defaults)).(*GenericValue),
+		node:         node,
+		defaults:     defaults,
+	}
+}
+
+// GetItem returns the macro attribute with the given name.
+func (m *MacroValue) GetItem(key any) Value {
+	vf := m.valueFactory
+	switch key {
+	case "name":
+		return vf.Value(m.node.Name)
+	case "arguments":
+		arguments := make([]any, 0, len(m.node.Args)+len(m.defaults))
+		for _, arg := range m.node.Args {
+			arguments = append(arguments, arg)
+		}
+		for _, kwarg := range m.defaults {
+			arguments = append(arguments, kwarg.Name)
+		}
+		return vf.Value(arguments)
+	case "defaults":
+		defaults := make([]any, 0, len(m.defaults))
+		for _, kwarg := range m.defaults {
+			defaults = append(defaults, kwarg.Default)
+		}
+		return vf.Value(defaults)
+	case "caller":
+		return vf.Value(m.node.Caller)
+	}
+	return vf.NewUndefined(fmt.Sprintf("%v", key), "macro has no attribute '%v'", key)
+}
@@ -0,0 +1,163 @@
+package exec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// OrderedMap is a mapping that keeps the order of its keys, e.g. a map decoded
+// from JSON or YAML that preserves the order of the source. Ordered maps are
+// treated as dicts: they are iterated, printed and encoded as JSON in the
+// order of their keys. [Dict] is the ordered map used by dict literals and the
+// `dict()` function.
+type OrderedMap interface {
+	// Len returns the number of entries.
+	Len() int
+	// Range calls fn for every entry in order until fn returns false.
+	Range(fn func(key, value any) bool)
+	// Load returns the value for the given key.
+	Load(key any) (value any, ok bool)
+	// Store sets the value for the given key. New keys are appended.
+	Store(key, value any)
+	// Delete removes the given key and reports whether it was present.
+	Delete(key any) bool
+}
+
+var _ OrderedMap = (*Dict)(nil)
+
+// orderedMap returns the underlying value as [OrderedMap], if it is one.
+func (v *GenericValue) orderedMap() (OrderedMap, bool) {
+	val := v.concreteValue()
+	if !val.IsValid() || !val.CanInterface() {
+		return nil, false
+	}
+	m, ok := val.Interface().(OrderedMap)
+	return m, ok
+}
+
+// mapEntry returns a key or value as it is stored in m. A [Dict] holds
+// [Value]s, other ordered maps hold plain values.
+func mapEntry(m OrderedMap, value Value) any {
+	if _, ok := m.(*Dict); ok {
+		return value
+	}
+	return value.Interface()
+}
+
+// orderedPairs returns the entries of m as [Pair]s.
+func (v *GenericValue) orderedPairs(m OrderedMap) []*Pair {
+	pairs := make([]*Pair, 0, m.Len())
+	m.Range(func(key, value any) bool {
+		pairs = append(pairs, &Pair{Key: v.valueFactory.Value(key), Value: v.valueFactory.Value(value)})
+		return true
+	})
+	return pairs
+}
+
+// -----------------------------------------------------------------------------
+// JSON
+// -----------------------------------------------------------------------------
+
+// JSONValue prepares a value for encoding with the `encoding/json` package:
+// [Value]s are unwrapped, also within lists and maps, lazy sequences are
+// materialized and ordered maps are encoded as JSON objects that keep the
+// order of their keys.
+func JSONValue(value any) any {
+	switch v := value.(type) {
+	case Value:
+		if v.IsNil() {
+			return nil
+		}
+		// lazy sequences are written as lists
+		if gv, ok := v.(*GenericValue); ok && gv.isLazy() {
+			return JSONValue(gv.materialize())
+		}
+		return JSONValue(v.Interface())
+	case OrderedMap:
+		return orderedJSON{v}
+	case Iterable:
+		return JSONValue(v.Iterator())
+	case Iterator:
+		items := []any{}
+		for item, ok := v.Next(); ok; item, ok = v.Next() {
+			items = append(items, JSONValue(item))
+		}
+		return items
+	case *ValuesList:
+		return JSONValue(*v)
+	case json.Marshaler:
+		return value
+	}
+
+	// lists and maps may hold values as well, e.g. `[]Value`
+	rv := reflect.ValueOf(value)
+	switch {
+	case !rv.IsValid():
+		return value
+	case (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return value
+		}
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = JSONValue(rv.Index(i).Interface())
+		}
+		return items
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		if rv.IsNil() {
+			return value
+		}
+		items := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			items[iter.Key().String()] = JSONValue(iter.Value().Interface())
+		}
+		return items
+	}
+	return value
+}
+
+// orderedJSON encodes an ordered map as JSON object.
+type orderedJSON struct {
+	m OrderedMap
+}
+
+func (o orderedJSON) MarshalJSON() ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	buf.WriteByte('{')
+	o.m.Range(func(key, value any) bool {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		var b []byte
+		if b, err = json.Marshal(jsonKey(key)); err != nil {
+			return false
+		}
+		buf.Write(b)
+		buf.WriteByte(':')
+		if b, err = json.Marshal(JSONValue(value)); err != nil {
+			return false
+		}
+		buf.Write(b)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonKey returns the key of a JSON object. Keys that aren't strings are
+// formatted.
+func jsonKey(key any) string {
+	if k, ok := key.(Value); ok {
+		return k.String()
+	}
+	return fmt.Sprint(key)
+}
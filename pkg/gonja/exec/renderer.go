@@ -1,7 +1,9 @@
 package exec
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	debug "github.com/aisbergg/gonja/internal/debug/exec"
@@ -31,6 +33,32 @@ func (ts *TrimState) TrimBlocks(r rune) bool {
 	return false
 }
 
+// renderState holds the state of a single render process. It is shared between
+// a renderer and all of its sub renderers.
+type renderState struct {
+	ctx            context.Context
+	loopIterations int
+	macroDepth     int
+	includeDepth   int
+	// out is the final writer of the render process
+	out io.Writer
+}
+
+// extendsState holds the parent of a template set by a dynamic `extends`.
+type extendsState struct {
+	parent *parse.TemplateNode
+	// token is the location of the `extends` statement
+	token *errors.Token
+	// discard is true, if the output is discarded, because an enclosing
+	// template has been extended
+	discard bool
+}
+
+// discarding reports whether the output of the template is discarded.
+func (es *extendsState) discarding() bool {
+	return es.discard || es.parent != nil
+}
+
 // Renderer is a node visitor in charge of rendering a template.
 type Renderer struct {
 	*EvalConfig
@@ -39,12 +67,15 @@ type Renderer struct {
 	Template     *Template
 	Root         *parse.TemplateNode
 	Current      parse.Node
-	Out          *strings.Builder
+	Out          io.Writer
 	Trim         *TrimState
+	state        *renderState
+	extends      *extendsState
+	output       *outputContext
 }
 
 // NewRenderer initialize a new renderer
-func NewRenderer(ctx *Context, valueVactory *ValueFactory, out *strings.Builder, cfg *EvalConfig, tpl *Template) *Renderer {
+func NewRenderer(ctx *Context, valueVactory *ValueFactory, out io.Writer, cfg *EvalConfig, tpl *Template) *Renderer {
 	var buffer strings.Builder
 	r := &Renderer{
 		EvalConfig:   cfg,
@@ -54,6 +85,9 @@ func NewRenderer(ctx *Context, valueVactory *ValueFactory, out *strings.Builder,
 		Root:         tpl.Root,
 		Out:          out,
 		Trim:         &TrimState{Buffer: &buffer},
+		state:        &renderState{ctx: context.Background(), out: out},
+		extends:      &extendsState{},
+		output:       &outputContext{out: out},
 	}
 	r.Ctx.Set("self", Self(r))
 	return r
@@ -70,10 +104,61 @@ func (r *Renderer) Inherit() *Renderer {
 		Root:         r.Root,
 		Out:          r.Out,
 		Trim:         r.Trim,
+		state:        r.state,
+		extends:      r.extends,
+		output:       r.outputContext(),
 	}
 	return sub
 }
 
+// CheckContext throws an error, if the context of the render process was
+// canceled or its deadline exceeded.
+func (r *Renderer) CheckContext() {
+	if err := r.state.ctx.Err(); err != nil {
+		errors.ThrowContextError(err)
+	}
+}
+
+// CountLoopIteration counts a loop iteration towards the loop iteration limit
+// and checks the context of the render process.
+func (r *Renderer) CountLoopIteration() {
+	r.CheckContext()
+	r.state.loopIterations++
+	if r.MaxLoopIterations > 0 && r.state.loopIterations > r.MaxLoopIterations {
+		errors.ThrowLimitExceededError("loop iterations", r.MaxLoopIterations)
+	}
+}
+
+// EnterInclude increases the include depth and checks it against the include
+// depth limit. Each call must be followed by a call to LeaveInclude.
+func (r *Renderer) EnterInclude() {
+	r.state.includeDepth++
+	if r.MaxIncludeDepth > 0 && r.state.includeDepth > r.MaxIncludeDepth {
+		r.state.includeDepth--
+		errors.ThrowLimitExceededError("include depth", r.MaxIncludeDepth)
+	}
+}
+
+// LeaveInclude decreases the include depth.
+func (r *Renderer) LeaveInclude() {
+	r.state.includeDepth--
+}
+
+// enterMacro increases the macro depth and checks it against the macro depth
+// limit. Each call must be followed by a call to leaveMacro.
+func (r *Renderer) enterMacro() {
+	r.state.macroDepth++
+	if r.MaxMacroDepth > 0 && r.state.macroDepth > r.MaxMacroDepth {
+		r.state.macroDepth--
+		errors.ThrowLimitExceededError("macro recursion depth", r.MaxMacroDepth)
+	}
+}
+
+// leaveMacro decreases the macro depth.
+func (r *Renderer) leaveMacro() {
+	r.state.macroDepth--
+}
+
 // Flush flushes the contents of the buffer to the final output.
 func (r *Renderer) Flush(lstrip bool) {
 	r.FlushAndTrim(false, lstrip)
@@ -92,13 +177,71 @@ func (r *Renderer) FlushAndTrim(trim, lstrip bool) {
 	if trim {
 		txt = strings.TrimRight(txt, " \t\n")
 	}
-	r.Out.WriteString(txt)
 	r.Trim.Buffer.Reset()
+	if len(txt) == 0 {
+		return
+	}
+	r.checkBufferSize(len(txt))
+	if _, err := io.WriteString(r.Out, txt); err != nil {
+		if rerr, ok := err.(errors.TemplateRuntimeError); ok {
+			panic(rerr)
+		}
+		errors.ThrowTemplateRuntimeError("unable to write output: %s", err)
+	}
+}
+
+// checkBufferSize checks the output size limit before n bytes are written to
+// the buffer of a sub renderer (e.g. of a macro or a `filter` block). The
+// final writer is limited separately, so that only the bytes reaching it count
+// towards the limit.
+func (r *Renderer) checkBufferSize(n int) {
+	if r.MaxOutputSize <= 0 || sameWriter(r.Out, r.state.out) {
+		return
+	}
+	if buf, ok := r.Out.(interface{ Len() int }); ok && buf.Len()+n > r.MaxOutputSize {
+		errors.ThrowLimitExceededError("output size", r.MaxOutputSize)
+	}
+}
+
+// LoadTemplate loads a template referenced by the template being rendered. The
+// name is joined with the name of the template containing the current node
+// first (see [EvalConfig.JoinPath]).
+func (r *Renderer) LoadTemplate(name string) (*Template, error) {
+	return r.TemplateLoadFn(r.joinPath(name, r.currentTemplateName()))
+}
+
+// currentTemplateName returns the name of the template the current node belongs
+// to. Parent templates and statically included templates are rendered with
+// the [Template] of the outermost template, so the name is taken from the
+// location of the node.
+func (r *Renderer) currentTemplateName() string {
+	if r.Current != nil {
+		if tk := r.Current.Position(); tk != nil && tk.Template != "" {
+			return tk.Template
+		}
+	}
+	return r.Root.Name
+}
+
+// Extend sets the parent of the template being rendered (dynamic `extends`).
+// The remaining output of the template is discarded and the parent template is
+// rendered instead, using the blocks of the template.
+func (r *Renderer) Extend(parent *parse.TemplateNode) {
+	if r.extends.parent != nil {
+		errors.ThrowTemplateRuntimeError("the template can only be extended once")
+	}
+	r.extends.parent = parent
+	if r.Current != nil {
+		r.extends.token = r.Current.Position().ErrorToken()
+	}
 }
 
 // WriteString applies the trim policy on the given string and writes it to the
 // buffer.
 func (r *Renderer) WriteString(txt string) int {
+	if r.extends.discarding() {
+		return 0
+	}
 	if r.TrimBlocks {
 		txt = strings.TrimLeftFunc(txt, r.Trim.TrimBlocks)
 	}
@@ -108,6 +251,9 @@ func (r *Renderer) WriteString(txt string) int {
 			r.Trim.Should = false
 		}
 	}
+	if r.ContextualAutoescape {
+		r.outputContext().feed(txt)
+	}
 	l, err := r.Trim.Buffer.WriteString(txt)
 	if err != nil {
 		errors.ThrowTemplateRuntimeError("unable to write to buffer: %s", err)
@@ -117,11 +263,31 @@ func (r *Renderer) WriteString(txt string) int {
 
 // RenderValue renders a single value.
 func (r *Renderer) RenderValue(value Value) {
-	if r.Autoescape && value.IsString() && !value.IsSafe() {
-		r.WriteString(value.Escaped())
-	} else {
-		r.WriteString(value.String())
+	r.WriteString(r.Escape(value))
+}
+
+// Escape returns the string of a value escaped according to the autoescape
+// configuration. With contextual autoescaping, the HTML escaper is chosen by
+// the context of the output written so far.
+func (r *Renderer) Escape(value Value) string {
+	switch {
+	case !r.Autoescape:
+		return value.String()
+	case r.Escaper != nil:
+		return r.Escaper(value)
+	case r.ContextualAutoescape:
+		return r.outputContext().escape(value)
+	}
+	return EscapeHTML(value)
+}
+
+// outputContext returns the context of the output of the renderer. Sub
+// renderers writing to another writer continue with a copy of the context.
+func (r *Renderer) outputContext() *outputContext {
+	if !sameWriter(r.output.out, r.Out) {
+		r.output = r.output.clone(r.Out)
 	}
+	return r.output
 }
 
 func (r *Renderer) StartTag(trim *parse.Trim, lstrip bool) {
@@ -155,6 +321,7 @@ func (r *Renderer) walk(node parse.Node) {
 	debug.Print("exec: %s", node.String())
 
 	r.Current = node
+	r.CheckContext()
 	switch n := node.(type) {
 	case *parse.DataNode:
 		r.WriteString(n.Data.Val)
@@ -169,8 +336,13 @@ func (r *Renderer) walk(node parse.Node) {
 		r.Tag(n.Trim, n.LStrip)
 		r.Trim.ShouldBlock = r.TrimBlocks
 		// only execute executable statements, skip others
-		if stmt, ok := n.Stmt.(Statement); ok {
+		switch stmt := n.Stmt.(type) {
+		case Statement:
 			stmt.Execute(r, n)
+		case StatementWithError:
+			if err := stmt.ExecuteWithError(r, n); err != nil {
+				throwError(err)
+			}
 		}
 
 	case *parse.CommentNode:
@@ -230,6 +402,16 @@ func (r *Renderer) Execute() (err error) {
 		}
 	}()
 
+	// output of included templates is discarded, if the including template
+	// has been extended
+	r.extends = &extendsState{discard: r.extends.discarding()}
+
+	// select the autoescaping of the template by its name
+	if r.AutoescapeFn != nil {
+		r.EvalConfig = r.EvalConfig.Inherit()
+		r.Autoescape, r.Escaper = r.AutoescapeFn(r.Root.Name)
+	}
+
 	// Determine the parent to be executed (for template inheritance)
 	root := r.Root
 	for root.Parent != nil {
@@ -237,14 +419,98 @@ func (r *Renderer) Execute() (err error) {
 	}
 	r.walk(root)
 	r.Flush(false)
+
+	// render the parent set by a dynamic extends
+	if parent := r.extends.parent; parent != nil {
+		sub := r.Inherit()
+		sub.Root = withParent(r.Root, parent)
+		sub.extends = &extendsState{discard: r.extends.discard}
+		sub.Ctx.Set("self", Self(sub))
+		if err := sub.Execute(); err != nil {
+			if rerr, ok := err.(errors.TemplateRuntimeError); ok {
+				rerr.PushFrame("extends", parent.Name, r.extends.token)
+			}
+			return err
+		}
+	}
 	return nil
 }
 
+// withParent returns a copy of the template with the given parent appended to
+// its chain of parent templates.
+func withParent(tpl, parent *parse.TemplateNode) *parse.TemplateNode {
+	cpy := *tpl
+	if tpl.Parent == nil {
+		cpy.Parent = parent
+	} else {
+		cpy.Parent = withParent(tpl.Parent, parent)
+	}
+	return &cpy
+}
+
+// String flushes the buffer and returns the rendered output, if the output
+// writer provides it (e.g. a strings.Builder). Otherwise an empty string is
+// returned.
 func (r *Renderer) String() string {
 	r.Flush(false)
-	out := r.Out.String()
+	stringer, ok := r.Out.(fmt.Stringer)
+	if !ok {
+		return ""
+	}
+	out := stringer.String()
 	if !r.KeepTrailingNewline {
 		out = strings.TrimSuffix(out, "\n")
 	}
 	return out
 }
+
+// -----------------------------------------------------------------------------
+
+// trailingNewlineWriter is a writer that holds back a trailing newline until
+// further output is written. This allows to drop the final newline of the
+// rendered output while streaming it.
+type trailingNewlineWriter struct {
+	w       io.Writer
+	pending bool
+}
+
+// Write writes p to the underlying writer, except for a trailing newline.
+func (tw *trailingNewlineWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if tw.pending {
+		if _, err := tw.w.Write([]byte{'\n'}); err != nil {
+			return 0, err
+		}
+		tw.pending = false
+	}
+	n := len(p)
+	if p[n-1] == '\n' {
+		tw.pending = true
+		n--
+	}
+	written, err := tw.w.Write(p[:n])
+	if err != nil {
+		return written, err
+	}
+	return len(p), nil
+}
+
+// limitWriter is a writer that fails with a LimitExceededError, once more than
+// max bytes are written.
+type limitWriter struct {
+	w       io.Writer
+	max     int
+	written int
+}
+
+// Write writes p to the underlying writer, unless the limit is exceeded.
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if lw.written+len(p) > lw.max {
+		return 0, errors.NewLimitExceededError("output size", lw.max)
+	}
+	n, err := lw.w.Write(p)
+	lw.written += n
+	return n, err
+}
@@ -0,0 +1,147 @@
+package exec
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+)
+
+const (
+	// DefaultMaxRange is the default maximum number of items `range()` may
+	// produce in a sandboxed environment.
+	DefaultMaxRange = 100000
+
+	// DefaultMaxRepeatLength is the default maximum length of a string created
+	// by repetition (`'ab' * 3`) in a sandboxed environment.
+	DefaultMaxRepeatLength = 100000
+)
+
+// gonjaPkgPath is the path prefix of the packages that make up gonja.
+const gonjaPkgPath = "github.com/aisbergg/gonja/pkg/gonja/"
+
+// SandboxPolicy decides which Go values and which of their fields and methods
+// are reachable from within a sandboxed template. You can embed
+// [DefaultSandboxPolicy] in your own policy and only override the decisions
+// you want to change.
+type SandboxPolicy interface {
+	// IsSafeType reports whether the fields, methods and items of values of
+	// the given type may be accessed.
+	IsSafeType(typ reflect.Type) bool
+
+	// IsSafeField reports whether the struct field with the given name of the
+	// given type may be accessed.
+	IsSafeField(typ reflect.Type, name string) bool
+
+	// IsSafeMethod reports whether the method with the given name of the given
+	// type may be accessed and called.
+	IsSafeMethod(typ reflect.Type, name string) bool
+}
+
+// DefaultSandboxPolicy is the policy used by sandboxed environments, if no
+// other policy is given. It allows access to all types and exported struct
+// fields, but denies access to all methods of Go values.
+type DefaultSandboxPolicy struct{}
+
+var _ SandboxPolicy = DefaultSandboxPolicy{}
+
+// IsSafeType allows access to all types.
+func (DefaultSandboxPolicy) IsSafeType(typ reflect.Type) bool { return true }
+
+// IsSafeField allows access to all exported struct fields.
+func (DefaultSandboxPolicy) IsSafeField(typ reflect.Type, name string) bool { return true }
+
+// IsSafeMethod denies access to all methods.
+func (DefaultSandboxPolicy) IsSafeMethod(typ reflect.Type, name string) bool { return false }
+
+// Sandbox is the configuration of a sandboxed environment. The types of gonja
+// itself (e.g. the loop variable) are always accessible.
+type Sandbox struct {
+	// Policy decides which types, fields and methods are accessible.
+	Policy SandboxPolicy
+
+	// MaxRange is the maximum number of items `range()` may produce. Zero
+	// means no limit.
+	MaxRange int
+
+	// MaxRepeatLength is the maximum length of a string created by repetition
+	// (`'ab' * 3`) or by string methods growing a string, e.g. `center` or
+	// `replace`. Zero means no limit.
+	MaxRepeatLength int
+}
+
+// NewSandbox creates a new sandbox configuration with the given policy and
+// the default limits. If policy is nil, the [DefaultSandboxPolicy] is used.
+func NewSandbox(policy SandboxPolicy) *Sandbox {
+	if policy == nil {
+		policy = DefaultSandboxPolicy{}
+	}
+	return &Sandbox{
+		Policy:          policy,
+		MaxRange:        DefaultMaxRange,
+		MaxRepeatLength: DefaultMaxRepeatLength,
+	}
+}
+
+// CheckType throws an UnsafeOperationError, if the values of the given type
+// may not be accessed.
+func (sb *Sandbox) CheckType(typ reflect.Type) {
+	if sb == nil || isGonjaType(typ) {
+		return
+	}
+	if !sb.Policy.IsSafeType(typ) {
+		errors.ThrowUnsafeOperationError("access to values of type %s is unsafe", typ)
+	}
+}
+
+// CheckField throws an UnsafeOperationError, if the field of the given type
+// may not be accessed.
+func (sb *Sandbox) CheckField(typ reflect.Type, name string) {
+	if sb == nil || isGonjaType(typ) {
+		return
+	}
+	if !sb.Policy.IsSafeField(typ, name) {
+		errors.ThrowUnsafeOperationError("access to field '%s' of type %s is unsafe", name, typ)
+	}
+}
+
+// CheckMethod throws an UnsafeOperationError, if the method of the given type
+// may not be accessed.
+func (sb *Sandbox) CheckMethod(typ reflect.Type, name string) {
+	if sb == nil || isGonjaType(typ) {
+		return
+	}
+	if !sb.Policy.IsSafeMethod(typ, name) {
+		errors.ThrowUnsafeOperationError("access to method '%s' of type %s is unsafe", name, typ)
+	}
+}
+
+// CheckRange throws an UnsafeOperationError, if a range with the given number
+// of items exceeds the range limit.
+func (sb *Sandbox) CheckRange(count int) {
+	if sb == nil || sb.MaxRange <= 0 {
+		return
+	}
+	if count > sb.MaxRange {
+		errors.ThrowUnsafeOperationError("range too big, the sandbox allows at most %d items", sb.MaxRange)
+	}
+}
+
+// CheckRepeat throws an UnsafeOperationError, if a string repetition results
+// in a string exceeding the repeat limit.
+func (sb *Sandbox) CheckRepeat(length, times int) {
+	if sb == nil || sb.MaxRepeatLength <= 0 || times <= 0 {
+		return
+	}
+	if length > sb.MaxRepeatLength/times {
+		errors.ThrowUnsafeOperationError("string repetition too big, the sandbox allows at most %d characters", sb.MaxRepeatLength)
+	}
+}
+
+// isGonjaType reports whether the given type is defined by gonja itself.
+func isGonjaType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return strings.HasPrefix(typ.PkgPath(), gonjaPkgPath)
+}
@@ -20,6 +20,7 @@ func getBlocks(tpl *parse.TemplateNode) map[string]*parse.WrapperNode {
 func Self(r *Renderer) map[string]func() (string, error) {
 	blocks := map[string]func() (string, error){}
 	for name, block := range getBlocks(r.Root) {
+		block := block
 		blocks[name] = func() (string, error) {
 			sub := r.Inherit()
 			var out strings.Builder
@@ -50,6 +50,14 @@ type Statement interface {
 	Execute(*Renderer, *parse.StatementBlockNode)
 }
 
+// StatementWithError is a statement that returns an error instead of throwing
+// it. Returned errors are reported as runtime errors with the position of the
+// statement.
+type StatementWithError interface {
+	parse.Statement
+	ExecuteWithError(*Renderer, *parse.StatementBlockNode) error
+}
+
 type StatementSet map[string]parse.StatementParser
 
 // Exists returns true if the given test is already registered
@@ -2,16 +2,39 @@ package exec
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/aisbergg/gonja/pkg/gonja/errors"
 	"github.com/aisbergg/gonja/pkg/gonja/parse"
 )
 
+// Names of the templates created from strings and bytes.
+const (
+	StringTemplateName = "string"
+	BytesTemplateName  = "bytes"
+)
+
 // TemplateLoadFn is a function that loads a template by name.
 type TemplateLoadFn func(name string) (*Template, error)
 
+// JoinPathFn joins the name of a referenced template with the name of the
+// template referencing it and returns the name to load the template by.
+type JoinPathFn func(name, parent string) string
+
+// JoinPathRelative is a [JoinPathFn] that resolves names starting with `./` or
+// `../` relative to the directory of the referencing template. Other names are
+// returned unchanged.
+func JoinPathRelative(name, parent string) string {
+	if !strings.HasPrefix(name, "./") && !strings.HasPrefix(name, "../") {
+		return name
+	}
+	return path.Join(path.Dir(filepath.ToSlash(parent)), name)
+}
+
 // TemplateLoader is an interface for loading templates by name.
 type TemplateLoader interface {
 	GetTemplate(string) (*Template, error)
@@ -20,6 +43,8 @@ type TemplateLoader interface {
 // Template is the central template object. It represents a parsed template and
 // is used to evaluate it.
 type Template struct {
+	// Name is the name the template has been loaded by.
+	Name   string
 	Reader io.Reader
 	Source string
 
@@ -32,15 +57,22 @@ type Template struct {
 
 	Root   *parse.TemplateNode
 	Macros MacroSet
+
+	// Dependencies are the names of the templates loaded while parsing the
+	// template, e.g. the parent template or statically included templates.
+	Dependencies []string
 }
 
-// NewTemplate creates a new template.
-func NewTemplate(name, source string, cfg *EvalConfig) (*Template, error) {
+// NewTemplate creates a new template. Panics while parsing (e.g. of statement
+// parsers provided by the user) are returned as syntax errors.
+func NewTemplate(name, source string, cfg *EvalConfig) (tpl *Template, err error) {
+	name = cfg.NamePrefix + name
 	// Create the template
 	t := &Template{
+		Name:   name,
 		Env:    cfg,
 		Source: source,
-		Tokens: parse.Lex(source, cfg.Config),
+		Tokens: parse.LexTemplate(name, source, cfg.Config),
 	}
 
 	// Parse it
@@ -53,34 +85,101 @@ func NewTemplate(name, source string, cfg *EvalConfig) (*Template, error) {
 	// os.Exit(0)
 
 	t.Parser.Statements = *t.Env.Statements
-	t.Parser.TemplateParseFn = cfg.templateParseFn
+	t.Parser.TemplateParseFn = func(filename string) (*parse.TemplateNode, error) {
+		filename = cfg.joinPath(filename, name)
+		t.Dependencies = append(t.Dependencies, filename)
+		tpl, err := cfg.TemplateLoadFn(filename)
+		if err != nil {
+			return nil, err
+		}
+		return tpl.Root, nil
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			tpl = nil
+			err = errors.FromPanic(rec)
+			if _, ok := err.(errors.TemplateError); !ok {
+				var token *errors.Token
+				if current := t.Parser.Current(); current != nil {
+					token = current.ErrorToken()
+				}
+				err = errors.WrapSyntaxError(err, token, "%s", err)
+			}
+		}
+		if err != nil {
+			errors.AttachSources(err, func(template string) (string, bool) {
+				return source, template == name
+			})
+		}
+	}()
 	root, err := t.Parser.Parse()
 	if err != nil {
 		return nil, err
 	}
+	root.Name = name
 	t.Root = root
 
 	return t, nil
 }
 
 // execute executes the template with the given context and writes the rendered
-// template to out.
-func (tpl *Template) execute(ctx any, out io.StringWriter) (err error) {
+// template to out. The output is streamed into out while rendering.
+func (tpl *Template) execute(ctx context.Context, data any, out io.Writer) (err error) {
 	valueFactory := NewValueFactory(tpl.Env.Undefined, tpl.Env.CustomTypes)
-	rootCtx := NewContext(tpl.Env.Globals, ctx, valueFactory)
+	valueFactory.sandbox = tpl.Env.Sandbox
+	// the root context caches resolved values, so the globals are copied to
+	// keep them from leaking into subsequent executions
+	globals := make(map[string]any, len(tpl.Env.Globals))
+	for name, value := range tpl.Env.Globals {
+		globals[name] = value
+	}
+	rootCtx := NewContext(globals, data, valueFactory)
 	excCtx := rootCtx.Inherit()
 
-	var builder strings.Builder
-	renderer := NewRenderer(excCtx, valueFactory, &builder, tpl.Env, tpl)
+	if tpl.Env.MaxOutputSize > 0 {
+		out = &limitWriter{w: out, max: tpl.Env.MaxOutputSize}
+	}
+	if !tpl.Env.KeepTrailingNewline {
+		out = &trailingNewlineWriter{w: out}
+	}
+	renderer := NewRenderer(excCtx, valueFactory, out, tpl.Env, tpl)
+	renderer.state.ctx = ctx
+
+	// runtime errors are returned by the renderer, other panics are converted
+	// here, so that no panic escapes the execution
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = errors.FromPanic(rec)
+			if _, ok := err.(errors.TemplateError); !ok {
+				rerr := errors.WrapTemplateRuntimeError(err, "%s", err)
+				if renderer.Current != nil {
+					rerr.Enrich(renderer.Current.Position().ErrorToken())
+				}
+				err = rerr
+			}
+		}
+		if err != nil {
+			errors.AttachSources(err, tpl.source)
+		}
+	}()
+	return renderer.Execute()
+}
 
-	err = renderer.Execute()
-	if err != nil {
-		return err
+// source returns the source of the template or of a template it depends on.
+// It is used to attach source excerpts to errors.
+func (tpl *Template) source(name string) (string, bool) {
+	if name == tpl.Name {
+		return tpl.Source, tpl.Source != ""
 	}
-	if _, err = out.WriteString(renderer.String()); err != nil {
-		return errors.NewTemplateRuntimeError("failed to write out template: %s", err)
+	if tpl.Env.TemplateLoadFn == nil {
+		return "", false
 	}
-	return nil
+	dependency, err := tpl.Env.TemplateLoadFn(name)
+	if err != nil {
+		return "", false
+	}
+	return dependency.Source, dependency.Source != ""
 }
 
 // newBufferAndExecute executes the template with the given context and returns
@@ -90,7 +189,7 @@ func (tpl *Template) newBufferAndExecute(ctx map[string]any) (*bytes.Buffer, err
 	// Create output buffer
 	// We assume that the rendered template will be 30% larger
 	// buffer := bytes.NewBuffer(make([]byte, 0, int(float64(tpl.size)*1.3)))
-	if err := tpl.execute(ctx, &buffer); err != nil {
+	if err := tpl.execute(context.Background(), ctx, &buffer); err != nil {
 		return nil, err
 	}
 	return &buffer, nil
@@ -109,8 +208,15 @@ func (tpl *Template) ExecuteBytes(ctx map[string]any) ([]byte, error) {
 // Execute executes the template with the given context and returns the rendered
 // template as a string.
 func (tpl *Template) Execute(ctx any) (string, error) {
+	return tpl.ExecuteContext(context.Background(), ctx)
+}
+
+// ExecuteContext executes the template with the given data and returns the
+// rendered template as a string. The rendering is aborted with an
+// [errors.ContextError], if the context is canceled or its deadline exceeded.
+func (tpl *Template) ExecuteContext(ctx context.Context, data any) (string, error) {
 	var b strings.Builder
-	err := tpl.execute(ctx, &b)
+	err := tpl.execute(ctx, data, &b)
 	if err != nil {
 		return "", err
 	}
@@ -118,6 +224,20 @@ func (tpl *Template) Execute(ctx any) (string, error) {
 	return b.String(), nil
 }
 
+// ExecuteTo executes the template with the given context and writes the
+// rendered template to w. The output is written incrementally while rendering,
+// so that large outputs don't need to be held in memory. If an error occurs,
+// parts of the output might already be written to w.
+func (tpl *Template) ExecuteTo(w io.Writer, ctx any) error {
+	return tpl.execute(context.Background(), ctx, w)
+}
+
+// ExecuteToContext is like ExecuteTo, but aborts the rendering with an
+// [errors.ContextError], if the context is canceled or its deadline exceeded.
+func (tpl *Template) ExecuteToContext(ctx context.Context, w io.Writer, data any) error {
+	return tpl.execute(ctx, data, w)
+}
+
 // Render is a alias for Execute.
 func (tpl *Template) Render(ctx any) (string, error) {
 	return tpl.Execute(ctx)
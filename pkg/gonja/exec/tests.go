@@ -8,6 +8,23 @@ import (
 // TestFunction is the type test functions must fulfil
 type TestFunction func(*Context, Value, *VarArgs) bool
 
+// TestFunctionWithError is a test function that returns an error instead of
+// throwing it. Use [TestWithError] to register it.
+type TestFunctionWithError func(*Context, Value, *VarArgs) (bool, error)
+
+// TestWithError converts a [TestFunctionWithError] into a [TestFunction].
+// Returned errors are reported as runtime errors with the position of the
+// test.
+func TestWithError(fn TestFunctionWithError) TestFunction {
+	return func(ctx *Context, in Value, params *VarArgs) bool {
+		result, err := fn(ctx, in, params)
+		if err != nil {
+			throwError(err)
+		}
+		return result
+	}
+}
+
 // TestSet maps test names to their TestFunction handler
 type TestSet map[string]TestFunction
 
@@ -62,8 +79,8 @@ func (e *Evaluator) ExecuteTest(tc *parse.TestCall, v Value) Value {
 		params.Args = append(params.Args, value)
 	}
 
-	for key, param := range tc.Kwargs {
-		value := e.Eval(param)
+	for _, key := range kwargNames(tc.Kwargs) {
+		value := e.Eval(tc.Kwargs[key])
 		params.SetKwarg(key, value)
 	}
 
@@ -75,6 +92,12 @@ func (e *Evaluator) ExecuteTestByName(name string, in Value, params *VarArgs) Va
 		errors.ThrowTemplateAssertionError("unknown test '%s'", name)
 	}
 	test := (*e.Tests)[name]
-	result := test(e.Ctx, in, params)
-	return e.ValueFactory.Value(result)
+	return e.ValueFactory.Value(callTest(name, test, e.Ctx, in, params))
+}
+
+// callTest calls a test function. Panics of the test are thrown as runtime
+// errors.
+func callTest(name string, test TestFunction, ctx *Context, in Value, params *VarArgs) bool {
+	defer recoverFunction("test", name)
+	return test(ctx, in, params)
 }
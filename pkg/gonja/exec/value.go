@@ -11,7 +11,6 @@ import (
 var (
 	rtValue      = reflect.TypeOf((*Value)(nil)).Elem()
 	rtValuesList = reflect.TypeOf((ValuesList)(nil))
-	rtDict       = reflect.TypeOf((*Dict)(nil))
 )
 
 func indirectReflectValue(val reflect.Value) reflect.Value {
@@ -430,10 +429,74 @@ func (d *Dict) Keys() ValuesList {
 
 // Get returns the [Value] for the given key from d.
 func (d *Dict) Get(key Value) (value Value, ok bool) {
+	if idx := d.index(key); idx >= 0 {
+		return d.Pairs[idx].Value, true
+	}
+	return nil, false
+}
+
+// Len returns the number of pairs in d.
+func (d *Dict) Len() int {
+	return len(d.Pairs)
+}
+
+// Range calls fn for every pair of d in order until fn returns false.
+func (d *Dict) Range(fn func(key, value any) bool) {
 	for _, pair := range d.Pairs {
-		if pair.Key.EqualValueTo(key) {
-			return pair.Value, true
+		if !fn(pair.Key, pair.Value) {
+			return
 		}
 	}
+}
+
+// Load returns the value for the given key from d.
+func (d *Dict) Load(key any) (value any, ok bool) {
+	if idx := d.index(dictValue(key)); idx >= 0 {
+		return d.Pairs[idx].Value, true
+	}
 	return nil, false
 }
+
+// Store sets the value for the given key. New keys are appended to d.
+func (d *Dict) Store(key, value any) {
+	k := dictValue(key)
+	if idx := d.index(k); idx >= 0 {
+		d.Pairs[idx].Value = dictValue(value)
+		return
+	}
+	d.Pairs = append(d.Pairs, &Pair{Key: k, Value: dictValue(value)})
+}
+
+// Delete removes the given key from d and reports whether it was present.
+func (d *Dict) Delete(key any) bool {
+	idx := d.index(dictValue(key))
+	if idx < 0 {
+		return false
+	}
+	d.Pairs = append(d.Pairs[:idx], d.Pairs[idx+1:]...)
+	return true
+}
+
+// MarshalJSON encodes d as JSON object in the order of its keys.
+func (d *Dict) MarshalJSON() ([]byte, error) {
+	return orderedJSON{d}.MarshalJSON()
+}
+
+// index returns the position of the given key in d or -1, if d has no such
+// key.
+func (d *Dict) index(key Value) int {
+	for idx, pair := range d.Pairs {
+		if pair.Key.EqualValueTo(key) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// dictValueFactory creates the values of plain Go values stored in a [Dict].
+var dictValueFactory = NewValueFactory(NewUndefinedValue, nil)
+
+// dictValue returns the given key or value as [Value].
+func dictValue(value any) Value {
+	return dictValueFactory.Value(value)
+}
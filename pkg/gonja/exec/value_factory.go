@@ -10,12 +10,15 @@ type ValueFactory struct {
 	undefinedFn UndefinedFunc
 
 	// customTypes allows to add custom getters for types that are not
-	// supported by default. For example, if you want to resolve value from a
-	// custom ordered map type, you can add a custom getter for that.
+	// supported by default, e.g. a custom decimal type. Ordered maps are
+	// supported by implementing [OrderedMap].
 	customTypes map[reflect.Type]ValueFunc
 
 	// customTypesEnabled is true if at least one custom getter is registered.
 	customTypesEnabled bool
+
+	// sandbox restricts the access to values, if set.
+	sandbox *Sandbox
 }
 
 // NewValueFactory creates a new value factory.
@@ -28,6 +31,12 @@ func NewValueFactory(undefined UndefinedFunc, customTypes map[reflect.Type]Value
 	}
 }
 
+// Sandbox returns the sandbox configuration or nil, if the values are not
+// sandboxed.
+func (vf *ValueFactory) Sandbox() *Sandbox {
+	return vf.sandbox
+}
+
 // Value creates a new [Value] container from the given value.
 func (vf *ValueFactory) Value(value any) Value {
 	return vf.asValue(value, false)
@@ -53,6 +62,12 @@ func (vf *ValueFactory) asValue(value any, isSafe bool) Value {
 	rflVal := reflect.Value{}
 	indVal := reflect.Value{}
 	if rv, ok := value.(reflect.Value); ok {
+		// a [Value] container stored in an interface (e.g. map[string]any)
+		if (rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr) && !rv.IsNil() && rv.CanInterface() {
+			if v, ok := rv.Interface().(Value); ok {
+				return v
+			}
+		}
 		rflVal = rv
 		indVal = indirectReflectValue(rflVal)
 	} else {
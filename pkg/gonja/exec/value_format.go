@@ -0,0 +1,433 @@
+package exec
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+)
+
+// strFormat formats the string like Python's `str.format`, e.g.
+// `"{} of {total}".format(1, total=3)`. Replacement fields may access
+// attributes and items of the arguments (`{0.name}`, `{0[key]}`), convert
+// them (`{!r}`) and apply a format spec (`{:>8.2f}`).
+func strFormat(v *GenericValue, va *VarArgs) Value {
+	f := &strFormatter{v: v, va: va}
+	return v.stringResult(f.format(v.String(), false))
+}
+
+// strFormatter formats a string using the arguments of `str.format`.
+type strFormatter struct {
+	v  *GenericValue
+	va *VarArgs
+
+	// next is the index of the next automatically numbered field
+	next int
+	// auto and manual report whether fields have been numbered automatically
+	// or manually, which must not be mixed
+	auto, manual bool
+}
+
+// format replaces the fields of s. The format specs of the fields may contain
+// fields themselves (e.g. `{:{width}}`), but not any deeper.
+func (f *strFormatter) format(s string, nested bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '{' && strings.HasPrefix(s[i:], "{{"):
+			sb.WriteByte('{')
+			i++
+		case c == '{':
+			end := fieldEnd(s, i+1)
+			if end < 0 {
+				errors.ThrowTemplateRuntimeError("single '{' encountered in format string")
+			}
+			sb.WriteString(f.field(s[i+1:end], nested))
+			i = end
+		case c == '}' && strings.HasPrefix(s[i:], "}}"):
+			sb.WriteByte('}')
+			i++
+		case c == '}':
+			errors.ThrowTemplateRuntimeError("single '}' encountered in format string")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// fieldEnd returns the index of the brace closing the field starting at
+// start or -1, if the field isn't closed.
+func fieldEnd(s string, start int) int {
+	level := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			level++
+		case '}':
+			level--
+			if level == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// field returns a formatted replacement field, e.g. `0.name!r:>10`.
+func (f *strFormatter) field(field string, nested bool) string {
+	i := 0
+	for i < len(field) && field[i] != '!' && field[i] != ':' {
+		if field[i] == '[' {
+			for i < len(field) && field[i] != ']' {
+				i++
+			}
+		}
+		i++
+	}
+	if i > len(field) {
+		i = len(field)
+	}
+	val := f.lookup(field[:i])
+
+	if i < len(field) && field[i] == '!' {
+		if i+1 >= len(field) || (i+2 < len(field) && field[i+2] != ':') {
+			errors.ThrowTemplateRuntimeError("expected ':' after conversion specifier")
+		}
+		switch field[i+1] {
+		case 's':
+			val = f.v.valueFactory.Value(val.String())
+		case 'r':
+			val = f.v.valueFactory.Value(strRepr(val))
+		default:
+			errors.ThrowTemplateRuntimeError("unknown conversion specifier %c", field[i+1])
+		}
+		i += 2
+	}
+
+	spec := ""
+	if i < len(field) {
+		if spec = field[i+1:]; nested && strings.Contains(spec, "{") {
+			errors.ThrowTemplateRuntimeError("format spec of 'format' is nested too deeply")
+		}
+		spec = f.format(spec, true)
+	}
+	return f.formatValue(val, spec)
+}
+
+// lookup returns the argument referenced by a field name, e.g. `0`, `name`,
+// `user.name` or `items[0]`. An empty name refers to the next argument.
+func (f *strFormatter) lookup(name string) Value {
+	end := strings.IndexAny(name, ".[")
+	if end < 0 {
+		end = len(name)
+	}
+	first, rest := name[:end], name[end:]
+
+	var val Value
+	if idx, err := strconv.Atoi(first); err == nil || first == "" {
+		if first == "" {
+			if f.manual {
+				errors.ThrowTemplateRuntimeError("cannot switch from manual field numbering to automatic field numbering")
+			}
+			f.auto = true
+			idx = f.next
+			f.next++
+		} else {
+			if f.auto {
+				errors.ThrowTemplateRuntimeError("cannot switch from automatic field numbering to manual field numbering")
+			}
+			f.manual = true
+		}
+		if idx < 0 || idx >= len(f.va.Args) {
+			errors.ThrowTemplateRuntimeError("replacement index %d out of range for 'format'", idx)
+		}
+		val = f.va.Args[idx]
+	} else {
+		if !f.va.HasKwarg(first) {
+			errors.ThrowTemplateRuntimeError("missing argument '%s' for 'format'", first)
+		}
+		val = f.va.GetKwarg(first)
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end <= 0 {
+				end = len(rest)
+			}
+			if end == 1 {
+				errors.ThrowTemplateRuntimeError("empty attribute in format string")
+			}
+			val = val.GetItem(rest[1:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				errors.ThrowTemplateRuntimeError("missing ']' in format string")
+			}
+			if idx, err := strconv.Atoi(rest[1:end]); err == nil {
+				val = val.GetItem(idx)
+			} else {
+				val = val.GetItem(rest[1:end])
+			}
+			rest = rest[end+1:]
+		default:
+			errors.ThrowTemplateRuntimeError("only '.' or '[' may follow ']' in format field specifier")
+		}
+	}
+	return val
+}
+
+// strRepr returns the Python representation of a value, i.e. strings are
+// quoted.
+func strRepr(val Value) string {
+	if !val.IsString() {
+		return val.String()
+	}
+	s := val.String()
+	quote := "'"
+	if strings.Contains(s, "'") && !strings.Contains(s, `"`) {
+		quote = `"`
+	}
+	s = strings.NewReplacer(`\`, `\\`, quote, `\`+quote, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s)
+	return quote + s + quote
+}
+
+// formatSpec is a parsed format spec of the form
+// `[[fill]align][sign][#][0][width][grouping][.precision][type]`.
+type formatSpec struct {
+	fill      string
+	align     byte
+	sign      byte
+	alt       bool
+	zero      bool
+	width     int
+	grouping  byte
+	precision int
+	typ       byte
+}
+
+// parseFormatSpec parses a format spec.
+func parseFormatSpec(spec string) *formatSpec {
+	fs := &formatSpec{precision: -1}
+	if r, size := utf8.DecodeRuneInString(spec); size < len(spec) && strings.IndexByte("<>=^", spec[size]) >= 0 {
+		fs.fill, fs.align = string(r), spec[size]
+		spec = spec[size+1:]
+	} else if spec != "" && strings.IndexByte("<>=^", spec[0]) >= 0 {
+		fs.align = spec[0]
+		spec = spec[1:]
+	}
+	if spec != "" && strings.IndexByte("+- ", spec[0]) >= 0 {
+		fs.sign = spec[0]
+		spec = spec[1:]
+	}
+	if strings.HasPrefix(spec, "#") {
+		fs.alt = true
+		spec = spec[1:]
+	}
+	if strings.HasPrefix(spec, "0") {
+		fs.zero = true
+		spec = spec[1:]
+	}
+	fs.width, spec = parseFormatNumber(spec)
+	if spec != "" && (spec[0] == ',' || spec[0] == '_') {
+		fs.grouping = spec[0]
+		spec = spec[1:]
+	}
+	if strings.HasPrefix(spec, ".") {
+		if spec = spec[1:]; spec == "" || spec[0] < '0' || spec[0] > '9' {
+			errors.ThrowTemplateRuntimeError("format specifier missing precision")
+		}
+		fs.precision, spec = parseFormatNumber(spec)
+	}
+	if len(spec) > 1 {
+		errors.ThrowTemplateRuntimeError("invalid format specifier")
+	}
+	if spec != "" {
+		fs.typ = spec[0]
+	}
+	return fs
+}
+
+// parseFormatNumber parses the leading digits of the spec.
+func parseFormatNumber(spec string) (int, string) {
+	end := 0
+	for end < len(spec) && spec[end] >= '0' && spec[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return 0, spec
+	}
+	n, err := strconv.Atoi(spec[:end])
+	if err != nil {
+		errors.ThrowTemplateRuntimeError("too many decimal digits in format string")
+	}
+	return n, spec[end:]
+}
+
+// formatValue formats a value according to the format spec.
+func (f *strFormatter) formatValue(val Value, spec string) string {
+	if spec == "" {
+		return f.v.stringArg(val)
+	}
+	fs := parseFormatSpec(spec)
+	isInt := val.IsInteger() || val.IsBool()
+	switch {
+	case fs.typ == 's' || (fs.typ == 0 && !val.IsNumber()):
+		if fs.sign != 0 || fs.align == '=' {
+			errors.ThrowTemplateRuntimeError("invalid format specifier for a string in 'format'")
+		}
+		s := f.v.stringArg(val)
+		if fs.precision >= 0 && fs.precision < utf8.RuneCountInString(s) {
+			s = string([]rune(s)[:fs.precision])
+		}
+		return f.pad(s, fs, '<', 0)
+	case isInt && (fs.typ == 0 || strings.IndexByte("bdoxXn", fs.typ) >= 0):
+		if fs.precision >= 0 {
+			errors.ThrowTemplateRuntimeError("precision not allowed in integer format specifier")
+		}
+		return f.formatInteger(int64(val.Integer()), fs)
+	case (val.IsNumber() || val.IsBool()) && strings.IndexByte("eEfFgG%", fs.typ) >= 0, val.IsFloat() && fs.typ == 0:
+		return f.formatFloat(val.Float(), fs)
+	}
+	errors.ThrowTemplateRuntimeError("unknown format code '%c' for '%s'", fs.typ, val.String())
+	return ""
+}
+
+// formatInteger formats an integer using the format spec.
+func (f *strFormatter) formatInteger(n int64, fs *formatSpec) string {
+	base, prefix, group := 10, "", 3
+	switch fs.typ {
+	case 'b':
+		base, prefix, group = 2, "0b", 4
+	case 'o':
+		base, prefix, group = 8, "0o", 4
+	case 'x':
+		base, prefix, group = 16, "0x", 4
+	case 'X':
+		base, prefix, group = 16, "0X", 4
+	}
+	abs := uint64(n)
+	if n < 0 {
+		abs = uint64(-n)
+	}
+	digits := strconv.FormatUint(abs, base)
+	if fs.typ == 'X' {
+		digits = strings.ToUpper(digits)
+	}
+	if !fs.alt {
+		prefix = ""
+	}
+	sign := formatSign(n < 0, fs)
+	return f.pad(sign+prefix+groupDigits(digits, fs.grouping, group), fs, '>', len(sign)+len(prefix))
+}
+
+// formatFloat formats a float using the format spec.
+func (f *strFormatter) formatFloat(x float64, fs *formatSpec) string {
+	neg := math.Signbit(x) && !math.IsNaN(x)
+	x = math.Abs(x)
+	prec := fs.precision
+	if prec < 0 && fs.typ != 0 {
+		prec = 6
+	}
+	f.v.valueFactory.Sandbox().CheckRepeat(1, prec)
+
+	var digits string
+	switch {
+	case math.IsInf(x, 0):
+		digits = "inf"
+	case math.IsNaN(x):
+		digits = "nan"
+	case fs.typ == 'f' || fs.typ == 'F':
+		digits = strconv.FormatFloat(x, 'f', prec, 64)
+	case fs.typ == 'e' || fs.typ == 'E':
+		digits = strconv.FormatFloat(x, 'e', prec, 64)
+	case fs.typ == '%':
+		digits = strconv.FormatFloat(x*100, 'f', prec, 64)
+	case prec >= 0:
+		if prec == 0 {
+			prec = 1
+		}
+		digits = strconv.FormatFloat(x, 'g', prec, 64)
+	default:
+		digits = f.v.valueFactory.Value(x).String()
+	}
+	if fs.typ == 'F' || fs.typ == 'E' || fs.typ == 'G' {
+		digits = strings.ToUpper(digits)
+	}
+
+	// group the digits of the integer part only
+	end := strings.IndexAny(digits, ".eE")
+	if end < 0 {
+		end = len(digits)
+	}
+	digits = groupDigits(digits[:end], fs.grouping, 3) + digits[end:]
+	if fs.typ == '%' {
+		digits += "%"
+	}
+	sign := formatSign(neg, fs)
+	return f.pad(sign+digits, fs, '>', len(sign))
+}
+
+// formatSign returns the sign of a number according to the format spec.
+func formatSign(neg bool, fs *formatSpec) string {
+	switch {
+	case neg:
+		return "-"
+	case fs.sign == '+' || fs.sign == ' ':
+		return string(fs.sign)
+	}
+	return ""
+}
+
+// groupDigits separates the digits into groups of the given size.
+func groupDigits(digits string, sep byte, size int) string {
+	if sep == 0 || len(digits) <= size {
+		return digits
+	}
+	var sb strings.Builder
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%size == 0 {
+			sb.WriteByte(sep)
+		}
+		sb.WriteByte(digits[i])
+	}
+	return sb.String()
+}
+
+// pad pads s to the width of the format spec using the given default
+// alignment. For the `=` alignment, the padding is placed after the first
+// prefixLen bytes (sign and base prefix).
+func (f *strFormatter) pad(s string, fs *formatSpec, align byte, prefixLen int) string {
+	spaces := fs.width - utf8.RuneCountInString(s)
+	if spaces <= 0 {
+		return s
+	}
+	fill := fs.fill
+	if fill == "" {
+		fill = " "
+		if fs.zero {
+			fill = "0"
+		}
+	}
+	if fs.align != 0 {
+		align = fs.align
+	} else if fs.zero && align == '>' {
+		// numbers are padded with zeros after the sign
+		align = '='
+	}
+	f.v.valueFactory.Sandbox().CheckRepeat(len(fill), fs.width)
+	switch align {
+	case '<':
+		return s + strings.Repeat(fill, spaces)
+	case '^':
+		return strings.Repeat(fill, spaces/2) + s + strings.Repeat(fill, spaces-spaces/2)
+	case '=':
+		return s[:prefixLen] + strings.Repeat(fill, spaces) + s[prefixLen:]
+	}
+	return strings.Repeat(fill, spaces) + s
+}
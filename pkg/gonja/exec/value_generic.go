@@ -30,6 +30,11 @@ type GenericValue struct {
 
 	// precomputed to improve performance
 	valueType reflect.Type
+
+	// assign writes a replaced value back into the container (map entry or
+	// context variable) the value was taken from. It is nil if the value is
+	// addressable itself or has no container.
+	assign func(reflect.Value)
 }
 
 // Type returns the type of the value.
@@ -84,9 +89,13 @@ func (v *GenericValue) IsList() bool {
 
 // IsDict reports whether the underlying value is a dictionary.
 func (v *GenericValue) IsDict() bool {
-	return v.IndirectValue.IsValid() &&
-		(v.IndirectValue.Kind() == reflect.Map ||
-			(v.IndirectValue.Kind() == reflect.Struct && v.valueType == rtDict))
+	if !v.IndirectValue.IsValid() {
+		return false
+	}
+	if _, ok := v.orderedMap(); ok {
+		return true
+	}
+	return v.IndirectValue.Kind() == reflect.Map
 }
 
 // IsNil reports whether the underlying value is NIL.
@@ -102,7 +111,7 @@ func (v *GenericValue) IsCallable() bool {
 // IsIterable reports whether the underlying value is an iterable type. Iterable
 // types are strings, lists and dictionaries.
 func (v *GenericValue) IsIterable() bool {
-	return v.IndirectValue.IsValid() && (v.IsString() || v.IsList() || v.IsDict())
+	return v.IndirectValue.IsValid() && (v.IsString() || v.IsList() || v.IsDict() || v.isLazy())
 }
 
 // IsSliceable reports whether the underlying value is of type array, slice or
@@ -151,6 +160,13 @@ func (v *GenericValue) String() string {
 	if v.IsNil() {
 		return "None"
 	}
+	if m, ok := v.orderedMap(); ok {
+		pairs := []string{}
+		for _, pair := range v.orderedPairs(m) {
+			pairs = append(pairs, pair.String())
+		}
+		return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+	}
 	resolved := v.IndirectValue
 
 	switch resolved.Kind() {
@@ -366,6 +382,9 @@ func (v *GenericValue) Bool() bool {
 	if v.IsNil() {
 		return false
 	}
+	if m, ok := v.orderedMap(); ok {
+		return m.Len() > 0
+	}
 
 	switch v.IndirectValue.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -403,6 +422,23 @@ func (v *GenericValue) Len() int {
 	if v.IsNil() {
 		errors.ThrowTemplateRuntimeError("nil has no length")
 	}
+	if m, ok := v.orderedMap(); ok {
+		return m.Len()
+	}
+
+	// lazy sequences are consumed to count their items, unless their length
+	// is known
+	if v.isLazy() {
+		if length, ok := v.lazyLen(); ok {
+			return length
+		}
+		count := 0
+		v.iterateLazy(func(idx, _ int, key, value Value) bool {
+			count++
+			return true
+		}, func() {})
+		return count
+	}
 
 	switch v.IndirectValue.Kind() {
 	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice:
@@ -492,12 +528,17 @@ func (v *GenericValue) Contains(other Value) bool {
 		errors.ThrowTemplateRuntimeError("nil cannot be checked for containment")
 	}
 
+	if m, ok := v.orderedMap(); ok {
+		_, found := m.Load(mapEntry(m, other))
+		return found
+	}
+	if v.isLazy() {
+		return v.lazyContains(other)
+	}
+
 	resolved := v.IndirectValue
 	switch resolved.Kind() {
 	case reflect.Struct:
-		if dict, ok := v.Value.Interface().(*Dict); ok {
-			return dict.Keys().Contains(other)
-		}
 		fldVal := resolved.FieldByName(other.String())
 		return fldVal.IsValid()
 
@@ -541,8 +582,8 @@ func (v *GenericValue) Keys() ValuesList {
 	}
 
 	keys := ValuesList{}
-	if v.valueType == rtDict {
-		for _, pair := range v.Value.Interface().(*Dict).Pairs {
+	if m, ok := v.orderedMap(); ok {
+		for _, pair := range v.orderedPairs(m) {
 			keys = append(keys, pair.Key)
 		}
 		return keys
@@ -565,8 +606,8 @@ func (v *GenericValue) Values() ValuesList {
 	}
 
 	values := ValuesList{}
-	if v.valueType == rtDict {
-		for _, pair := range v.Value.Interface().(*Dict).Pairs {
+	if m, ok := v.orderedMap(); ok {
+		for _, pair := range v.orderedPairs(m) {
 			values = append(values, pair.Value)
 		}
 		return values
@@ -590,8 +631,8 @@ func (v *GenericValue) Items() []*Pair {
 	}
 
 	items := []*Pair{}
-	if v.valueType == rtDict {
-		return v.Value.Interface().(Dict).Pairs
+	if m, ok := v.orderedMap(); ok {
+		return v.orderedPairs(m)
 	} else if v.IndirectValue.Kind() == reflect.Map {
 		iter := v.IndirectValue.MapRange()
 		for iter.Next() {
@@ -620,12 +661,41 @@ func (v *GenericValue) GetItem(key any) Value {
 		debug.Print("get item '%s' from invalid or nil value -> return undefined", key)
 		return v.valueFactory.NewUndefined(fmt.Sprintf("%s", key), "")
 	}
+	sandbox := v.valueFactory.sandbox
+	sandbox.CheckType(v.IndirectValue.Type())
+
+	// ordered maps are accessed by key, the dict methods are used as fallback
+	if m, ok := v.orderedMap(); ok {
+		if item, found := m.Load(key); found {
+			return withAssign(v.valueFactory.Value(item), func(value reflect.Value) {
+				m.Store(key, value.Interface())
+			})
+		}
+		if name, ok := key.(string); ok {
+			if method := v.getBuiltinMethod(name); method != nil {
+				return method
+			}
+		}
+		debug.Print("dict has no key '%v' -> return undefined", key)
+		return v.valueFactory.NewUndefined(fmt.Sprintf("%v", key), "dict has no key '%v'", key)
+	}
 
 	var resVal reflect.Value
 	if index, ok := key.(int); ok {
 		val := v.IndirectValue
 		switch val.Kind() {
-		case reflect.String, reflect.Array, reflect.Slice:
+		case reflect.String:
+			runes := []rune(val.String())
+			if index < 0 {
+				index = len(runes) + index
+			}
+			if index < 0 || index >= len(runes) {
+				debug.Print("index '%v' out of range -> return undefined", index)
+				return v.valueFactory.NewUndefined(strconv.Itoa(index), "%s has no element %d", val.Kind().String(), index)
+			}
+			return v.valueFactory.Value(string(runes[index]))
+
+		case reflect.Array, reflect.Slice:
 			if index >= val.Len() {
 				debug.Print("index '%v' out of range -> return undefined", index)
 				return v.valueFactory.NewUndefined(strconv.Itoa(index), "%s has no element %d", val.Kind().String(), index)
@@ -640,7 +710,7 @@ func (v *GenericValue) GetItem(key any) Value {
 			resVal = val.Index(index)
 
 		case reflect.Map:
-			resVal = val.MapIndex(reflect.ValueOf(index))
+			resVal = mapIndex(val, index)
 			if !resVal.IsValid() {
 				debug.Print("map has no key '%v' -> return undefined", index)
 				return v.valueFactory.NewUndefined(fmt.Sprintf("%s", key), "")
@@ -652,16 +722,24 @@ func (v *GenericValue) GetItem(key any) Value {
 
 	} else if name, ok := key.(string); ok {
 		// check if value has a method with the given name
-		val := v.Value.MethodByName(name)
+		rcv := v.Value
+		if rcv.Kind() == reflect.Interface {
+			rcv = rcv.Elem()
+		}
+		val := rcv.MethodByName(name)
 		if val.IsValid() {
+			sandbox.CheckMethod(v.IndirectValue.Type(), name)
 			return v.valueFactory.Value(val)
 		}
 
 		val = v.IndirectValue
 		switch val.Kind() {
 		case reflect.Map:
-			resVal = val.MapIndex(reflect.ValueOf(name))
+			resVal = mapIndex(val, name)
 			if !resVal.IsValid() {
+				if method := v.getBuiltinMethod(name); method != nil {
+					return method
+				}
 				debug.Print("map has no key '%s' -> return undefined", name)
 				return v.valueFactory.NewUndefined(name, "map has no key '%s'", name)
 			}
@@ -675,25 +753,29 @@ func (v *GenericValue) GetItem(key any) Value {
 				}
 			}
 
-			// special handling for dict type
-			if v.valueType == rtDict {
-				dict := v.Value.Interface().(*Dict)
-				resVal, ok := dict.Get(v.valueFactory.Value(name))
-				if !ok {
-					debug.Print("dict has no key '%s' -> return undefined", name)
-					return v.valueFactory.NewUndefined(name, "dict has no key '%s'", name)
-				}
-				return resVal
-			}
-
 			structFlds := getStructFields(val)
 			fld, ok := structFlds[name]
 			if !ok {
 				debug.Print("struct has no field '%s' -> return undefined", name)
 				return v.valueFactory.NewUndefined(name, "struct has no field '%s'", name)
 			}
+			sandbox.CheckField(val.Type(), name)
 			resVal = val.Field(fld.Index)
 
+		case reflect.Array, reflect.Slice:
+			if method := v.getBuiltinMethod(name); method != nil {
+				return method
+			}
+			debug.Print("list has no method '%s' -> return undefined", name)
+			return v.valueFactory.NewUndefined(name, "list has no method '%s'", name)
+
+		case reflect.String:
+			if method := v.getBuiltinMethod(name); method != nil {
+				return method
+			}
+			debug.Print("string has no method '%s' -> return undefined", name)
+			return v.valueFactory.NewUndefined(name, "string has no method '%s'", name)
+
 		default:
 			debug.Print("cannot get item '%s' from '%s' value -> return undefined", name, val.Kind().String())
 			return v.valueFactory.NewUndefined(name, "")
@@ -703,7 +785,7 @@ func (v *GenericValue) GetItem(key any) Value {
 		val := v.IndirectValue
 		switch val.Kind() {
 		case reflect.Map:
-			resVal = val.MapIndex(reflect.ValueOf(key))
+			resVal = mapIndex(val, key)
 			if !resVal.IsValid() {
 				debug.Print("map has no key '%v' -> return undefined", key)
 				return v.valueFactory.NewUndefined(fmt.Sprintf("%s", key), "")
@@ -722,7 +804,64 @@ func (v *GenericValue) GetItem(key any) Value {
 	if resVal.Type() == rtValue {
 		return resVal.Interface().(Value)
 	}
-	return v.valueFactory.Value(resVal)
+	item := v.valueFactory.Value(resVal)
+	if v.IndirectValue.Kind() == reflect.Map {
+		item = withAssign(item, mapAssign(v.IndirectValue, key))
+	}
+	return item
+}
+
+// withAssign attaches the write back function to a generic value, unless it
+// already has one.
+func withAssign(value Value, assign func(reflect.Value)) Value {
+	if gv, ok := value.(*GenericValue); ok && gv.assign == nil {
+		gv.assign = assign
+	}
+	return value
+}
+
+// mapAssign returns a function that stores a value in the map under the given
+// key.
+func mapAssign(m reflect.Value, key any) func(reflect.Value) {
+	keyVal := reflect.ValueOf(key)
+	if !keyVal.Type().AssignableTo(m.Type().Key()) {
+		keyVal = keyVal.Convert(m.Type().Key())
+	}
+	return func(value reflect.Value) {
+		if !value.Type().AssignableTo(m.Type().Elem()) {
+			errors.ThrowTemplateRuntimeError("cannot store %s in a map of %s", value.Type(), m.Type().Elem())
+		}
+		m.SetMapIndex(keyVal, value)
+	}
+}
+
+// mapIndex returns the value of the map for the given key. The key is
+// converted to the key type of the map, if both are strings or integers. If the
+// key is not contained in the map, an invalid value is returned.
+func mapIndex(m reflect.Value, key any) reflect.Value {
+	keyVal := reflect.ValueOf(key)
+	if !keyVal.IsValid() {
+		return reflect.Value{}
+	}
+	keyType := m.Type().Key()
+	if !keyVal.Type().AssignableTo(keyType) {
+		if !(isIntegerKind(keyVal.Kind()) && isIntegerKind(keyType.Kind())) &&
+			!(keyVal.Kind() == reflect.String && keyType.Kind() == reflect.String) {
+			return reflect.Value{}
+		}
+		keyVal = keyVal.Convert(keyType)
+	}
+	return m.MapIndex(keyVal)
+}
+
+// isIntegerKind reports whether the kind is an integer kind.
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
 }
 
 // XXX: need to work on that
@@ -730,6 +869,10 @@ func (v *GenericValue) SetItem(key string, value interface{}) {
 	if v.IsNil() {
 		errors.ThrowTemplateRuntimeError("can't set attribute or item on nil value")
 	}
+	if m, ok := v.orderedMap(); ok {
+		m.Store(mapEntry(m, v.valueFactory.Value(key)), mapEntry(m, v.valueFactory.Value(value)))
+		return
+	}
 	val := v.Value
 	for val.Kind() == reflect.Ptr {
 		val = val.Elem()
@@ -783,6 +926,21 @@ func (v *GenericValue) IterateOrder(
 		errors.ThrowTemplateRuntimeError("nil cannot be iterated")
 	}
 
+	if m, ok := v.orderedMap(); ok {
+		v.iterateOrderedMap(m, fn, empty, reverse, sorted, caseSensitive)
+		return
+	}
+
+	// lazy sequences are only materialized, if an order is required
+	if v.isLazy() {
+		if reverse || sorted {
+			v.materialize().IterateOrder(fn, empty, reverse, sorted, caseSensitive)
+		} else {
+			v.iterateLazy(fn, empty)
+		}
+		return
+	}
+
 	rflVal := v.IndirectValue
 	switch rflVal.Kind() {
 	case reflect.Map:
@@ -825,14 +983,19 @@ func (v *GenericValue) IterateOrder(
 	case reflect.Array, reflect.Slice:
 		var items ValuesList
 		var itemCount int
-		if v.valueType == rtValuesList {
-			items = v.Value.Interface().(ValuesList)
+		if rflVal.Type() == rtValuesList {
+			items = rflVal.Interface().(ValuesList)
 			itemCount = len(items)
 		} else {
+			// pass addressable elements, so that nested lists can be modified
 			itemCount = rflVal.Len()
 			items = make(ValuesList, 0, itemCount)
 			for i := 0; i < itemCount; i++ {
-				items = append(items, v.valueFactory.Value(rflVal.Index(i).Interface()))
+				item := rflVal.Index(i)
+				if item.Kind() == reflect.Interface {
+					item = item.Elem()
+				}
+				items = append(items, v.valueFactory.Value(item))
 			}
 		}
 		if itemCount == 0 {
@@ -920,49 +1083,56 @@ func (v *GenericValue) IterateOrder(
 
 		return // done
 
-	case reflect.Struct:
-		if v.valueType != rtDict {
-			errors.ThrowTemplateRuntimeError("Value.Iterate() not available for type: %s", rflVal.Kind().String())
-		}
-		dict := v.Value.Interface().(*Dict)
-		keys := dict.Keys()
-		keysCount := len(dict.Pairs)
-		if keysCount == 0 {
-			empty()
-			return
-		}
+	default:
+		errors.ThrowTemplateRuntimeError("type %s cannot be iterated", rflVal.Kind().String())
+	}
+}
 
-		if sorted {
-			sortKeys := sortValuesList(keys, caseSensitive)
-			if reverse {
-				sort.Sort(sort.Reverse(sortKeys))
-			} else {
-				sort.Sort(sortKeys)
-			}
+// iterateOrderedMap iterates through an ordered map in the order of its keys,
+// unless the keys are sorted.
+func (v *GenericValue) iterateOrderedMap(
+	m OrderedMap,
+	fn func(idx, count int, key, value Value) (cont bool),
+	empty func(),
+	reverse bool,
+	sorted bool,
+	caseSensitive bool,
+) {
+	pairs := v.orderedPairs(m)
+	count := len(pairs)
+	if count == 0 {
+		empty()
+		return
+	}
 
-		} else if reverse {
-			// reverse order without sorting
-			for i := keysCount - 1; i >= 0; i-- {
-				key := keys[i]
-				item, _ := dict.Get(key)
-				if !fn(keysCount-i-1, keysCount, key, item) {
-					return
-				}
-			}
-			return
+	if sorted {
+		keys := make(ValuesList, 0, count)
+		for _, pair := range pairs {
+			keys = append(keys, pair.Key)
+		}
+		sortKeys := sortValuesList(keys, caseSensitive)
+		if reverse {
+			sort.Sort(sort.Reverse(sortKeys))
+		} else {
+			sort.Sort(sortKeys)
 		}
-
 		for i, key := range keys {
-			item, _ := dict.Get(key)
-			if !fn(i, keysCount, key, item) {
+			item, _ := m.Load(mapEntry(m, key))
+			if !fn(i, count, key, v.valueFactory.Value(item)) {
 				return
 			}
 		}
+		return
+	}
 
-		return // done
-
-	default:
-		errors.ThrowTemplateRuntimeError("type %s cannot be iterated", rflVal.Kind().String())
+	for i := range pairs {
+		pair := pairs[i]
+		if reverse {
+			pair = pairs[count-i-1]
+		}
+		if !fn(i, count, pair.Key, pair.Value) {
+			return
+		}
 	}
 }
 
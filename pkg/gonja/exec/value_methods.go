@@ -0,0 +1,1158 @@
+package exec
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"golang.org/x/text/cases"
+)
+
+// builtinMethod is a Python-like method of a string, list or dict value, e.g.
+// `name.upper()` or `list.append(x)`.
+type builtinMethod func(v *GenericValue, va *VarArgs) Value
+
+var (
+	stringMethods = map[string]builtinMethod{
+		"capitalize":   stringTransform("capitalize", strCapitalize),
+		"casefold":     stringTransform("casefold", strCasefold),
+		"center":       strCenter,
+		"count":        strCount,
+		"endswith":     strEndswith,
+		"expandtabs":   strExpandtabs,
+		"find":         strFind,
+		"format":       strFormat,
+		"index":        strIndex,
+		"isalnum":      stringPredicate("isalnum", func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }),
+		"isalpha":      stringPredicate("isalpha", unicode.IsLetter),
+		"isdigit":      stringPredicate("isdigit", unicode.IsDigit),
+		"islower":      strIslower,
+		"isspace":      stringPredicate("isspace", unicode.IsSpace),
+		"isupper":      strIsupper,
+		"join":         strJoin,
+		"ljust":        strLjust,
+		"lower":        stringTransform("lower", strings.ToLower),
+		"lstrip":       strLstrip,
+		"partition":    strPartition,
+		"removeprefix": strRemoveprefix,
+		"removesuffix": strRemovesuffix,
+		"replace":      strReplace,
+		"rfind":        strRfind,
+		"rindex":       strRindex,
+		"rjust":        strRjust,
+		"rpartition":   strRpartition,
+		"rsplit":       strRsplit,
+		"rstrip":       strRstrip,
+		"split":        strSplit,
+		"splitlines":   strSplitlines,
+		"startswith":   strStartswith,
+		"strip":        strStrip,
+		"swapcase":     stringTransform("swapcase", strSwapcase),
+		"title":        stringTransform("title", strTitle),
+		"upper":        stringTransform("upper", strings.ToUpper),
+		"zfill":        strZfill,
+	}
+	listMethods = map[string]builtinMethod{
+		"append":  listAppend,
+		"clear":   listClear,
+		"copy":    listCopy,
+		"count":   listCount,
+		"extend":  listExtend,
+		"index":   listIndex,
+		"insert":  listInsert,
+		"pop":     listPop,
+		"remove":  listRemove,
+		"reverse": listReverse,
+		"sort":    listSort,
+	}
+	dictMethods = map[string]builtinMethod{
+		"clear":      dictClear,
+		"copy":       dictCopy,
+		"get":        dictGet,
+		"items":      dictItems,
+		"keys":       dictKeys,
+		"pop":        dictPop,
+		"setdefault": dictSetdefault,
+		"update":     dictUpdate,
+		"values":     dictValues,
+	}
+)
+
+// getBuiltinMethod returns the built-in method with the given name bound to
+// v. If v has no such method, nil is returned.
+func (v *GenericValue) getBuiltinMethod(name string) Value {
+	var method builtinMethod
+	switch {
+	case v.IsString():
+		method = stringMethods[name]
+	case v.IsDict():
+		method = dictMethods[name]
+	case v.IsList():
+		method = listMethods[name]
+	}
+	if method == nil {
+		return nil
+	}
+	return v.valueFactory.Value(func(va *VarArgs) Value {
+		return method(v, va)
+	})
+}
+
+// -----------------------------------------------------------------------------
+// String Methods
+// -----------------------------------------------------------------------------
+
+// stringResult returns the result of a string method. Like Jinja's `Markup`,
+// the result of a method of a safe string is safe as well.
+func (v *GenericValue) stringResult(s string) Value {
+	if v.IsSafe() {
+		return v.valueFactory.SafeValue(s)
+	}
+	return v.valueFactory.Value(s)
+}
+
+// stringArg returns the string of a method argument. Arguments of methods of
+// safe strings are escaped, unless they are safe themselves.
+func (v *GenericValue) stringArg(arg Value) string {
+	if v.IsSafe() && !arg.IsSafe() {
+		return arg.Escaped()
+	}
+	return arg.String()
+}
+
+// stringList returns a list of strings as result of a string method.
+func (v *GenericValue) stringList(items []string) Value {
+	list := make(ValuesList, 0, len(items))
+	for _, item := range items {
+		list = append(list, v.stringResult(item))
+	}
+	return v.valueFactory.Value(list)
+}
+
+// stringTransform returns a string method without arguments that transforms
+// the string using fn.
+func stringTransform(name string, fn func(string) string) builtinMethod {
+	return func(v *GenericValue, va *VarArgs) Value {
+		if p := va.ExpectNothing(); p.IsError() {
+			errors.ThrowTemplateRuntimeError("wrong signature for '%s': %s", name, p.Error())
+		}
+		return v.stringResult(fn(v.String()))
+	}
+}
+
+// stringPredicate returns a string method without arguments that reports
+// whether the string is not empty and all of its characters satisfy fn.
+func stringPredicate(name string, fn func(rune) bool) builtinMethod {
+	return func(v *GenericValue, va *VarArgs) Value {
+		if p := va.ExpectNothing(); p.IsError() {
+			errors.ThrowTemplateRuntimeError("wrong signature for '%s': %s", name, p.Error())
+		}
+		s := v.String()
+		return v.valueFactory.Value(s != "" && strings.IndexFunc(s, func(r rune) bool { return !fn(r) }) < 0)
+	}
+}
+
+// strCapitalize returns s with the first character in upper case and the rest
+// in lower case.
+func strCapitalize(s string) string {
+	runes := []rune(strings.ToLower(s))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// strCasefold returns s folded for caseless comparisons, e.g. "ß" becomes
+// "ss".
+func strCasefold(s string) string {
+	return cases.Fold().String(s)
+}
+
+// strSwapcase returns s with upper case characters converted to lower case and
+// vice versa.
+func strSwapcase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// strTitle returns s with the first letter of every word in upper case and
+// the remaining letters in lower case.
+func strTitle(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i > 0 && unicode.IsLetter(runes[i-1]) {
+			runes[i] = unicode.ToLower(r)
+		} else {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// strCased reports whether s has cased characters and all of them satisfy
+// isCase.
+func strCased(s string, isCase func(rune) bool) bool {
+	cased := false
+	for _, r := range s {
+		if unicode.IsUpper(r) || unicode.IsLower(r) || unicode.IsTitle(r) {
+			if !isCase(r) {
+				return false
+			}
+			cased = true
+		}
+	}
+	return cased
+}
+
+// strIslower reports whether all cased characters are in lower case.
+func strIslower(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'islower': %s", p.Error())
+	}
+	return v.valueFactory.Value(strCased(v.String(), unicode.IsLower))
+}
+
+// strIsupper reports whether all cased characters are in upper case.
+func strIsupper(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'isupper': %s", p.Error())
+	}
+	return v.valueFactory.Value(strCased(v.String(), unicode.IsUpper))
+}
+
+// strPad pads the string to the given width. The position of the string
+// within the padding is given by the share of padding on the left.
+func strPad(v *GenericValue, va *VarArgs, name string, left func(spaces int) int) Value {
+	p := va.Expect(1, []*Kwarg{{Name: "fillchar", Default: " "}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for '%s': %s", name, p.Error())
+	}
+	fill := []rune(v.stringArg(p.GetKwarg("fillchar")))
+	if len(fill) != 1 {
+		errors.ThrowTemplateRuntimeError("'%s' expects a single fill character", name)
+	}
+	s := v.String()
+	spaces := p.First().Integer() - utf8.RuneCountInString(s)
+	if spaces <= 0 {
+		return v.stringResult(s)
+	}
+	v.valueFactory.Sandbox().CheckRepeat(len(string(fill)), p.First().Integer())
+	l := left(spaces)
+	return v.stringResult(strings.Repeat(string(fill), l) + s + strings.Repeat(string(fill), spaces-l))
+}
+
+// strCenter centers the string within the given width.
+func strCenter(v *GenericValue, va *VarArgs) Value {
+	return strPad(v, va, "center", func(spaces int) int { return spaces/2 + spaces%2 })
+}
+
+// strLjust left-justifies the string within the given width.
+func strLjust(v *GenericValue, va *VarArgs) Value {
+	return strPad(v, va, "ljust", func(spaces int) int { return 0 })
+}
+
+// strRjust right-justifies the string within the given width.
+func strRjust(v *GenericValue, va *VarArgs) Value {
+	return strPad(v, va, "rjust", func(spaces int) int { return spaces })
+}
+
+// strZfill pads a numeric string with zeros on the left. A leading sign is
+// kept in front of the zeros.
+func strZfill(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'zfill': %s", p.Error())
+	}
+	s := v.String()
+	spaces := p.First().Integer() - utf8.RuneCountInString(s)
+	if spaces <= 0 {
+		return v.stringResult(s)
+	}
+	v.valueFactory.Sandbox().CheckRepeat(1, p.First().Integer())
+	sign := ""
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		sign, s = s[:1], s[1:]
+	}
+	return v.stringResult(sign + strings.Repeat("0", spaces) + s)
+}
+
+// strSpan returns the part of the string given by the optional `start` and
+// `end` arguments (like `s[start:end]`) and its offset in runes.
+func strSpan(s string, p *ReducedVarArgs) (span string, offset int, ok bool) {
+	runes := []rune(s)
+	start, end := 0, len(runes)
+	if arg := p.GetKwarg("start"); !arg.IsNil() {
+		start = clampIndex(arg.Integer(), len(runes))
+	}
+	if arg := p.GetKwarg("end"); !arg.IsNil() {
+		end = clampIndex(arg.Integer(), len(runes))
+	}
+	if start > end {
+		return "", start, false
+	}
+	return string(runes[start:end]), start, true
+}
+
+// clampIndex resolves a negative index and limits the index to the bounds of
+// a sequence of the given length.
+func clampIndex(idx, length int) int {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 {
+		return 0
+	}
+	if idx > length {
+		return length
+	}
+	return idx
+}
+
+// spanKwargs are the optional arguments of the string methods that operate on
+// a part of the string.
+func spanKwargs() []*Kwarg {
+	return []*Kwarg{{Name: "start", Default: nil}, {Name: "end", Default: nil}}
+}
+
+// strCount returns the number of non-overlapping occurrences of a substring.
+func strCount(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(1, spanKwargs())
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'count': %s", p.Error())
+	}
+	span, _, ok := strSpan(v.String(), p)
+	if !ok {
+		return v.valueFactory.Value(0)
+	}
+	sub := v.stringArg(p.First())
+	if sub == "" {
+		return v.valueFactory.Value(utf8.RuneCountInString(span) + 1)
+	}
+	return v.valueFactory.Value(strings.Count(span, sub))
+}
+
+// strSearch returns the rune index of the first (or last) occurrence of the
+// substring or -1, if the string doesn't contain the substring.
+func strSearch(v *GenericValue, va *VarArgs, name string, last bool) int {
+	p := va.Expect(1, spanKwargs())
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for '%s': %s", name, p.Error())
+	}
+	span, offset, ok := strSpan(v.String(), p)
+	if !ok {
+		return -1
+	}
+	sub := v.stringArg(p.First())
+	idx := strings.Index(span, sub)
+	if last {
+		idx = strings.LastIndex(span, sub)
+	}
+	if idx < 0 {
+		return -1
+	}
+	return offset + utf8.RuneCountInString(span[:idx])
+}
+
+// strFind returns the lowest index of a substring or -1, if it isn't found.
+func strFind(v *GenericValue, va *VarArgs) Value {
+	return v.valueFactory.Value(strSearch(v, va, "find", false))
+}
+
+// strRfind returns the highest index of a substring or -1, if it isn't found.
+func strRfind(v *GenericValue, va *VarArgs) Value {
+	return v.valueFactory.Value(strSearch(v, va, "rfind", true))
+}
+
+// strIndex returns the lowest index of a substring or throws an error, if it
+// isn't found.
+func strIndex(v *GenericValue, va *VarArgs) Value {
+	idx := strSearch(v, va, "index", false)
+	if idx < 0 {
+		errors.ThrowTemplateRuntimeError("substring not found")
+	}
+	return v.valueFactory.Value(idx)
+}
+
+// strRindex returns the highest index of a substring or throws an error, if it
+// isn't found.
+func strRindex(v *GenericValue, va *VarArgs) Value {
+	idx := strSearch(v, va, "rindex", true)
+	if idx < 0 {
+		errors.ThrowTemplateRuntimeError("substring not found")
+	}
+	return v.valueFactory.Value(idx)
+}
+
+// strAffix reports whether the string starts or ends with the affix. The affix
+// may also be a list of alternatives.
+func strAffix(v *GenericValue, va *VarArgs, name string, has func(s, affix string) bool) Value {
+	p := va.Expect(1, spanKwargs())
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for '%s': %s", name, p.Error())
+	}
+	span, _, ok := strSpan(v.String(), p)
+	if !ok {
+		return v.valueFactory.Value(false)
+	}
+	affixes := ValuesList{p.First()}
+	if p.First().IsList() {
+		affixes = affixes[:0]
+		p.First().Iterate(func(idx, count int, key, value Value) bool {
+			affixes = append(affixes, key)
+			return true
+		}, func() {})
+	}
+	for _, affix := range affixes {
+		if has(span, v.stringArg(affix)) {
+			return v.valueFactory.Value(true)
+		}
+	}
+	return v.valueFactory.Value(false)
+}
+
+// strStartswith reports whether the string starts with the given prefix.
+func strStartswith(v *GenericValue, va *VarArgs) Value {
+	return strAffix(v, va, "startswith", strings.HasPrefix)
+}
+
+// strEndswith reports whether the string ends with the given suffix.
+func strEndswith(v *GenericValue, va *VarArgs) Value {
+	return strAffix(v, va, "endswith", strings.HasSuffix)
+}
+
+// strRemoveprefix removes the given prefix from the string, if present.
+func strRemoveprefix(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'removeprefix': %s", p.Error())
+	}
+	return v.stringResult(strings.TrimPrefix(v.String(), v.stringArg(p.First())))
+}
+
+// strRemovesuffix removes the given suffix from the string, if present.
+func strRemovesuffix(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'removesuffix': %s", p.Error())
+	}
+	return v.stringResult(strings.TrimSuffix(v.String(), v.stringArg(p.First())))
+}
+
+// strJoin concatenates the items of an iterable using the string as
+// separator.
+func strJoin(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'join': %s", p.Error())
+	}
+	if !p.First().IsIterable() {
+		errors.ThrowTemplateRuntimeError("'join' expects an iterable, got '%s'", p.First().String())
+	}
+	items := []string{}
+	p.First().Iterate(func(idx, count int, key, value Value) bool {
+		items = append(items, v.stringArg(key))
+		return true
+	}, func() {})
+	return v.stringResult(strings.Join(items, v.String()))
+}
+
+// strReplace replaces occurrences of a substring. If count is given, only the
+// first count occurrences are replaced.
+func strReplace(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(2, []*Kwarg{{Name: "count", Default: -1}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'replace': %s", p.Error())
+	}
+	s := v.String()
+	from, to := v.stringArg(p.Args[0]), v.stringArg(p.Args[1])
+	count := p.GetKwarg("count").Integer()
+	if len(to) > len(from) {
+		// check the size of the result before it is allocated
+		n := strings.Count(s, from)
+		if count >= 0 && count < n {
+			n = count
+		}
+		v.valueFactory.Sandbox().CheckRepeat(len(s)+n*(len(to)-len(from)), 1)
+	}
+	return v.stringResult(strings.Replace(s, from, to, count))
+}
+
+// strExpandtabs replaces tabs with spaces up to the next tab stop. The column
+// is reset by line breaks.
+func strExpandtabs(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(0, []*Kwarg{{Name: "tabsize", Default: 8}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'expandtabs': %s", p.Error())
+	}
+	s := v.String()
+	tabsize := p.GetKwarg("tabsize").Integer()
+	if n := strings.Count(s, "\t"); n > 0 && tabsize > 1 {
+		// check the size of the result before it is allocated
+		v.valueFactory.Sandbox().CheckRepeat(len(s)+n*(tabsize-1), 1)
+	}
+	var sb strings.Builder
+	column := 0
+	for _, r := range s {
+		switch r {
+		case '\t':
+			if tabsize > 0 {
+				spaces := tabsize - column%tabsize
+				sb.WriteString(strings.Repeat(" ", spaces))
+				column += spaces
+			}
+		case '\n', '\r':
+			sb.WriteRune(r)
+			column = 0
+		default:
+			sb.WriteRune(r)
+			column++
+		}
+	}
+	return v.stringResult(sb.String())
+}
+
+// strTrim removes leading and/or trailing characters. Without argument,
+// whitespace is removed.
+func strTrim(v *GenericValue, va *VarArgs, name string, left, right bool) Value {
+	p := va.Expect(0, []*Kwarg{{Name: "chars", Default: nil}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for '%s': %s", name, p.Error())
+	}
+	trim := unicode.IsSpace
+	if chars := p.GetKwarg("chars"); !chars.IsNil() {
+		set := v.stringArg(chars)
+		trim = func(r rune) bool { return strings.ContainsRune(set, r) }
+	}
+	s := v.String()
+	if left {
+		s = strings.TrimLeftFunc(s, trim)
+	}
+	if right {
+		s = strings.TrimRightFunc(s, trim)
+	}
+	return v.stringResult(s)
+}
+
+// strStrip removes leading and trailing characters.
+func strStrip(v *GenericValue, va *VarArgs) Value {
+	return strTrim(v, va, "strip", true, true)
+}
+
+// strLstrip removes leading characters.
+func strLstrip(v *GenericValue, va *VarArgs) Value {
+	return strTrim(v, va, "lstrip", true, false)
+}
+
+// strRstrip removes trailing characters.
+func strRstrip(v *GenericValue, va *VarArgs) Value {
+	return strTrim(v, va, "rstrip", false, true)
+}
+
+// splitArgs returns the separator and the maximum number of splits of the
+// split methods. An empty separator means splitting at runs of whitespace.
+func (v *GenericValue) splitArgs(va *VarArgs, name string) (sep string, maxsplit int) {
+	p := va.Expect(0, []*Kwarg{{Name: "sep", Default: nil}, {Name: "maxsplit", Default: -1}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for '%s': %s", name, p.Error())
+	}
+	if arg := p.GetKwarg("sep"); !arg.IsNil() {
+		sep = v.stringArg(arg)
+		if sep == "" {
+			errors.ThrowTemplateRuntimeError("empty separator")
+		}
+	}
+	return sep, p.GetKwarg("maxsplit").Integer()
+}
+
+// strSplit splits the string at the separator, starting from the left.
+func strSplit(v *GenericValue, va *VarArgs) Value {
+	sep, maxsplit := v.splitArgs(va, "split")
+	s := v.String()
+	if sep != "" {
+		if maxsplit >= 0 {
+			return v.stringList(strings.SplitN(s, sep, maxsplit+1))
+		}
+		return v.stringList(strings.Split(s, sep))
+	}
+	parts := []string{}
+	for s = strings.TrimLeftFunc(s, unicode.IsSpace); s != ""; s = strings.TrimLeftFunc(s, unicode.IsSpace) {
+		if maxsplit >= 0 && len(parts) == maxsplit {
+			parts = append(parts, s)
+			break
+		}
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		parts = append(parts, s[:end])
+		s = s[end:]
+	}
+	return v.stringList(parts)
+}
+
+// strRsplit splits the string at the separator, starting from the right.
+func strRsplit(v *GenericValue, va *VarArgs) Value {
+	sep, maxsplit := v.splitArgs(va, "rsplit")
+	s := v.String()
+	if maxsplit < 0 {
+		return strSplit(v, va)
+	}
+	parts := []string{}
+	for {
+		if sep == "" {
+			s = strings.TrimRightFunc(s, unicode.IsSpace)
+			if s == "" {
+				break
+			}
+		}
+		if len(parts) == maxsplit {
+			parts = append(parts, s)
+			break
+		}
+		var start, end int
+		if sep != "" {
+			end = strings.LastIndex(s, sep)
+			if end < 0 {
+				parts = append(parts, s)
+				break
+			}
+			start = end + len(sep)
+		} else {
+			start = strings.LastIndexFunc(s, unicode.IsSpace) + 1
+			end = start
+		}
+		parts = append(parts, s[start:])
+		s = s[:end]
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return v.stringList(parts)
+}
+
+// strSplitlines splits the string at line boundaries. The line breaks are
+// kept, if keepends is true.
+func strSplitlines(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(0, []*Kwarg{{Name: "keepends", Default: false}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'splitlines': %s", p.Error())
+	}
+	keepends := p.GetKwarg("keepends").Bool()
+	lines := []string{}
+	s := v.String()
+	for s != "" {
+		end := strings.IndexAny(s, "\r\n")
+		if end < 0 {
+			lines = append(lines, s)
+			break
+		}
+		next := end + 1
+		if strings.HasPrefix(s[end:], "\r\n") {
+			next++
+		}
+		if keepends {
+			lines = append(lines, s[:next])
+		} else {
+			lines = append(lines, s[:end])
+		}
+		s = s[next:]
+	}
+	return v.stringList(lines)
+}
+
+// strPartitionAt splits the string at the first (or last) occurrence of the
+// separator and returns the part before, the separator and the part after.
+func strPartitionAt(v *GenericValue, va *VarArgs, name string, last bool) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for '%s': %s", name, p.Error())
+	}
+	s, sep := v.String(), v.stringArg(p.First())
+	if sep == "" {
+		errors.ThrowTemplateRuntimeError("empty separator")
+	}
+	idx := strings.Index(s, sep)
+	if last {
+		idx = strings.LastIndex(s, sep)
+	}
+	switch {
+	case idx >= 0:
+		return v.stringList([]string{s[:idx], sep, s[idx+len(sep):]})
+	case last:
+		return v.stringList([]string{"", "", s})
+	default:
+		return v.stringList([]string{s, "", ""})
+	}
+}
+
+// strPartition splits the string at the first occurrence of the separator.
+func strPartition(v *GenericValue, va *VarArgs) Value {
+	return strPartitionAt(v, va, "partition", false)
+}
+
+// strRpartition splits the string at the last occurrence of the separator.
+func strRpartition(v *GenericValue, va *VarArgs) Value {
+	return strPartitionAt(v, va, "rpartition", true)
+}
+
+// -----------------------------------------------------------------------------
+// List Methods
+// -----------------------------------------------------------------------------
+
+// setList replaces the underlying list of v. The change is written through to
+// the original list if it is addressable, or to the container (map entry or
+// context variable) the list was taken from.
+func (v *GenericValue) setList(list reflect.Value) {
+	switch {
+	case v.IndirectValue.CanSet():
+		v.IndirectValue.Set(list)
+		return
+	case v.Value.Kind() == reflect.Interface && v.Value.CanSet():
+		v.Value.Set(list)
+	default:
+		v.assign(list)
+		v.Value = list
+	}
+	v.IndirectValue = list
+}
+
+// listAppend appends an item to the end of the list.
+func listAppend(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'append': %s", p.Error())
+	}
+	list := v.mutableList("append")
+	elemType := list.Type().Elem()
+	v.setList(reflect.Append(list, toReflectValue(p.First(), elemType)))
+	return v.valueFactory.Value(nil)
+}
+
+// listExtend appends all items of an iterable to the end of the list.
+func listExtend(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'extend': %s", p.Error())
+	}
+	list := v.mutableList("extend")
+	elemType := list.Type().Elem()
+	p.First().Iterate(func(idx, count int, key, value Value) bool {
+		list = reflect.Append(list, toReflectValue(key, elemType))
+		return true
+	}, func() {})
+	v.setList(list)
+	return v.valueFactory.Value(nil)
+}
+
+// listPop removes the item at the given index (default last) from the list
+// and returns it.
+func listPop(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(0, []*Kwarg{{Name: "index", Default: -1}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'pop': %s", p.Error())
+	}
+	list := v.mutableList("pop")
+	length := list.Len()
+	index := p.GetKwarg("index").Integer()
+	if index < 0 {
+		index += length
+	}
+	if index < 0 || index >= length {
+		errors.ThrowTemplateRuntimeError("pop index out of range")
+	}
+	item := v.valueFactory.Value(list.Index(index).Interface())
+	popped := reflect.MakeSlice(list.Type(), 0, length-1)
+	popped = reflect.AppendSlice(popped, list.Slice(0, index))
+	popped = reflect.AppendSlice(popped, list.Slice(index+1, length))
+	v.setList(popped)
+	return item
+}
+
+// listItems returns the items of the list.
+func (v *GenericValue) listItems() ValuesList {
+	if items, ok := v.IndirectValue.Interface().(ValuesList); ok {
+		return items
+	}
+	list := v.IndirectValue
+	items := make(ValuesList, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		items = append(items, v.valueFactory.Value(list.Index(i).Interface()))
+	}
+	return items
+}
+
+// listClear removes all items from the list.
+func listClear(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'clear': %s", p.Error())
+	}
+	list := v.mutableList("clear")
+	v.setList(reflect.MakeSlice(list.Type(), 0, 0))
+	return v.valueFactory.Value(nil)
+}
+
+// listCopy returns a shallow copy of the list.
+func listCopy(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'copy': %s", p.Error())
+	}
+	list := v.IndirectValue
+	copied := reflect.MakeSlice(reflect.SliceOf(list.Type().Elem()), 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		copied = reflect.Append(copied, list.Index(i))
+	}
+	return v.valueFactory.Value(copied.Interface())
+}
+
+// listCount returns the number of occurrences of an item.
+func listCount(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'count': %s", p.Error())
+	}
+	count := 0
+	for _, item := range v.listItems() {
+		if item.EqualValueTo(p.First()) {
+			count++
+		}
+	}
+	return v.valueFactory.Value(count)
+}
+
+// listIndex returns the index of the first occurrence of an item or throws an
+// error, if the list doesn't contain the item.
+func listIndex(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(1, spanKwargs())
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'index': %s", p.Error())
+	}
+	items := v.listItems()
+	start, end := 0, len(items)
+	if arg := p.GetKwarg("start"); !arg.IsNil() {
+		start = clampIndex(arg.Integer(), len(items))
+	}
+	if arg := p.GetKwarg("end"); !arg.IsNil() {
+		end = clampIndex(arg.Integer(), len(items))
+	}
+	for i := start; i < end; i++ {
+		if items[i].EqualValueTo(p.First()) {
+			return v.valueFactory.Value(i)
+		}
+	}
+	errors.ThrowTemplateRuntimeError("%s is not in list", p.First().String())
+	return nil
+}
+
+// listInsert inserts an item before the given index.
+func listInsert(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(2)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'insert': %s", p.Error())
+	}
+	list := v.mutableList("insert")
+	index := clampIndex(p.Args[0].Integer(), list.Len())
+	inserted := reflect.MakeSlice(list.Type(), 0, list.Len()+1)
+	inserted = reflect.AppendSlice(inserted, list.Slice(0, index))
+	inserted = reflect.Append(inserted, toReflectValue(p.Args[1], list.Type().Elem()))
+	inserted = reflect.AppendSlice(inserted, list.Slice(index, list.Len()))
+	v.setList(inserted)
+	return v.valueFactory.Value(nil)
+}
+
+// listRemove removes the first occurrence of an item or throws an error, if
+// the list doesn't contain the item.
+func listRemove(v *GenericValue, va *VarArgs) Value {
+	p := va.ExpectArgs(1)
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'remove': %s", p.Error())
+	}
+	list := v.mutableList("remove")
+	for i, item := range v.listItems() {
+		if item.EqualValueTo(p.First()) {
+			removed := reflect.MakeSlice(list.Type(), 0, list.Len()-1)
+			removed = reflect.AppendSlice(removed, list.Slice(0, i))
+			removed = reflect.AppendSlice(removed, list.Slice(i+1, list.Len()))
+			v.setList(removed)
+			return v.valueFactory.Value(nil)
+		}
+	}
+	errors.ThrowTemplateRuntimeError("list.remove(x): x not in list")
+	return nil
+}
+
+// listReverse reverses the list in place.
+func listReverse(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'reverse': %s", p.Error())
+	}
+	list := v.mutableList("reverse")
+	swap := reflect.Swapper(list.Interface())
+	for i, j := 0, list.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+	return v.valueFactory.Value(nil)
+}
+
+// listSort sorts the list in place. Unlike the `sort` filter, strings are
+// compared case-sensitively.
+func listSort(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(0, []*Kwarg{{Name: "reverse", Default: false}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'sort': %s", p.Error())
+	}
+	list := v.mutableList("sort")
+	items := append(ValuesList{}, v.listItems()...)
+	sorter := sortValuesList(items, true)
+	if p.GetKwarg("reverse").Bool() {
+		sorter = sort.Reverse(sorter)
+	}
+	sort.Stable(sorter)
+	elemType := list.Type().Elem()
+	for i, item := range items {
+		list.Index(i).Set(toReflectValue(item, elemType))
+	}
+	return v.valueFactory.Value(nil)
+}
+
+// mutableList returns the underlying list of v or throws an error, if the list
+// can't be modified.
+func (v *GenericValue) mutableList(method string) reflect.Value {
+	list := v.IndirectValue
+	if list.Kind() != reflect.Slice {
+		errors.ThrowTemplateRuntimeError("'%s' can't be used on a fixed-size array", method)
+	}
+	writable := list.CanSet() || v.assign != nil ||
+		(v.Value.Kind() == reflect.Interface && v.Value.CanSet())
+	if !writable {
+		errors.ThrowTemplateRuntimeError("'%s' can't be used on a list that is not stored in a variable, list or dict", method)
+	}
+	return list
+}
+
+// -----------------------------------------------------------------------------
+// Dict Methods
+// -----------------------------------------------------------------------------
+
+// lookup returns the value for the given key from the underlying dict, ordered
+// map or map.
+func (v *GenericValue) lookup(key Value) (Value, bool) {
+	if m, ok := v.orderedMap(); ok {
+		item, found := m.Load(mapEntry(m, key))
+		if !found {
+			return nil, false
+		}
+		return v.valueFactory.Value(item), true
+	}
+	item := mapIndex(v.IndirectValue, key.Interface())
+	if !item.IsValid() {
+		return nil, false
+	}
+	return v.valueFactory.Value(item.Interface()), true
+}
+
+// dictPairs returns the key/value pairs of the dict. The keys of maps, which
+// have no order, are sorted.
+func (v *GenericValue) dictPairs() []*Pair {
+	if m, ok := v.orderedMap(); ok {
+		return v.orderedPairs(m)
+	}
+	pairs := []*Pair{}
+	v.IterateOrder(func(idx, count int, key, value Value) bool {
+		pairs = append(pairs, &Pair{Key: key, Value: value})
+		return true
+	}, func() {}, false, true, true)
+	return pairs
+}
+
+// dictClear removes all items from the dict.
+func dictClear(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'clear': %s", p.Error())
+	}
+	if m, ok := v.orderedMap(); ok {
+		for _, pair := range v.orderedPairs(m) {
+			m.Delete(mapEntry(m, pair.Key))
+		}
+		return v.valueFactory.Value(nil)
+	}
+	m := v.IndirectValue
+	for _, key := range m.MapKeys() {
+		m.SetMapIndex(key, reflect.Value{})
+	}
+	return v.valueFactory.Value(nil)
+}
+
+// dictCopy returns a shallow copy of the dict.
+func dictCopy(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'copy': %s", p.Error())
+	}
+	if m, ok := v.orderedMap(); ok {
+		return v.valueFactory.Value(&Dict{Pairs: v.orderedPairs(m)})
+	}
+	m := v.IndirectValue
+	copied := reflect.MakeMapWithSize(m.Type(), m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		copied.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return v.valueFactory.Value(copied.Interface())
+}
+
+// dictGet returns the value for the given key or the default value, if the
+// dict has no such key.
+func dictGet(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(1, []*Kwarg{{Name: "default", Default: nil}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'get': %s", p.Error())
+	}
+	if value, ok := v.lookup(p.First()); ok {
+		return value
+	}
+	return p.GetKwarg("default")
+}
+
+// dictItems returns the key/value pairs of the dict.
+func dictItems(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'items': %s", p.Error())
+	}
+	items := [][2]Value{}
+	for _, pair := range v.dictPairs() {
+		items = append(items, [2]Value{pair.Key, pair.Value})
+	}
+	return v.valueFactory.Value(items)
+}
+
+// dictKeys returns the keys of the dict.
+func dictKeys(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'keys': %s", p.Error())
+	}
+	keys := ValuesList{}
+	for _, pair := range v.dictPairs() {
+		keys = append(keys, pair.Key)
+	}
+	return v.valueFactory.Value(keys)
+}
+
+// dictValues returns the values of the dict.
+func dictValues(v *GenericValue, va *VarArgs) Value {
+	if p := va.ExpectNothing(); p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'values': %s", p.Error())
+	}
+	values := ValuesList{}
+	for _, pair := range v.dictPairs() {
+		values = append(values, pair.Value)
+	}
+	return v.valueFactory.Value(values)
+}
+
+// dictPop removes the given key from the dict and returns its value. If the key
+// doesn't exist, the default value is returned or an error is thrown, if there
+// is no default value.
+func dictPop(v *GenericValue, va *VarArgs) Value {
+	if len(va.Args) < 1 || len(va.Args) > 2 || len(va.Kwargs) > 0 {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'pop': expected a key and an optional default value")
+	}
+	key := va.Args[0]
+	if m, ok := v.orderedMap(); ok {
+		if item, ok := m.Load(mapEntry(m, key)); ok {
+			m.Delete(mapEntry(m, key))
+			return v.valueFactory.Value(item)
+		}
+	} else {
+		m := v.IndirectValue
+		keyVal := toReflectValue(key, m.Type().Key())
+		if item := m.MapIndex(keyVal); item.IsValid() {
+			m.SetMapIndex(keyVal, reflect.Value{})
+			return v.valueFactory.Value(item.Interface())
+		}
+	}
+	if len(va.Args) == 2 {
+		return va.Args[1]
+	}
+	errors.ThrowTemplateRuntimeError("dict has no key '%s'", key.String())
+	return nil
+}
+
+// dictSetdefault returns the value of the given key. If the key doesn't exist,
+// it is inserted with the default value first.
+func dictSetdefault(v *GenericValue, va *VarArgs) Value {
+	p := va.Expect(1, []*Kwarg{{Name: "default", Default: nil}})
+	if p.IsError() {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'setdefault': %s", p.Error())
+	}
+	key := p.First()
+	if value, ok := v.lookup(key); ok {
+		return value
+	}
+	value := p.GetKwarg("default")
+	v.setDictItem(key, value)
+	return value
+}
+
+// dictUpdate updates the dict with the key/value pairs of another dict and the
+// given keyword arguments.
+func dictUpdate(v *GenericValue, va *VarArgs) Value {
+	if len(va.Args) > 1 {
+		errors.ThrowTemplateRuntimeError("wrong signature for 'update': expected at most 1 argument, got %d", len(va.Args))
+	}
+	if len(va.Args) == 1 {
+		other := va.Args[0]
+		if !other.IsDict() {
+			errors.ThrowTemplateRuntimeError("'update' expects a dict, got '%s'", other.String())
+		}
+		other.Iterate(func(idx, count int, key, value Value) bool {
+			v.setDictItem(key, value)
+			return true
+		}, func() {})
+	}
+	for _, kv := range va.Kwargs {
+		v.setDictItem(v.valueFactory.Value(kv.Key), kv.Value)
+	}
+	return v.valueFactory.Value(nil)
+}
+
+// setDictItem sets the value for the given key in the underlying dict, ordered
+// map or map.
+func (v *GenericValue) setDictItem(key, value Value) {
+	if m, ok := v.orderedMap(); ok {
+		m.Store(mapEntry(m, key), mapEntry(m, value))
+		return
+	}
+	m := v.IndirectValue
+	if m.IsNil() {
+		errors.ThrowTemplateRuntimeError("can't set item on nil map")
+	}
+	m.SetMapIndex(toReflectValue(key, m.Type().Key()), toReflectValue(value, m.Type().Elem()))
+}
+
+// toReflectValue converts a value into a reflect value of the given type, so
+// it can be stored in a list or map of that type.
+func toReflectValue(value Value, typ reflect.Type) reflect.Value {
+	if typ == rtValue {
+		return reflect.ValueOf(&value).Elem()
+	}
+	val := reflect.ValueOf(value.Interface())
+	if !val.IsValid() {
+		return reflect.Zero(typ)
+	}
+	if val.Type().AssignableTo(typ) {
+		return val
+	}
+	if (isIntegerKind(val.Kind()) && isIntegerKind(typ.Kind())) ||
+		(val.Kind() == reflect.String && typ.Kind() == reflect.String) ||
+		(val.Kind() == reflect.Float64 && (typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64)) {
+		return val.Convert(typ)
+	}
+	errors.ThrowTemplateRuntimeError("can't use '%s' as value of type %s", value.String(), typ)
+	return reflect.Value{}
+}
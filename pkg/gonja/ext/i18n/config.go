@@ -0,0 +1,51 @@
+package i18n
+
+import (
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"github.com/aisbergg/gonja/pkg/gonja/ext"
+)
+
+// Config is the configuration of the i18n extension.
+type Config struct {
+	// Translations provides the translated messages.
+	Translations Translations
+}
+
+// NewConfig creates a new configuration using the given translations. If
+// translations is nil, messages are not translated.
+func NewConfig(translations Translations) *Config {
+	if translations == nil {
+		translations = NullTranslations{}
+	}
+	return &Config{
+		Translations: translations,
+	}
+}
+
+// Inherit creates a copy of the configuration.
+func (cfg *Config) Inherit() ext.Inheritable {
+	return &Config{
+		Translations: cfg.Translations,
+	}
+}
+
+// Install installs the i18n extension with the given translations into the
+// evaluation config (e.g. the one of a gonja.Environment). It registers the
+// `trans` statement and the gettext globals.
+func Install(cfg *exec.EvalConfig, translations Translations) {
+	extCfg := NewConfig(translations)
+	cfg.Statements.Update(Statements)
+	for name, fn := range Globals(extCfg.Translations) {
+		cfg.Globals[name] = fn
+	}
+	cfg.ExtensionConfig["i18n"] = extCfg
+}
+
+// getConfig returns the configuration of the extension or a default
+// configuration, if the extension is not configured.
+func getConfig(cfg *exec.EvalConfig) *Config {
+	if extCfg, ok := cfg.ExtensionConfig["i18n"].(*Config); ok {
+		return extCfg
+	}
+	return NewConfig(nil)
+}
@@ -0,0 +1,18 @@
+// Package i18n provides the internationalization extension known from Jinja2.
+// It adds the `{% trans %}` statement, the gettext globals (`_`, `gettext`,
+// `ngettext`, `pgettext` and `npgettext`), translation catalogs loaded from GNU
+// gettext `.po` and `.mo` files and the extraction of translatable messages
+// from templates into a `.pot` file.
+//
+// Example:
+//
+//	catalog, err := i18n.LoadCatalog("locale/de/LC_MESSAGES/messages.mo")
+//	if err != nil {
+//		return err
+//	}
+//	env := gonja.NewEnvironment(...)
+//	i18n.Install(env.EvalConfig, catalog)
+//
+// Since the translations are bound to the environment, use one environment
+// per language.
+package i18n
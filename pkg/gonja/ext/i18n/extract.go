@@ -0,0 +1,230 @@
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/parse"
+)
+
+// Message is a translatable message found in a template.
+type Message struct {
+	Context string
+	ID      string
+	// Plural is the plural form of the message, if any.
+	Plural string
+	// Lines are the lines of the template the message occurs on.
+	Lines []int
+}
+
+// gettextFuncs maps the names of the gettext functions to the meaning of their
+// string arguments.
+var gettextFuncs = map[string][]string{
+	"_":         {"id"},
+	"gettext":   {"id"},
+	"ngettext":  {"id", "plural"},
+	"pgettext":  {"context", "id"},
+	"npgettext": {"context", "id", "plural"},
+}
+
+var (
+	rtTemplateNode = reflect.TypeOf((*parse.TemplateNode)(nil))
+	rtToken        = reflect.TypeOf((*parse.Token)(nil))
+	rtTransStmt    = reflect.TypeOf((*TransStmt)(nil))
+	rtCallNode     = reflect.TypeOf((*parse.CallNode)(nil))
+	rtNameNode     = reflect.TypeOf((*parse.NameNode)(nil))
+	rtStringNode   = reflect.TypeOf((*parse.StringNode)(nil))
+)
+
+// Extract returns the translatable messages of a parsed template. Messages are
+// taken from `{% trans %}` sections and from calls of the gettext functions
+// with string literals as arguments. Equal messages are merged.
+func Extract(tpl *parse.TemplateNode) []Message {
+	e := &extractor{
+		visited: map[uintptr]bool{},
+		index:   map[string]int{},
+	}
+	e.walk(reflect.ValueOf(tpl))
+
+	sort.SliceStable(e.messages, func(i, j int) bool {
+		return e.messages[i].Lines[0] < e.messages[j].Lines[0]
+	})
+	return e.messages
+}
+
+// extractor walks the node tree and collects the messages. Statements keep
+// their nodes in unexported fields, so the tree is walked using reflection only
+// (i.e. without calling Interface()).
+type extractor struct {
+	visited  map[uintptr]bool
+	messages []Message
+	// index maps a message key to the position in messages
+	index map[string]int
+}
+
+func (e *extractor) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || v.Type() == rtToken {
+			return
+		}
+		// templates reference their parents; only walk the template itself
+		if v.Type() == rtTemplateNode && len(e.visited) > 0 {
+			return
+		}
+		if e.visited[v.Pointer()] {
+			return
+		}
+		e.visited[v.Pointer()] = true
+		e.visit(v)
+		e.walk(v.Elem())
+
+	case reflect.Interface:
+		if !v.IsNil() {
+			e.walk(v.Elem())
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			e.walk(v.Field(i))
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e.walk(v.Index(i))
+		}
+
+	case reflect.Map:
+		// sort the keys for a deterministic order of the lines
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, key := range keys {
+			e.walk(v.MapIndex(key))
+		}
+	}
+}
+
+// visit adds the message of a node, if it is translatable.
+func (e *extractor) visit(node reflect.Value) {
+	switch node.Type() {
+	case rtTransStmt:
+		stmt := node.Elem()
+		e.add(Message{
+			Context: stmt.FieldByName("Context").String(),
+			ID:      stmt.FieldByName("Singular").String(),
+			Plural:  stmt.FieldByName("Plural").String(),
+			Lines:   []int{line(stmt)},
+		})
+
+	case rtCallNode:
+		call := node.Elem()
+		fn := call.FieldByName("Func").Elem()
+		if fn.Type() != rtNameNode {
+			return
+		}
+		params, ok := gettextFuncs[fn.Elem().FieldByName("Name").Elem().FieldByName("Val").String()]
+		args := call.FieldByName("Args")
+		if !ok || args.Len() < len(params) {
+			return
+		}
+		msg := Message{Lines: []int{line(call)}}
+		for i, param := range params {
+			arg := args.Index(i).Elem()
+			if arg.Type() != rtStringNode {
+				return
+			}
+			str := arg.Elem().FieldByName("Val").String()
+			switch param {
+			case "context":
+				msg.Context = str
+			case "id":
+				msg.ID = str
+			case "plural":
+				msg.Plural = str
+			}
+		}
+		e.add(msg)
+	}
+}
+
+// line returns the line of a node with a `Location` token.
+func line(node reflect.Value) int {
+	return int(node.FieldByName("Location").Elem().FieldByName("Line").Int())
+}
+
+func (e *extractor) add(msg Message) {
+	if msg.ID == "" {
+		return
+	}
+	key := catalogKey(msg.Context, msg.ID)
+	if idx, exists := e.index[key]; exists {
+		existing := &e.messages[idx]
+		existing.Lines = append(existing.Lines, msg.Lines...)
+		sort.Ints(existing.Lines)
+		if existing.Plural == "" {
+			existing.Plural = msg.Plural
+		}
+		return
+	}
+	e.index[key] = len(e.messages)
+	e.messages = append(e.messages, msg)
+}
+
+// WritePOT writes the messages as gettext template (`.pot` file). The filename
+// is used in the location comments of the messages.
+func WritePOT(w io.Writer, filename string, messages []Message) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("msgid \"\"\n")
+	bw.WriteString("msgstr \"\"\n")
+	bw.WriteString("\"Content-Type: text/plain; charset=UTF-8\\n\"\n")
+	bw.WriteString("\"Content-Transfer-Encoding: 8bit\\n\"\n")
+
+	for _, msg := range messages {
+		bw.WriteString("\n#:")
+		for _, line := range msg.Lines {
+			fmt.Fprintf(bw, " %s:%d", filename, line)
+		}
+		bw.WriteString("\n")
+		if strings.Contains(msg.ID, "%(") || strings.Contains(msg.Plural, "%(") {
+			bw.WriteString("#, python-format\n")
+		}
+		if msg.Context != "" {
+			writePOString(bw, "msgctxt", msg.Context)
+		}
+		writePOString(bw, "msgid", msg.ID)
+		if msg.Plural != "" {
+			writePOString(bw, "msgid_plural", msg.Plural)
+			bw.WriteString("msgstr[0] \"\"\n")
+			bw.WriteString("msgstr[1] \"\"\n")
+		} else {
+			bw.WriteString("msgstr \"\"\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// poEscaper escapes strings for the `.po` format.
+var poEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+// writePOString writes a keyword with a quoted string. Multi-line strings are
+// split into one quoted string per line.
+func writePOString(w *bufio.Writer, keyword, s string) {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 1 {
+		fmt.Fprintf(w, "%s \"%s\"\n", keyword, poEscaper.Replace(s))
+		return
+	}
+	fmt.Fprintf(w, "%s \"\"\n", keyword)
+	for _, line := range lines {
+		fmt.Fprintf(w, "\"%s\"\n", poEscaper.Replace(line))
+	}
+}
@@ -0,0 +1,117 @@
+package i18n
+
+import (
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+)
+
+// Globals returns the gettext functions `_`, `gettext`, `ngettext`,
+// `pgettext` and `npgettext` using the given translations. Keyword arguments
+// are substituted into `%(name)s` placeholders of the translated message, e.g.
+// `_('Hello %(name)s!', name=user.name)`. The plural functions additionally
+// provide the count as `num`.
+func Globals(t Translations) map[string]any {
+	gettext := func(va *exec.VarArgs) exec.Value {
+		args := expectStrings(va, "gettext", 1)
+		return formatVarArgs(va, t.Gettext(args[0]), nil)
+	}
+	ngettext := func(va *exec.VarArgs) exec.Value {
+		args := expectStrings(va, "ngettext", 2)
+		n := va.Args[2]
+		return formatVarArgs(va, t.Ngettext(args[0], args[1], n.Integer()), n)
+	}
+	pgettext := func(va *exec.VarArgs) exec.Value {
+		args := expectStrings(va, "pgettext", 2)
+		return formatVarArgs(va, t.Pgettext(args[0], args[1]), nil)
+	}
+	npgettext := func(va *exec.VarArgs) exec.Value {
+		args := expectStrings(va, "npgettext", 3)
+		n := va.Args[3]
+		return formatVarArgs(va, t.Npgettext(args[0], args[1], args[2], n.Integer()), n)
+	}
+
+	return map[string]any{
+		"_":         gettext,
+		"gettext":   gettext,
+		"ngettext":  ngettext,
+		"pgettext":  pgettext,
+		"npgettext": npgettext,
+	}
+}
+
+// expectStrings checks the positional arguments of a gettext function and
+// returns the leading strings. The plural functions take the count as an
+// additional argument after the strings.
+func expectStrings(va *exec.VarArgs, name string, strs int) []string {
+	expected := strs
+	if strings.HasPrefix(name, "n") {
+		expected++
+	}
+	if len(va.Args) != expected {
+		errors.ThrowTemplateRuntimeError("wrong signature for '%s': expected %d arguments, got %d", name, expected, len(va.Args))
+	}
+	args := make([]string, strs)
+	for i := range args {
+		if !va.Args[i].IsString() {
+			errors.ThrowTemplateRuntimeError("wrong signature for '%s': argument %d must be a string", name, i+1)
+		}
+		args[i] = va.Args[i].String()
+	}
+	return args
+}
+
+// formatVarArgs substitutes the keyword arguments and the count (if not nil)
+// into the message. Messages without any variables are returned unchanged.
+func formatVarArgs(va *exec.VarArgs, message string, num exec.Value) exec.Value {
+	if len(va.Kwargs) == 0 && num == nil {
+		return va.ValueFactory.Value(message)
+	}
+	message = formatMessage(message, func(name string) (string, bool) {
+		if va.HasKwarg(name) {
+			return va.GetKwarg(name).String(), true
+		}
+		if name == "num" && num != nil {
+			return num.String(), true
+		}
+		return "", false
+	})
+	return va.ValueFactory.Value(message)
+}
+
+// formatMessage replaces the `%(name)s` placeholders of a message with the
+// variables returned by lookup and unescapes `%%`.
+func formatMessage(message string, lookup func(name string) (string, bool)) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c != '%' || i == len(message)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		if message[i+1] == '%' {
+			b.WriteByte('%')
+			i++
+			continue
+		}
+		if message[i+1] != '(' {
+			b.WriteByte(c)
+			continue
+		}
+		end := strings.IndexByte(message[i:], ')')
+		if end < 0 || i+end+1 >= len(message) {
+			b.WriteByte(c)
+			continue
+		}
+		name := message[i+2 : i+end]
+		value, ok := lookup(name)
+		if !ok {
+			errors.ThrowTemplateRuntimeError("translation references undefined variable '%s'", name)
+		}
+		b.WriteString(value)
+		// skip the conversion character, e.g. 's' in '%(name)s'
+		i += end + 1
+	}
+	return b.String()
+}
@@ -0,0 +1,9 @@
+module github.com/aisbergg/gonja/pkg/gonja/ext/i18n
+
+go 1.19
+
+require github.com/aisbergg/gonja v0.0.0
+
+require golang.org/x/text v0.9.0 // indirect
+
+replace github.com/aisbergg/gonja => ./../../../../
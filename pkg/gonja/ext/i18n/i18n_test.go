@@ -0,0 +1,207 @@
+package i18n_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/aisbergg/gonja/internal/testutils"
+	"github.com/aisbergg/gonja/pkg/gonja"
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/ext/i18n"
+)
+
+func Env(t *testing.T, root string) *gonja.Environment {
+	catalog, err := i18n.LoadCatalog("./testdata/de.po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := testutils.TestEnv(root)
+	i18n.Install(env.EvalConfig, catalog)
+	return env
+}
+
+func TestI18nTemplates(t *testing.T) {
+	root := "./testdata"
+	env := Env(t, root)
+	testutils.GlobTemplateTests(t, root, env)
+}
+
+func TestTransErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		err      string
+	}{
+		{"control structure", "{% trans %}{% if true %}x{% endif %}{% endtrans %}", "control structures in translatable sections are not allowed"},
+		{"expression", "{% trans %}{{ user.name }}{% endtrans %}", "only simple variables are allowed in translatable sections"},
+		{"pluralize without variables", "{% trans %}apple{% pluralize %}apples{% endtrans %}", "pluralize without variables"},
+		{"variable defined twice", "{% trans a=1, a=2 %}{% endtrans %}", "translatable variable 'a' defined twice"},
+		{"failed with pluralize", "{% trans a=1, a=2 %}{{ a }} apple{% pluralize %}{{ a }} apples{% endtrans %}", "translatable variable 'a' defined twice"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := testutils.NewAssert(t)
+			env := Env(t, "./testdata")
+			_, err := env.FromString(test.template)
+			if assert.Error(err) {
+				assert.True(strings.Contains(err.Error(), test.err), "unexpected error: %s", err)
+				_, multiple := err.(errors.TemplateSyntaxErrors)
+				assert.False(multiple, "expected a single error, got: %s", err)
+			}
+		})
+	}
+}
+
+// buildMO creates a little endian `.mo` file with the given messages.
+func buildMO(messages [][2]string) []byte {
+	const headerSize = 28
+	n := len(messages)
+	origTable := headerSize
+	transTable := origTable + n*8
+	offset := transTable + n*8
+
+	var table, strs bytes.Buffer
+	addStrings := func(idx int) {
+		for _, msg := range messages {
+			binary.Write(&table, binary.LittleEndian, uint32(len(msg[idx])))
+			binary.Write(&table, binary.LittleEndian, uint32(offset+strs.Len()))
+			strs.WriteString(msg[idx])
+			strs.WriteByte(0)
+		}
+	}
+	addStrings(0)
+	addStrings(1)
+
+	var buf bytes.Buffer
+	for _, v := range []uint32{0x950412de, 0, uint32(n), uint32(origTable), uint32(transTable), 0, 0} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.Write(table.Bytes())
+	buf.Write(strs.Bytes())
+	return buf.Bytes()
+}
+
+func TestParseMO(t *testing.T) {
+	assert := testutils.NewAssert(t)
+	data := buildMO([][2]string{
+		{"", "Content-Type: text/plain; charset=UTF-8\nPlural-Forms: nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"},
+		{"Hello World!", "Witaj świecie!"},
+		{"apple\x00apples", "jabłko\x00jabłka\x00jabłek"},
+		{"month\x04May", "maj"},
+	})
+	catalog, err := i18n.ParseMO(bytes.NewReader(data))
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("Witaj świecie!", catalog.Gettext("Hello World!"))
+	assert.Equal("Untranslated", catalog.Gettext("Untranslated"))
+	assert.Equal("jabłko", catalog.Ngettext("apple", "apples", 1))
+	assert.Equal("jabłka", catalog.Ngettext("apple", "apples", 3))
+	assert.Equal("jabłek", catalog.Ngettext("apple", "apples", 5))
+	assert.Equal("jabłka", catalog.Ngettext("apple", "apples", 22))
+	assert.Equal("maj", catalog.Pgettext("month", "May"))
+	assert.Equal("May", catalog.Gettext("May"))
+
+	_, err = i18n.ParseMO(bytes.NewReader([]byte("not a mo file at all")))
+	assert.Error(err)
+}
+
+func TestParsePO(t *testing.T) {
+	assert := testutils.NewAssert(t)
+	catalog, err := i18n.ParsePO(strings.NewReader(`
+msgid "Line\none"
+msgstr "Zeile\neins \"zitiert\""
+
+msgid "missing"
+msgstr ""
+`))
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("Zeile\neins \"zitiert\"", catalog.Gettext("Line\none"))
+	assert.Equal("missing", catalog.Gettext("missing"))
+
+	_, err = i18n.ParsePO(strings.NewReader("msgid \"a\"\nmsgfoo \"b\"\n"))
+	assert.True(err != nil && strings.Contains(err.Error(), "line 2: unknown keyword 'msgfoo'"))
+}
+
+func TestParsePluralForms(t *testing.T) {
+	assert := testutils.NewAssert(t)
+	tests := []struct {
+		header   string
+		expected []int // plural forms for n = 0..5
+	}{
+		{"nplurals=2; plural=(n != 1);", []int{1, 0, 1, 1, 1, 1}},
+		{"nplurals=1; plural=0;", []int{0, 0, 0, 0, 0, 0}},
+		{"nplurals=2; plural=n>1;", []int{0, 0, 1, 1, 1, 1}},
+		{"nplurals=3; plural=n==0 ? 0 : n==1 ? 1 : 2;", []int{0, 1, 2, 2, 2, 2}},
+		{"nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);", []int{2, 0, 1, 1, 1, 2}},
+		{"nplurals=2; plural=!(n == 1);", []int{1, 0, 1, 1, 1, 1}},
+	}
+	for _, test := range tests {
+		fn, err := i18n.ParsePluralForms(test.header)
+		if !assert.NoError(err, test.header) {
+			continue
+		}
+		for n, expected := range test.expected {
+			assert.Equal(expected, fn(n), "%s with n=%d", test.header, n)
+		}
+	}
+
+	for _, header := range []string{"nplurals=2;", "plural=(n != 1;", "plural=n ? 1;", "plural=x;"} {
+		_, err := i18n.ParsePluralForms(header)
+		assert.Error(err, header)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	assert := testutils.NewAssert(t)
+	env := Env(t, "./testdata")
+	tpl, err := env.FromString(`{% trans user=name %}Hello {{ user }}!{% endtrans %}
+{{ _('Hello World!') }}
+{% if true %}
+  {% trans count=items|length %}One item{% pluralize %}{{ count }} items{% endtrans %}
+{% endif %}
+{% macro title() %}{{ pgettext('month', 'May') }}{% endmacro %}
+{{ _('Hello World!') }} {{ _(variable) }}
+{{ ngettext('%(num)s apple', '%(num)s apples', 3) }}
+{% trans trimmed %}
+  Multiple
+  lines
+{% endtrans %}`)
+	if !assert.NoError(err) {
+		return
+	}
+
+	messages := i18n.Extract(tpl.Root)
+	assert.Equal([]i18n.Message{
+		{ID: "Hello %(user)s!", Lines: []int{1}},
+		{ID: "Hello World!", Lines: []int{2, 7}},
+		{ID: "One item", Plural: "%(count)s items", Lines: []int{4}},
+		{Context: "month", ID: "May", Lines: []int{6}},
+		{ID: "%(num)s apple", Plural: "%(num)s apples", Lines: []int{8}},
+		{ID: "Multiple lines", Lines: []int{9}},
+	}, messages)
+
+	var buf bytes.Buffer
+	assert.NoError(i18n.WritePOT(&buf, "mail.html", messages[2:4]))
+	assert.Equal(`msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+"Content-Transfer-Encoding: 8bit\n"
+
+#: mail.html:4
+#, python-format
+msgid "One item"
+msgid_plural "%(count)s items"
+msgstr[0] ""
+msgstr[1] ""
+
+#: mail.html:6
+msgctxt "month"
+msgid "May"
+msgstr ""
+`, buf.String())
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// moMagic is the magic number at the start of a `.mo` file.
+const moMagic = 0x950412de
+
+// ParseMO parses a catalog in the binary GNU gettext `.mo` format.
+func ParseMO(r io.Reader) (*Catalog, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 20 {
+		return nil, errors.New("invalid mo file: file too short")
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(data) == moMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(data) == moMagic:
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("invalid mo file: bad magic number")
+	}
+
+	count := int(order.Uint32(data[8:]))
+	origTable := int(order.Uint32(data[12:]))
+	transTable := int(order.Uint32(data[16:]))
+
+	// str returns the idx-th string of the table at the given offset
+	str := func(table, idx int) (string, error) {
+		entry := table + idx*8
+		if entry < 0 || entry+8 > len(data) {
+			return "", errors.New("invalid mo file: string table out of range")
+		}
+		length := int(order.Uint32(data[entry:]))
+		offset := int(order.Uint32(data[entry+4:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return "", errors.New("invalid mo file: string out of range")
+		}
+		return string(data[offset : offset+length]), nil
+	}
+
+	catalog := NewCatalog()
+	for i := 0; i < count; i++ {
+		orig, err := str(origTable, i)
+		if err != nil {
+			return nil, err
+		}
+		trans, err := str(transTable, i)
+		if err != nil {
+			return nil, err
+		}
+
+		if orig == "" {
+			if err := catalog.setHeader(trans); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var context string
+		if ctx, id, found := strings.Cut(orig, contextSeparator); found {
+			context, orig = ctx, id
+		}
+		// plural messages are stored as "singular\x00plural"
+		id, _, _ := strings.Cut(orig, "\x00")
+		catalog.Add(context, id, strings.Split(trans, "\x00")...)
+	}
+	return catalog, nil
+}
@@ -0,0 +1,231 @@
+package i18n
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PluralFunc returns the index of the plural form to use for the number n.
+type PluralFunc func(n int) int
+
+// germanicPlural is the plural rule of English, German and many other
+// languages.
+func germanicPlural(n int) int {
+	if n == 1 {
+		return 0
+	}
+	return 1
+}
+
+// ParsePluralForms parses the value of a `Plural-Forms` header like
+// `nplurals=2; plural=(n != 1);` and returns the plural rule as function.
+func ParsePluralForms(header string) (PluralFunc, error) {
+	var expr string
+	for _, part := range strings.Split(header, ";") {
+		name, value, found := strings.Cut(part, "=")
+		if found && strings.TrimSpace(name) == "plural" {
+			expr = value
+		}
+	}
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("plural forms '%s' define no plural expression", header)
+	}
+
+	p := &pluralParser{input: expr}
+	node, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid plural expression '%s': %s", strings.TrimSpace(expr), err)
+	}
+	return func(n int) int { return node(n) }, nil
+}
+
+// pluralExpr is a compiled (sub) expression of a plural rule.
+type pluralExpr func(n int) int
+
+// pluralParser is a recursive descent parser for the C-like expressions used
+// in plural rules.
+type pluralParser struct {
+	input string
+	pos   int
+}
+
+func (p *pluralParser) parse() (expr pluralExpr, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			perr, ok := rec.(pluralError)
+			if !ok {
+				panic(rec)
+			}
+			err = perr
+		}
+	}()
+	expr = p.parseTernary()
+	if p.skipSpace(); p.pos < len(p.input) {
+		p.fail("unexpected '%s'", p.input[p.pos:])
+	}
+	return expr, nil
+}
+
+// pluralError is thrown (panicked) on syntax errors in plural rules.
+type pluralError string
+
+func (e pluralError) Error() string { return string(e) }
+
+func (p *pluralParser) fail(format string, args ...any) {
+	panic(pluralError(fmt.Sprintf(format, args...)))
+}
+
+func (p *pluralParser) skipSpace() {
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// match consumes the given operator, if it is next in the input.
+func (p *pluralParser) match(op string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.input[p.pos:], op) {
+		return false
+	}
+	p.pos += len(op)
+	return true
+}
+
+func (p *pluralParser) parseTernary() pluralExpr {
+	cond := p.parseBinary(0)
+	if !p.match("?") {
+		return cond
+	}
+	then := p.parseTernary()
+	if !p.match(":") {
+		p.fail("expected ':'")
+	}
+	otherwise := p.parseTernary()
+	return func(n int) int {
+		if cond(n) != 0 {
+			return then(n)
+		}
+		return otherwise(n)
+	}
+}
+
+// pluralOperators lists the binary operators by increasing precedence. Longer
+// operators come first, so that '<=' isn't mistaken for '<'.
+var pluralOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *pluralParser) parseBinary(level int) pluralExpr {
+	if level >= len(pluralOperators) {
+		return p.parseUnary()
+	}
+	left := p.parseBinary(level + 1)
+	for {
+		op := ""
+		for _, candidate := range pluralOperators[level] {
+			if p.match(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left
+		}
+		left = binaryPluralExpr(op, left, p.parseBinary(level+1))
+	}
+}
+
+func binaryPluralExpr(op string, left, right pluralExpr) pluralExpr {
+	boolToInt := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	return func(n int) int {
+		l := left(n)
+		switch op {
+		case "||":
+			return boolToInt(l != 0 || right(n) != 0)
+		case "&&":
+			return boolToInt(l != 0 && right(n) != 0)
+		}
+		r := right(n)
+		switch op {
+		case "==":
+			return boolToInt(l == r)
+		case "!=":
+			return boolToInt(l != r)
+		case "<=":
+			return boolToInt(l <= r)
+		case ">=":
+			return boolToInt(l >= r)
+		case "<":
+			return boolToInt(l < r)
+		case ">":
+			return boolToInt(l > r)
+		case "+":
+			return l + r
+		case "-":
+			return l - r
+		case "*":
+			return l * r
+		case "/":
+			if r == 0 {
+				return 0
+			}
+			return l / r
+		default: // "%"
+			if r == 0 {
+				return 0
+			}
+			return l % r
+		}
+	}
+}
+
+func (p *pluralParser) parseUnary() pluralExpr {
+	if p.match("!") {
+		operand := p.parseUnary()
+		return func(n int) int {
+			if operand(n) == 0 {
+				return 1
+			}
+			return 0
+		}
+	}
+	return p.parsePrimary()
+}
+
+func (p *pluralParser) parsePrimary() pluralExpr {
+	p.skipSpace()
+	if p.match("(") {
+		expr := p.parseTernary()
+		if !p.match(")") {
+			p.fail("expected ')'")
+		}
+		return expr
+	}
+	if p.pos < len(p.input) && p.input[p.pos] == 'n' {
+		p.pos++
+		return func(n int) int { return n }
+	}
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos >= len(p.input) {
+			p.fail("unexpected end of expression")
+		}
+		p.fail("unexpected '%c'", p.input[p.pos])
+	}
+	value, _ := strconv.Atoi(p.input[start:p.pos])
+	return func(int) int { return value }
+}
@@ -0,0 +1,191 @@
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParsePO parses a catalog in the GNU gettext `.po` format. Fuzzy
+// translations are ignored.
+func ParsePO(r io.Reader) (*Catalog, error) {
+	p := &poParser{catalog: NewCatalog()}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		p.line++
+		if err := p.parseLine(strings.TrimSpace(scanner.Text())); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := p.flush(); err != nil {
+		return nil, err
+	}
+	return p.catalog, nil
+}
+
+// poEntry is a single entry of a `.po` file.
+type poEntry struct {
+	context      string
+	id           string
+	plural       string
+	translations []string
+	fuzzy        bool
+}
+
+// poParser parses a `.po` file line by line.
+type poParser struct {
+	catalog *Catalog
+	line    int
+	entry   *poEntry
+	// appendString appends a continuation line to the last keyword's string
+	appendString func(s string)
+	// inTranslation is true, if the current entry already has a msgstr
+	inTranslation bool
+}
+
+func (p *poParser) parseLine(line string) error {
+	switch {
+	case line == "":
+		return p.flush()
+
+	case strings.HasPrefix(line, "#"):
+		// comments belong to the following entry
+		if p.inTranslation {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		if strings.HasPrefix(line, "#,") && strings.Contains(line, "fuzzy") {
+			p.current().fuzzy = true
+		}
+		return nil
+
+	case strings.HasPrefix(line, `"`):
+		if p.appendString == nil {
+			return p.errorf("unexpected string %s", line)
+		}
+		s, err := p.unquote(line)
+		if err != nil {
+			return err
+		}
+		p.appendString(s)
+		return nil
+	}
+
+	keyword, rest, _ := strings.Cut(line, " ")
+	value, err := p.unquote(strings.TrimSpace(rest))
+	if err != nil {
+		return err
+	}
+
+	// a msgctxt or msgid after a msgstr starts a new entry
+	if (keyword == "msgctxt" || keyword == "msgid") && p.inTranslation {
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+	entry := p.current()
+
+	switch {
+	case keyword == "msgctxt":
+		entry.context = value
+		p.appendString = func(s string) { entry.context += s }
+
+	case keyword == "msgid":
+		entry.id = value
+		p.appendString = func(s string) { entry.id += s }
+
+	case keyword == "msgid_plural":
+		entry.plural = value
+		p.appendString = func(s string) { entry.plural += s }
+
+	case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+		idx := 0
+		if keyword != "msgstr" {
+			idx, err = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(keyword, "msgstr["), "]"))
+			if err != nil || idx < 0 {
+				return p.errorf("invalid keyword '%s'", keyword)
+			}
+		}
+		for len(entry.translations) <= idx {
+			entry.translations = append(entry.translations, "")
+		}
+		entry.translations[idx] = value
+		p.appendString = func(s string) { entry.translations[idx] += s }
+		p.inTranslation = true
+
+	default:
+		return p.errorf("unknown keyword '%s'", keyword)
+	}
+	return nil
+}
+
+// current returns the entry being parsed. A new entry is started if needed.
+func (p *poParser) current() *poEntry {
+	if p.entry == nil {
+		p.entry = &poEntry{}
+	}
+	return p.entry
+}
+
+// flush adds the current entry to the catalog.
+func (p *poParser) flush() error {
+	entry := p.entry
+	p.entry = nil
+	p.appendString = nil
+	p.inTranslation = false
+
+	if entry == nil || len(entry.translations) == 0 {
+		return nil
+	}
+	if entry.id == "" && entry.context == "" {
+		if err := p.catalog.setHeader(entry.translations[0]); err != nil {
+			return p.errorf("%s", err)
+		}
+		return nil
+	}
+	if !entry.fuzzy {
+		p.catalog.Add(entry.context, entry.id, entry.translations...)
+	}
+	return nil
+}
+
+func (p *poParser) unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", p.errorf("expected a quoted string, got '%s'", s)
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		default: // '"', '\\'
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+func (p *poParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,18 @@
+{% trans %}Hello World!{% endtrans %}
+{% trans name=simple.name %}Hello {{ name }}!{% endtrans %}
+{% trans name=simple.xss %}Hello {{ name }}!{% endtrans %}
+{% for count in [1, 3] %}{% trans %}{{ count }} apple{% pluralize %}{{ count }} apples{% endtrans %}
+{% endfor %}{% trans count=simple.number %}{{ count }} apple{% pluralize count %}{{ count }} apples{% endtrans %}
+{% trans "month" %}May{% endtrans %} / {% trans "verb" %}May{% endtrans %}
+{% trans %}100% sure{% endtrans %}
+{% trans %}Welcome to our shop.{% endtrans %}
+{% trans %}Goodbye{% endtrans %}
+{% trans trimmed %}
+  This is
+  a long text.
+{% endtrans %}
+{{ _('Hello World!') }}
+{{ gettext('Hello %(name)s!', name='Jane') }}
+{{ ngettext('%(num)s apple', '%(num)s apples', 3) }}
+{{ pgettext('month', 'May') }}
+{{ _('Untranslated') }}
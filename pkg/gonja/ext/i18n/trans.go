@@ -0,0 +1,224 @@
+package i18n
+
+import (
+	"encoding/gob"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"github.com/aisbergg/gonja/pkg/gonja/parse"
+)
+
+// Statements contains the statements provided by the i18n extension.
+var Statements = exec.StatementSet{}
+
+// TransStmt is a translatable section `{% trans %}...{% endtrans %}` with an
+// optional plural form `{% pluralize %}`.
+type TransStmt struct {
+	Location *parse.Token
+	Context  string
+	Singular string
+	Plural   string
+	// Count is the name of the variable selecting the plural form.
+	Count string
+	// Variables maps the names of all variables used in the section to their
+	// expressions.
+	Variables map[string]parse.Expression
+	// Formatted indicates, if the messages reference variables and need to be
+	// formatted.
+	Formatted bool
+	EndTrim   *parse.Trim
+	EndLStrip bool
+}
+
+var (
+	_ parse.Statement = (*TransStmt)(nil)
+	_ exec.Statement  = (*TransStmt)(nil)
+)
+
+// Position returns the position of the statement.
+func (stmt *TransStmt) Position() *parse.Token { return stmt.Location }
+
+func (stmt *TransStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("TransStmt(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+// Execute renders the translated message.
+func (stmt *TransStmt) Execute(r *exec.Renderer, tag *parse.StatementBlockNode) {
+	r.Current = stmt
+	translations := getConfig(r.EvalConfig).Translations
+
+	variables := make(map[string]exec.Value, len(stmt.Variables))
+	for name, expr := range stmt.Variables {
+		variables[name] = r.Eval(expr)
+	}
+
+	var message string
+	if stmt.Plural == "" {
+		message = translations.Pgettext(stmt.Context, stmt.Singular)
+	} else {
+		count := variables[stmt.Count].Integer()
+		message = translations.Npgettext(stmt.Context, stmt.Singular, stmt.Plural, count)
+	}
+
+	if stmt.Formatted {
+		message = formatMessage(message, func(name string) (string, bool) {
+			value, ok := variables[name]
+			if !ok {
+				return "", false
+			}
+			return r.Escape(value), true
+		})
+	}
+
+	r.WriteString(message)
+	r.Tag(stmt.EndTrim, stmt.EndLStrip)
+}
+
+// reTrimmed matches line breaks including the surrounding whitespace.
+var reTrimmed = regexp.MustCompile(`\s*\n\s*`)
+
+func transParser(p, args *parse.Parser) parse.Statement {
+	stmt := &TransStmt{
+		Location:  p.Current(),
+		Variables: map[string]parse.Expression{},
+	}
+
+	// an optional context: {% trans "title" %}
+	if context := args.Match(parse.TokenString); context != nil {
+		stmt.Context = context.Val
+	}
+
+	// options and variables: {% trans trimmed user=user.name, count=3 %}
+	trimmed := false
+	var tagVariables []string
+	for !args.End() {
+		if len(tagVariables) > 0 && args.Match(parse.TokenComma) == nil {
+			errors.ThrowSyntaxError(args.Current().ErrorToken(), "expected ',', got '%s'", args.Current().Val)
+		}
+		name := args.Match(parse.TokenName)
+		if name == nil {
+			errors.ThrowSyntaxError(args.Current().ErrorToken(), "expected an identifier, got '%s'", args.Current().Val)
+		}
+		if (name.Val == "trimmed" || name.Val == "notrimmed") && args.Peek(parse.TokenAssign) == nil {
+			trimmed = name.Val == "trimmed"
+			continue
+		}
+		if _, exists := stmt.Variables[name.Val]; exists {
+			errors.ThrowSyntaxError(name.ErrorToken(), "translatable variable '%s' defined twice", name.Val)
+		}
+		if args.Match(parse.TokenAssign) != nil {
+			stmt.Variables[name.Val] = args.ParseExpression()
+		} else {
+			stmt.Variables[name.Val] = &parse.NameNode{Name: name}
+		}
+		tagVariables = append(tagVariables, name.Val)
+	}
+
+	// singular message
+	var referenced []string
+	wrapper, endargs := p.WrapUntil("pluralize", "endtrans")
+	stmt.Singular = transBody(wrapper, stmt.Variables, &referenced)
+	stmt.EndTrim, stmt.EndLStrip = wrapper.Trim, wrapper.LStrip
+
+	// plural message
+	if wrapper.EndTag == "pluralize" {
+		if name := endargs.Match(parse.TokenName); name != nil {
+			stmt.Count = name.Val
+			if _, exists := stmt.Variables[name.Val]; !exists {
+				stmt.Variables[name.Val] = &parse.NameNode{Name: name}
+			}
+		}
+		if !endargs.End() {
+			errors.ThrowSyntaxError(endargs.Current().ErrorToken(), "malformed pluralize-tag")
+		}
+
+		wrapper, endargs = p.WrapUntil("endtrans")
+		stmt.Plural = transBody(wrapper, stmt.Variables, &referenced)
+		stmt.EndTrim, stmt.EndLStrip = wrapper.Trim, wrapper.LStrip
+
+		if stmt.Count == "" {
+			switch {
+			case len(tagVariables) > 0:
+				stmt.Count = tagVariables[0]
+			case len(referenced) > 0:
+				stmt.Count = referenced[0]
+			default:
+				errors.ThrowSyntaxError(stmt.Location.ErrorToken(), "pluralize without variables")
+			}
+		}
+	}
+	if !endargs.End() {
+		errors.ThrowSyntaxError(endargs.Current().ErrorToken(), "arguments not allowed here")
+	}
+
+	// messages without variables are not formatted, so they don't need
+	// escaped percent signs
+	stmt.Formatted = len(referenced) > 0
+	messages := []*string{&stmt.Singular, &stmt.Plural}
+	for _, msg := range messages {
+		if trimmed {
+			*msg = strings.TrimSpace(reTrimmed.ReplaceAllString(*msg, " "))
+		}
+		if !stmt.Formatted {
+			*msg = strings.ReplaceAll(*msg, "%%", "%")
+		}
+	}
+
+	return stmt
+}
+
+// transBody converts the body of a translatable section into a message.
+// Variables are replaced with `%(name)s` placeholders. Referenced variables
+// are added to the variables and referenced names.
+func transBody(wrapper *parse.WrapperNode, variables map[string]parse.Expression, referenced *[]string) string {
+	var b strings.Builder
+	trimNext := false
+	for _, node := range wrapper.Nodes {
+		switch n := node.(type) {
+		case *parse.DataNode:
+			data := n.Data.Val
+			if trimNext {
+				data = strings.TrimLeft(data, " \t\n")
+				trimNext = false
+			}
+			b.WriteString(strings.ReplaceAll(data, "%", "%%"))
+
+		case *parse.OutputNode:
+			name, ok := n.Expression.(*parse.NameNode)
+			if !ok {
+				errors.ThrowSyntaxError(n.Expression.Position().ErrorToken(), "only simple variables are allowed in translatable sections")
+			}
+			if n.Trim != nil && n.Trim.Left {
+				trimmed := strings.TrimRight(b.String(), " \t\n")
+				b.Reset()
+				b.WriteString(trimmed)
+			}
+			trimNext = n.Trim != nil && n.Trim.Right
+			if _, exists := variables[name.Name.Val]; !exists {
+				variables[name.Name.Val] = name
+			}
+			*referenced = append(*referenced, name.Name.Val)
+			fmt.Fprintf(&b, "%%(%s)s", name.Name.Val)
+
+		case *parse.CommentNode:
+			continue
+
+		default:
+			errors.ThrowSyntaxError(node.Position().ErrorToken(), "control structures in translatable sections are not allowed")
+		}
+	}
+	if wrapper.Trim.Left {
+		return strings.TrimRight(b.String(), " \t\n")
+	}
+	return b.String()
+}
+
+func init() {
+	Statements.MustRegister("trans", transParser)
+	// allow templates using translations to be bundled
+	gob.Register(&TransStmt{})
+}
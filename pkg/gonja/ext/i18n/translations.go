@@ -0,0 +1,165 @@
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// contextSeparator separates the context from the message ID in gettext
+// catalogs.
+const contextSeparator = "\x04"
+
+// Translations provides translated messages.
+type Translations interface {
+	// Gettext returns the translation of the given message.
+	Gettext(message string) string
+
+	// Ngettext returns the singular or plural translation of the given message
+	// depending on n.
+	Ngettext(singular, plural string, n int) string
+
+	// Pgettext returns the translation of the given message in the given
+	// context.
+	Pgettext(context, message string) string
+
+	// Npgettext returns the singular or plural translation of the given
+	// message in the given context depending on n.
+	Npgettext(context, singular, plural string, n int) string
+}
+
+// NullTranslations returns all messages untranslated.
+type NullTranslations struct{}
+
+var _ Translations = NullTranslations{}
+
+// Gettext returns the message as is.
+func (NullTranslations) Gettext(message string) string { return message }
+
+// Ngettext returns the singular message, if n is 1, otherwise the plural one.
+func (NullTranslations) Ngettext(singular, plural string, n int) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// Pgettext returns the message as is.
+func (NullTranslations) Pgettext(context, message string) string { return message }
+
+// Npgettext returns the singular message, if n is 1, otherwise the plural one.
+func (NullTranslations) Npgettext(context, singular, plural string, n int) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// Catalog is a catalog of translated messages, usually loaded from a `.po` or
+// `.mo` file. Messages missing in the catalog are returned untranslated.
+type Catalog struct {
+	// messages maps the message IDs (prefixed with the context) to the
+	// translations; plural messages have one translation per plural form
+	messages map[string][]string
+	plural   PluralFunc
+}
+
+var _ Translations = (*Catalog)(nil)
+
+// NewCatalog creates an empty catalog, which uses the plural rule of the
+// English language.
+func NewCatalog() *Catalog {
+	return &Catalog{
+		messages: map[string][]string{},
+		plural:   germanicPlural,
+	}
+}
+
+// LoadCatalog loads a catalog from a `.po` or `.mo` file. The format is
+// determined by the file extension.
+func LoadCatalog(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".po", ".pot":
+		return ParsePO(f)
+	case ".mo":
+		return ParseMO(f)
+	default:
+		return nil, fmt.Errorf("unsupported catalog format '%s'", ext)
+	}
+}
+
+// Add adds a translated message to the catalog. The context can be empty.
+// Plural messages must have one translation per plural form of the language.
+func (c *Catalog) Add(context, id string, translations ...string) {
+	c.messages[catalogKey(context, id)] = translations
+}
+
+// SetPluralFunc sets the function that selects the plural form.
+func (c *Catalog) SetPluralFunc(fn PluralFunc) {
+	c.plural = fn
+}
+
+// Gettext returns the translation of the given message.
+func (c *Catalog) Gettext(message string) string {
+	return c.Pgettext("", message)
+}
+
+// Ngettext returns the singular or plural translation of the given message
+// depending on n.
+func (c *Catalog) Ngettext(singular, plural string, n int) string {
+	return c.Npgettext("", singular, plural, n)
+}
+
+// Pgettext returns the translation of the given message in the given context.
+func (c *Catalog) Pgettext(context, message string) string {
+	if translations := c.messages[catalogKey(context, message)]; len(translations) > 0 && translations[0] != "" {
+		return translations[0]
+	}
+	return message
+}
+
+// Npgettext returns the singular or plural translation of the given message in
+// the given context depending on n.
+func (c *Catalog) Npgettext(context, singular, plural string, n int) string {
+	translations := c.messages[catalogKey(context, singular)]
+	idx := c.plural(n)
+	if idx >= 0 && idx < len(translations) && translations[idx] != "" {
+		return translations[idx]
+	}
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// setHeader applies the catalog header (the translation of the empty message
+// ID). Currently only the `Plural-Forms` header is evaluated.
+func (c *Catalog) setHeader(header string) error {
+	for _, line := range strings.Split(header, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "Plural-Forms") {
+			continue
+		}
+		fn, err := ParsePluralForms(value)
+		if err != nil {
+			return err
+		}
+		c.plural = fn
+	}
+	return nil
+}
+
+// catalogKey returns the key of a message in the catalog.
+func catalogKey(context, id string) string {
+	if context == "" {
+		return id
+	}
+	return context + contextSeparator + id
+}
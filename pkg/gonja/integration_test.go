@@ -1,9 +1,26 @@
 package gonja_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/aisbergg/gonja/internal/testutils"
+	"github.com/aisbergg/gonja/pkg/gonja"
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"github.com/aisbergg/gonja/pkg/gonja/loaders"
+	"github.com/aisbergg/gonja/pkg/gonja/parse"
+	"golang.org/x/text/encoding/charmap"
 )
 
 func TestTemplates(t *testing.T) {
@@ -44,6 +61,15 @@ func TestStatements(t *testing.T) {
 	testutils.GlobTemplateTests(t, root, env)
 }
 
+func TestLineStatements(t *testing.T) {
+	root := "./testdata/linestatements"
+	env := testutils.TestEnv(root,
+		gonja.OptLineStatementPrefix("#"),
+		gonja.OptLineCommentPrefix("##"),
+	)
+	testutils.GlobTemplateTests(t, root, env)
+}
+
 func TestCompilationErrors(t *testing.T) {
 	root := "./testdata/errors/compilation"
 	env := testutils.TestEnv(root)
@@ -55,3 +81,1264 @@ func TestExecutionErrors(t *testing.T) {
 	env := testutils.TestEnv(root)
 	testutils.GlobTemplateTests(t, root, env)
 }
+
+// chunkWriter records every write as separate chunk.
+type chunkWriter struct {
+	chunks []string
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, string(p))
+	return len(p), nil
+}
+
+func TestExecuteTo(t *testing.T) {
+	tpl, err := gonja.FromString("{% for i in range(3) %}{{ i }}\n{% endfor %}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &chunkWriter{}
+	if err := tpl.ExecuteTo(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.chunks) < 3 {
+		t.Errorf("expected output to be streamed in chunks, got %q", w.chunks)
+	}
+	if out := strings.Join(w.chunks, ""); out != "0\n1\n2" {
+		t.Errorf("expected %q, got %q", "0\n1\n2", out)
+	}
+}
+
+func TestGlobalsIsolation(t *testing.T) {
+	env := gonja.NewEnvironment(gonja.OptSetGlobal("site", "gonja"))
+	tpl, err := env.FromString("{{ site }} {{ user is defined }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		data   map[string]any
+		output string
+	}{
+		{map[string]any{"user": "alice"}, "gonja True"},
+		{map[string]any{}, "gonja False"},
+	} {
+		out, err := tpl.Execute(c.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != c.output {
+			t.Errorf("expected output '%s', got '%s'", c.output, out)
+		}
+	}
+	if _, ok := env.Globals["user"]; ok {
+		t.Errorf("expected the globals to be left unchanged, got %v", env.Globals)
+	}
+}
+
+func TestExecuteContext(t *testing.T) {
+	tpl, err := gonja.FromString("{% for i in range(1000000) %}{{ i }}{% endfor %}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = tpl.ExecuteContext(ctx, nil)
+	if _, ok := err.(errors.ContextError); !ok {
+		t.Fatalf("expected a context error, got: %v", err)
+	}
+	if !stderrors.Is(err, context.Canceled) {
+		t.Errorf("expected error to wrap context.Canceled, got: %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		name   string
+		option gonja.Option
+		source string
+		limit  string
+	}{
+		{"loop iterations", gonja.OptMaxLoopIterations(10),
+			"{% for i in range(5) %}{% for j in range(5) %}{% endfor %}{% endfor %}", "loop iterations"},
+		{"macro depth", gonja.OptMaxMacroDepth(5),
+			"{% macro m(n) %}{{ m(n + 1) }}{% endmacro %}{{ m(0) }}", "macro recursion depth"},
+		{"include depth", gonja.OptMaxIncludeDepth(3),
+			"{% include 'include_self.tpl' %}", "include depth"},
+		{"output size", gonja.OptMaxOutputSize(100),
+			"{% for i in range(100) %}{{ i }}{% endfor %}", "output size"},
+		{"output size of macro", gonja.OptMaxOutputSize(100),
+			"{% macro m() %}{% for i in range(100) %}{{ i }}{% endfor %}{% endmacro %}{{ m()|length }}", "output size"},
+		{"output size of caller", gonja.OptMaxOutputSize(100),
+			"{% macro m() %}{{ caller()|length }}{% endmacro %}{% call m() %}{% for i in range(100) %}{{ i }}{% endfor %}{% endcall %}", "output size"},
+		{"output size of filter", gonja.OptMaxOutputSize(100),
+			"{% filter length %}{% for i in range(100) %}{{ i }}{% endfor %}{% endfilter %}", "output size"},
+		{"output size of recursive loop", gonja.OptMaxOutputSize(100),
+			"{% for i in [range(100)|list] recursive %}{{ loop(i)|length if i is iterable else i }}{% endfor %}", "output size"},
+		{"output size of single value", gonja.OptMaxOutputSize(100),
+			"{{ 'x' * 1000 }}", "output size"},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			env := testutils.TestEnv("./testdata/limits", test.option)
+			tpl, err := env.FromString(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = tpl.Execute(nil)
+			lerr, ok := err.(errors.LimitExceededError)
+			if !ok {
+				t.Fatalf("expected a limit exceeded error, got: %v", err)
+			}
+			if lerr.Limit() != test.limit {
+				t.Errorf("expected limit '%s' to be exceeded, got '%s'", test.limit, lerr.Limit())
+			}
+		})
+	}
+}
+
+func TestOutputSizeWithinLimit(t *testing.T) {
+	// output rendered into buffers is only counted once it reaches the output
+	for _, source := range []string{
+		"{% macro m() %}hello{% endmacro %}{{ m() }}",
+		"{% filter upper %}hello{% endfilter %}",
+		"{% macro m() %}{{ caller() }}{% endmacro %}{% call m() %}hello{% endcall %}",
+		"{% for i in [['hello']] recursive %}{% if i is string %}{{ i }}{% else %}{{ loop(i) }}{% endif %}{% endfor %}",
+		"{% filter length %}{{ 'x' * 8 }}{% endfilter %}",
+	} {
+		env := testutils.TestEnv("./testdata/limits", gonja.OptMaxOutputSize(8))
+		tpl, err := env.FromString(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tpl.Execute(nil); err != nil {
+			t.Errorf("unexpected error for '%s': %s", source, err)
+		}
+	}
+}
+
+type sandboxUser struct {
+	Name     string
+	Password string
+}
+
+func (u sandboxUser) Greet() string { return "hello " + u.Name }
+
+type sandboxPolicy struct {
+	exec.DefaultSandboxPolicy
+}
+
+func (sandboxPolicy) IsSafeField(typ reflect.Type, name string) bool {
+	return name != "Password"
+}
+
+func TestSandbox(t *testing.T) {
+	data := map[string]any{"user": sandboxUser{Name: "alice", Password: "secret"}}
+	cases := []struct {
+		name    string
+		options []gonja.Option
+		source  string
+		output  string
+		unsafe  bool
+	}{
+		{"field", []gonja.Option{gonja.OptSandbox(nil)}, "{{ user.Name }}", "alice", false},
+		{"method", []gonja.Option{gonja.OptSandbox(nil)}, "{{ user.Greet() }}", "", true},
+		{"method unsandboxed", nil, "{{ user.Greet() }}", "hello alice", false},
+		{"denied field", []gonja.Option{gonja.OptSandbox(sandboxPolicy{})}, "{{ user.Password }}", "", true},
+		{"loop", []gonja.Option{gonja.OptSandbox(nil)}, "{% for i in range(3) %}{{ loop.index }}{{ loop.Cycle('a', 'b') }}{% endfor %}", "1a2b3a", false},
+		{"range", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{% for i in range(11) %}{% endfor %}", "", true},
+		{"range within limit", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{% for i in range(10) %}{{ i }}{% endfor %}", "0123456789", false},
+		{"repeat", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'abc' * 4 }}", "", true},
+		{"repeat within limit", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'abc' * 3 }}", "abcabcabc", false},
+		{"center", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'ab'.center(100000) }}", "", true},
+		{"ljust", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'ab'.ljust(11, '-') }}", "", true},
+		{"rjust within limit", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'ab'.rjust(10, '-') }}", "--------ab", false},
+		{"zfill", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'x'.zfill(1000000000) }}", "", true},
+		{"zfill within limit", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ '-1'.zfill(4) }}", "-001", false},
+		{"replace", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 1000)}, "{{ ('a' * 999).replace('a', 'b' * 999) }}", "", true},
+		{"replace within limit", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'aaa'.replace('a', 'bb', 2) }}", "bbbba", false},
+		{"format width", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ '{:>1000000000}'.format(1) }}", "", true},
+		{"format nested width", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ '{:{}}'.format('x', 11) }}", "", true},
+		{"format precision", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ '{:.1000000000f}'.format(1.5) }}", "", true},
+		{"format within limit", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ '{:>4}|{:.2f}'.format(1, 1.5) }}", "   1|1.50", false},
+		{"expandtabs", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'a\\tb'.expandtabs(100) }}", "", true},
+		{"expandtabs within limit", []gonja.Option{gonja.OptSandbox(nil), gonja.OptSandboxLimits(10, 10)}, "{{ 'a\\tb'.expandtabs(4) }}", "a   b", false},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			env := testutils.TestEnv("./testdata", test.options...)
+			tpl, err := env.FromString(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tpl.Execute(data)
+			if test.unsafe {
+				if _, ok := err.(errors.UnsafeOperationError); !ok {
+					t.Fatalf("expected an unsafe operation error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.output {
+				t.Errorf("expected output '%s', got '%s'", test.output, out)
+			}
+		})
+	}
+}
+
+func TestLoopControlsOutsideLoop(t *testing.T) {
+	sources := []string{
+		"{% break %}",
+		"{% if true %}{% continue %}{% endif %}",
+		"{% for i in range(3) %}{% else %}{% break %}{% endfor %}",
+		"{% for i in range(3) %}{% macro m() %}{% break %}{% endmacro %}{% endfor %}",
+	}
+	for _, source := range sources {
+		_, err := gonja.FromString(source)
+		if _, ok := err.(errors.TemplateSyntaxError); !ok {
+			t.Errorf("expected a syntax error for '%s', got: %v", source, err)
+		}
+	}
+}
+
+func TestMutatingMethods(t *testing.T) {
+	list := []int{1}
+	scores := map[string]int{"a": 1}
+	tpl, err := gonja.FromString("{% do list.append(2) %}{% do scores.update(b=2) %}{{ list }} {{ scores.pop('a') }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(map[string]any{"list": &list, "scores": scores})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "[1, 2] 1" {
+		t.Errorf("unexpected output '%s'", out)
+	}
+	if len(list) != 2 || list[1] != 2 {
+		t.Errorf("expected the Go slice to be modified, got %v", list)
+	}
+	if len(scores) != 1 || scores["b"] != 2 {
+		t.Errorf("expected the Go map to be modified, got %v", scores)
+	}
+
+	type point struct{ Xs []int }
+	cases := []struct {
+		name   string
+		source string
+		output string
+	}{
+		{"map entry", "{% do d.xs.append(2) %}{% do d['xs'].append(3) %}{{ d.xs }}", "[1, 2, 3]"},
+		{"variable", "{% do xs.append(2) %}{% do xs.extend([3]) %}{{ xs }}", "[1, 2, 3]"},
+		{"alias", "{% set ys = [1] %}{% set zs = ys %}{% do zs.append(2) %}{{ ys }}", "[1, 2]"},
+		{"loop item", "{% for row in rows %}{% do row.append(0) %}{% endfor %}{{ rows }}", "[[1, 0], [2, 0]]"},
+		{"nested literal", "{% set rows = [[1], [2]] %}{% for row in rows %}{% do row.pop() %}{% endfor %}{{ rows }}", "[[], []]"},
+		{"inner scope", "{% for _ in [1] %}{% do xs.append(2) %}{% endfor %}{{ xs }}", "[1, 2]"},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := gonja.FromString(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tpl.Execute(map[string]any{
+				"d":    map[string]any{"xs": []int{1}},
+				"xs":   []int{1},
+				"rows": [][]int{{1}, {2}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.output {
+				t.Errorf("expected output '%s', got '%s'", test.output, out)
+			}
+		})
+	}
+
+	t.Run("not addressable", func(t *testing.T) {
+		tpl, err := gonja.FromString("{% do p.Xs.append(2) %}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tpl.Execute(map[string]any{"p": point{Xs: []int{1}}})
+		if err == nil || !strings.Contains(err.Error(), "not stored in a variable, list or dict") {
+			t.Errorf("expected an error for a non-addressable list, got %v", err)
+		}
+	})
+}
+
+func TestFSLoader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"secret.txt":                {Data: []byte("secret")},
+		"templates/base.html":       {Data: []byte("<{% block content %}{% endblock %}>")},
+		"templates/index.html":      {Data: []byte("{% extends 'base.html' %}{% block content %}{% include 'parts/part.html' %}{% endblock %}")},
+		"templates/parts/part.html": {Data: []byte("part")},
+		"templates/latin1.html":     {Data: []byte("gr\xfc\xdfe")},
+		"templates/link.html":       {Data: []byte("parts/part.html"), Mode: fs.ModeSymlink},
+	}
+
+	cases := []struct {
+		name   string
+		loader loaders.Loader
+		tpl    string
+		output string
+	}{
+		{"inheritance", loaders.MustNewFSLoader(fsys, "templates"), "index.html", "<part>"},
+		{"traversal", loaders.MustNewFSLoader(fsys, "templates"), "../secret.txt", ""},
+		{"root", loaders.MustNewFSLoader(fsys), "templates/parts/part.html", "part"},
+		{"encoding", loaders.MustNewFSLoaderWithOptions(fsys, charmap.ISO8859_1, false, "templates"), "latin1.html", "grüße"},
+		{"symlink", loaders.MustNewFSLoader(fsys, "templates"), "link.html", ""},
+		{"follow symlink", loaders.MustNewFSLoaderWithOptions(fsys, nil, true, "templates"), "link.html", "part"},
+		{"dict", loaders.NewDictLoader(map[string]string{"a.html": "{% include './b.html' %}", "b.html": "b"}), "a.html", "b"},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			env := gonja.NewEnvironment(gonja.OptLoader(test.loader))
+			tpl, err := env.FromFile(test.tpl)
+			if test.output == "" {
+				if _, ok := err.(errors.TemplateNotFoundError); !ok {
+					t.Fatalf("expected a template not found error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tpl.Execute(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.output {
+				t.Errorf("expected output '%s', got '%s'", test.output, out)
+			}
+		})
+	}
+}
+
+func TestAutoReload(t *testing.T) {
+	fsys := fstest.MapFS{
+		"base.html":  {Data: []byte("base1 {% block content %}{% endblock %}")},
+		"index.html": {Data: []byte("{% extends 'base.html' %}{% block content %}{% include 'part.html' %}{% endblock %}")},
+		"part.html":  {Data: []byte("part1")},
+	}
+	update := func(name, source string) {
+		fsys[name] = &fstest.MapFile{Data: []byte(source), ModTime: fsys[name].ModTime.Add(time.Second)}
+	}
+
+	cases := []struct {
+		name       string
+		autoReload bool
+		outputs    []string
+	}{
+		{"cached", false, []string{"base1 part1", "base1 part1", "base1 part1"}},
+		{"auto reload", true, []string{"base1 part1", "base2 part1", "base2 part2"}},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			update("base.html", "base1 {% block content %}{% endblock %}")
+			update("part.html", "part1")
+			options := []gonja.Option{gonja.OptLoader(loaders.MustNewFSLoader(fsys))}
+			if test.autoReload {
+				options = append(options, gonja.OptAutoReload())
+			} else {
+				options = append(options, gonja.OptLoader(loaders.NewCachedLoader(loaders.MustNewFSLoader(fsys))))
+			}
+			env := gonja.NewEnvironment(options...)
+
+			for i, output := range test.outputs {
+				switch i {
+				case 1:
+					update("base.html", "base2 {% block content %}{% endblock %}")
+				case 2:
+					update("part.html", "part2")
+				}
+				tpl, err := env.FromFile("index.html")
+				if err != nil {
+					t.Fatal(err)
+				}
+				out, err := tpl.Execute(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if out != output {
+					t.Errorf("expected output '%s' after %d changes, got '%s'", output, i, out)
+				}
+			}
+		})
+	}
+}
+
+func TestDynamicExtends(t *testing.T) {
+	env := gonja.NewEnvironment(gonja.OptLoader(loaders.NewDictLoader(map[string]string{
+		"base.html":  "<{% block content %}{% endblock %}>",
+		"child.html": "{% extends layout %}{% block content %}child{% endblock %}",
+		"twice.html": "{% extends layout %}{% extends layout %}",
+	})))
+	base, err := env.FromFile("base.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := env.FromFile("child.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := child.Execute(map[string]any{"layout": base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "<child>" {
+		t.Errorf("expected output '<child>', got '%s'", out)
+	}
+
+	_, err = child.Execute(map[string]any{"layout": "missing.html"})
+	if _, ok := err.(errors.TemplateNotFoundError); !ok {
+		t.Errorf("expected a template not found error, got: %v", err)
+	}
+
+	_, err = child.Execute(map[string]any{"layout": []string{"missing.html", "other.html"}})
+	if nf, ok := err.(errors.TemplatesNotFoundError); !ok {
+		t.Errorf("expected a templates not found error, got: %v", err)
+	} else if !reflect.DeepEqual(nf.Names(), []string{"missing.html", "other.html"}) {
+		t.Errorf("unexpected template names %v", nf.Names())
+	}
+
+	twice, err := env.FromFile("twice.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = twice.Execute(map[string]any{"layout": "base.html"})
+	if err == nil || !strings.Contains(err.Error(), "the template can only be extended once") {
+		t.Errorf("expected an error for extending twice, got: %v", err)
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	env := gonja.NewEnvironment(
+		gonja.OptJoinPath(exec.JoinPathRelative),
+		gonja.OptLoader(loaders.NewDictLoader(map[string]string{
+			"mail/base.html":    "<{% block content %}{% endblock %}>",
+			"mail/footer.html":  "footer",
+			"mail/macros.html":  "{% macro greet(name) %}Hello {{ name }}{% endmacro %}",
+			"shared/sig.html":   "sig",
+			"mail/welcome.html": `{% extends "./base.html" %}{% block content %}{% from "./macros.html" import greet %}{{ greet("Bob") }}|{% include "./footer.html" %}|{% include "../shared/sig.html" %}|{% include partial %}{% endblock %}`,
+		})),
+	)
+	tpl, err := env.FromFile("mail/welcome.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tpl.Execute(map[string]any{"partial": []string{"./missing.html", "./footer.html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<Hello Bob|footer|sig|footer>"; out != expected {
+		t.Errorf("expected output '%s', got '%s'", expected, out)
+	}
+
+	// dynamic names are joined with the template containing the statement,
+	// e.g. a statically included template or a parent template
+	env = gonja.NewEnvironment(
+		gonja.OptJoinPath(exec.JoinPathRelative),
+		gonja.OptLoader(loaders.NewDictLoader(map[string]string{
+			"index.html":       `{% extends 'layout/base.html' %}`,
+			"x.html":           "root-x",
+			"mail/footer.html": "{% include n %}",
+			"mail/x.html":      "mail-x",
+			"layout/base.html": "{% include y %}",
+			"layout/y.html":    "layout-y",
+		})),
+	)
+	tpl, err = env.FromFile("index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = tpl.Execute(map[string]any{"y": "./y.html"})
+	if err != nil {
+		t.Fatal(err)
+	} else if expected := "layout-y"; out != expected {
+		t.Errorf("expected output '%s', got '%s'", expected, out)
+	}
+	tpl = gonja.Must(env.FromString(`{% include 'mail/footer.html' %}`))
+	if out, err = tpl.Execute(map[string]any{"n": "./x.html"}); err != nil {
+		t.Fatal(err)
+	} else if expected := "mail-x"; out != expected {
+		t.Errorf("expected output '%s', got '%s'", expected, out)
+	}
+
+	// without a join function, the names are used as they are
+	env = gonja.NewEnvironment(gonja.OptLoader(loaders.NewDictLoader(map[string]string{
+		"mail/welcome.html": `{% include "./footer.html" %}`,
+	})))
+	if _, err = env.FromFile("mail/welcome.html"); err == nil {
+		t.Error("expected an error for a relative name without join function")
+	}
+}
+
+func TestPrefixAndChoiceLoader(t *testing.T) {
+	admin := loaders.NewDictLoader(map[string]string{
+		"index.html":  `{% extends "admin/base.html" %}{% block content %}admin{% endblock %}`,
+		"base.html":   "[{% block content %}{% endblock %}{% include './footer.html' %}]",
+		"footer.html": "|admin footer",
+	})
+	overrides := loaders.NewDictLoader(map[string]string{
+		"welcome.html": "overridden",
+		"broken.html":  "{% if %}",
+		"oops.html":    "hi\n  {{ missing() }}",
+	})
+	defaults := loaders.NewDictLoader(map[string]string{
+		"welcome.html": "default",
+		"goodbye.html": "goodbye",
+		"broken.html":  "fine",
+	})
+	env := gonja.NewEnvironment(
+		gonja.OptJoinPath(exec.JoinPathRelative),
+		gonja.OptLoader(loaders.NewPrefixLoader(map[string]loaders.Loader{
+			"admin/": admin,
+			"mail/":  loaders.NewChoiceLoader(overrides, defaults),
+		})),
+	)
+
+	for name, expected := range map[string]string{
+		"admin/index.html":  "[admin|admin footer]",
+		"mail/welcome.html": "overridden",
+		"mail/goodbye.html": "goodbye",
+	} {
+		tpl, err := env.FromFile(name)
+		if err != nil {
+			t.Errorf("failed to load '%s': %s", name, err)
+			continue
+		}
+		out, err := tpl.Execute(nil)
+		if err != nil {
+			t.Errorf("failed to execute '%s': %s", name, err)
+		} else if out != expected {
+			t.Errorf("expected output '%s' for '%s', got '%s'", expected, name, out)
+		}
+	}
+
+	// not found errors carry the full name
+	_, err := env.FromFile("mail/missing.html")
+	if nf, ok := err.(errors.TemplateNotFoundError); !ok || nf.Name() != "mail/missing.html" {
+		t.Errorf("expected a template not found error for 'mail/missing.html', got: %v", err)
+	}
+	if _, err = env.FromFile("other/index.html"); err == nil {
+		t.Error("expected an error for an unknown prefix")
+	}
+
+	// syntax errors are not hidden by the choice loader
+	_, err = env.FromFile("mail/broken.html")
+	if _, ok := err.(errors.TemplateSyntaxError); !ok {
+		t.Errorf("expected a syntax error, got: %v", err)
+	}
+
+	// errors are located by the full name, so that the source can be reloaded
+	tpl, err := env.FromFile("mail/oops.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Name != "mail/oops.html" || tpl.Root.Name != "mail/oops.html" {
+		t.Errorf("expected template name 'mail/oops.html', got '%s' and '%s'", tpl.Name, tpl.Root.Name)
+	}
+	_, err = tpl.Execute(map[string]any{})
+	expected := "mail/oops.html:2:6: '' is not callable\n" +
+		"    2 |   {{ missing() }}\n" +
+		"      |      ^~~~~~~\n"
+	if err == nil {
+		t.Fatal("expected an error for 'mail/oops.html'")
+	}
+	if report := errors.NewReport(err).String(); report != expected {
+		t.Errorf("unexpected report:\n%s\nexpected:\n%s", report, expected)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	root := "./testdata/statements"
+	env := testutils.TestEnv(root)
+	names, err := filepath.Glob(filepath.Join(root, "*.tpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		names[i] = filepath.Base(name)
+	}
+	// templates loaded dynamically by the tests
+	names = append(names, "includes.helper", "with.helper", "inheritance/base.tpl")
+
+	var buf bytes.Buffer
+	if err := env.CompileBundle(&buf, names...); err != nil {
+		t.Fatal(err)
+	}
+	loader, err := loaders.NewBundleLoader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// templates loaded from the bundle render like the original ones
+	env = testutils.TestEnv(root, gonja.OptLoader(loader))
+	testutils.GlobTemplateTests(t, root, env)
+}
+
+func TestCompileBundleDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, source := range map[string]string{
+		"index.html":         `{% extends "layout/base.html" %}{% block content %}index{% endblock %}`,
+		"layout/base.html":   "<{% block content %}{% endblock %}>",
+		"notes.txt":          "{% not a template",
+		".hidden/page.html":  "hidden",
+		"layout/.draft.html": "draft",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gonja.CompileBundleDir(&buf, dir, []string{".html"}); err != nil {
+		t.Fatal(err)
+	}
+	loader := loaders.MustNewBundleLoader(&buf)
+	if names := loader.Names(); !reflect.DeepEqual(names, []string{"index.html", "layout/base.html"}) {
+		t.Errorf("unexpected template names %v", names)
+	}
+	tpl, err := gonja.NewEnvironment(gonja.OptLoader(loader)).FromFile("index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := tpl.Execute(nil); err != nil || out != "<index>" {
+		t.Errorf("expected output '<index>', got '%s' (error: %v)", out, err)
+	}
+}
+
+func TestPrefixedBundle(t *testing.T) {
+	var buf bytes.Buffer
+	env := gonja.NewEnvironment(gonja.OptLoader(loaders.NewDictLoader(map[string]string{
+		"welcome.html": `{% set n = "./footer.html" %}hello{% include n %}`,
+		"footer.html":  "|footer",
+		"oops.html":    "hi\n  {{ missing() }}",
+	})))
+	if err := env.CompileBundle(&buf, "welcome.html", "footer.html", "oops.html"); err != nil {
+		t.Fatal(err)
+	}
+	env = gonja.NewEnvironment(
+		gonja.OptJoinPath(exec.JoinPathRelative),
+		gonja.OptLoader(loaders.NewPrefixLoader(map[string]loaders.Loader{
+			"mail/": loaders.MustNewBundleLoader(&buf),
+		})),
+	)
+
+	// dynamic relative includes are resolved against the full name
+	tpl, err := env.FromFile("mail/welcome.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Name != "mail/welcome.html" || tpl.Root.Name != "mail/welcome.html" {
+		t.Errorf("expected template name 'mail/welcome.html', got '%s' and '%s'", tpl.Name, tpl.Root.Name)
+	}
+	if out, err := tpl.Execute(nil); err != nil || out != "hello|footer" {
+		t.Errorf("expected output 'hello|footer', got '%s' (error: %v)", out, err)
+	}
+
+	// errors are located by the full name
+	tpl, err = env.FromFile("mail/oops.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tpl.Execute(map[string]any{})
+	expected := "mail/oops.html:2:6: '' is not callable\n" +
+		"    2 |   {{ missing() }}\n" +
+		"      |      ^~~~~~~\n"
+	if err == nil {
+		t.Fatal("expected an error for 'mail/oops.html'")
+	}
+	if report := errors.NewReport(err).String(); report != expected {
+		t.Errorf("unexpected report:\n%s\nexpected:\n%s", report, expected)
+	}
+}
+
+func TestErrorLocations(t *testing.T) {
+	env := gonja.NewEnvironment(gonja.OptLoader(loaders.NewDictLoader(map[string]string{
+		"mail/base.html":    "<{% block content %}{% endblock %}>\n\n  {% block footer %}{{ missing() }}{% endblock %}",
+		"mail/broken.html":  "<{% block content %}{% endblock %}>\n{{ 1 + }}",
+		"mail/partial.html": "line\n  {{ missing() }}",
+		"mail/macros.html":  "{% macro m() %}\n{{ missing() }}{% endmacro %}",
+		"extends.html":      "{% extends 'mail/base.html' %}",
+		"extends_bad.html":  "{% extends 'mail/broken.html' %}",
+		"include.html":      "x{% include 'mail/partial.html' %}",
+		"import.html":       "{% from 'mail/macros.html' import m %}{{ m() }}",
+		"self.html":         "\n{% if true %}{{ missing() }}{% endif %}",
+	})))
+
+	for name, location := range map[string]string{
+		"extends.html":     "mail/base.html:3:24: ",
+		"extends_bad.html": "mail/broken.html:2:8: ",
+		"include.html":     "mail/partial.html:2:6: ",
+		"import.html":      "mail/macros.html:2:4: ",
+		"self.html":        "self.html:2:17: ",
+	} {
+		tpl, err := env.FromFile(name)
+		if err == nil {
+			_, err = tpl.Execute(nil)
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), location) {
+			t.Errorf("expected error of '%s' to start with '%s', got: %v", name, location, err)
+		}
+	}
+
+	tpl, err := env.FromFile("self.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Name != "self.html" || tpl.Root.Name != "self.html" {
+		t.Errorf("expected template name 'self.html', got '%s' and '%s'", tpl.Name, tpl.Root.Name)
+	}
+}
+
+func TestErrorReport(t *testing.T) {
+	env := gonja.NewEnvironment(gonja.OptLoader(loaders.NewDictLoader(map[string]string{
+		"page.html":    "{% from 'macros.html' import card %}\n<main>{% include 'partial.html' %}</main>",
+		"partial.html": "<p>\n  {{ card(user) }}\n</p>",
+		"macros.html":  "{% macro card(u) %}\n\t{{ u.name }} {{ missing() }}\n{% endmacro %}",
+		"broken.html":  "{% include 'bad.html' %}",
+		"bad.html":     "ok\n{{ 1 + }}",
+	})))
+
+	for name, expected := range map[string]string{
+		"page.html": "macros.html:2:18: '' is not callable\n" +
+			"    2 | \t{{ u.name }} {{ missing() }}\n" +
+			"      | \t                ^~~~~~~\n" +
+			"  in macro 'card' called from partial.html:2:10\n" +
+			"    2 |   {{ card(user) }}\n" +
+			"      |          ^\n" +
+			"  included from page.html:2:7\n" +
+			"    2 | <main>{% include 'partial.html' %}</main>\n" +
+			"      |       ^~\n",
+		"broken.html": "bad.html:2:8: expected a number, string, keyword or identifier\n" +
+			"    2 | {{ 1 + }}\n" +
+			"      |        ^~\n" +
+			"  included from broken.html:1:12\n" +
+			"    1 | {% include 'bad.html' %}\n" +
+			"      |            ^~~~~~~~\n",
+	} {
+		tpl, err := env.FromFile(name)
+		if err == nil {
+			_, err = tpl.Execute(map[string]any{"user": map[string]any{"name": "x"}})
+		}
+		if err == nil {
+			t.Fatalf("expected an error for '%s'", name)
+		}
+		if report := errors.NewReport(err).String(); report != expected {
+			t.Errorf("unexpected report for '%s':\n%s\nexpected:\n%s", name, report, expected)
+		}
+	}
+
+	tpl, err := env.FromFile("page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tpl.Execute(map[string]any{"user": map[string]any{"name": "x"}})
+	if err == nil {
+		t.Fatal("expected an error for 'page.html'")
+	}
+	data, err := errors.NewReport(err).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Kind     string
+		Location struct {
+			Template string
+			Line     int
+			Source   string
+		}
+		Traceback []struct {
+			Kind string
+			Name string
+		}
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Kind != "runtime" || decoded.Location.Template != "macros.html" || decoded.Location.Line != 2 ||
+		decoded.Location.Source != "\t{{ u.name }} {{ missing() }}" || len(decoded.Traceback) != 2 ||
+		decoded.Traceback[0].Name != "card" || decoded.Traceback[1].Kind != "include" {
+		t.Errorf("unexpected JSON report: %s", data)
+	}
+
+	// there is nothing to report without an error
+	if report := errors.NewReport(nil); report != nil || report.String() != "" {
+		t.Errorf("expected no report for a nil error, got %#v", report)
+	}
+}
+
+// panickingLoader is a loader that panics on every load.
+type panickingLoader struct{}
+
+func (panickingLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Template, error) {
+	panic("loader exploded")
+}
+
+// errorStmt is a statement that fails with an error.
+type errorStmt struct {
+	Location *parse.Token
+}
+
+func (stmt *errorStmt) Position() *parse.Token { return stmt.Location }
+func (stmt *errorStmt) String() string         { return "errorStmt" }
+
+func (stmt *errorStmt) ExecuteWithError(r *exec.Renderer, tag *parse.StatementBlockNode) error {
+	return stderrors.New("statement failed")
+}
+
+func TestReturnedErrors(t *testing.T) {
+	errFailed := stderrors.New("filter failed")
+	env := gonja.NewEnvironment()
+	env.Filters.Register("failing", exec.FilterWithError(func(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) (exec.Value, error) {
+		return nil, errFailed
+	}))
+	env.Filters.Register("panicking", func(e *exec.Evaluator, in exec.Value, params *exec.VarArgs) exec.Value {
+		var m map[string]int
+		m["x"] = 1
+		return in
+	})
+	env.Tests.Register("failing", exec.TestWithError(func(ctx *exec.Context, in exec.Value, params *exec.VarArgs) (bool, error) {
+		return false, stderrors.New("test failed")
+	}))
+	env.Statements.Register("fail", func(p, args *parse.Parser) parse.Statement {
+		return &errorStmt{Location: p.Current()}
+	})
+	env.Statements.Register("badparse", parse.ParserWithError(func(p, args *parse.Parser) (parse.Statement, error) {
+		return nil, stderrors.New("cannot parse")
+	}))
+
+	for source, expected := range map[string]string{
+		"\n{{ 'a' | failing }}":                         "string:2:4: filter failed",
+		"{{ 'a' | panicking }}":                         "string:1:4: filter panicking panicked: assignment to entry in nil map",
+		"{{ 'a' is failing }}":                          "string:1:4: test failed",
+		"{{ explode() }}":                               "string:1:11: function explode panicked: boom",
+		"{{ fail_err() }}":                              "string:1:12: call of function fail_err failed: function failed",
+		"x{% fail %}":                                   "string:1:2: statement failed",
+		"{% badparse 'a' %}":                            "string:1:13: cannot parse",
+		"{{ nested.explode() }}":                        "string:1:18: function explode panicked: boom",
+		"{% for i in [1] %}{{ explode() }}{% endfor %}": "string:1:29: function explode panicked: boom",
+	} {
+		var err error
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					t.Errorf("unexpected panic for '%s': %v", source, rec)
+				}
+			}()
+			var tpl *exec.Template
+			tpl, err = env.FromString(source)
+			if err == nil {
+				_, err = tpl.Execute(map[string]any{
+					"explode":  func() string { panic("boom") },
+					"fail_err": func() (string, error) { return "", stderrors.New("function failed") },
+					"nested":   map[string]any{"explode": func() string { panic("boom") }},
+				})
+			}
+		}()
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("expected error of '%s' to start with '%s', got: %v", source, expected, err)
+		}
+	}
+
+	// the returned error is kept as cause
+	tpl, err := env.FromString("{{ 'a' | failing }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tpl.Execute(nil); !stderrors.Is(err, errFailed) {
+		t.Errorf("expected error to wrap the filter error, got: %v", err)
+	}
+
+	// panics of loaders are returned as load errors
+	env = gonja.NewEnvironment(gonja.OptLoader(panickingLoader{}))
+	if _, err := env.FromFile("index.html"); err == nil || !strings.Contains(err.Error(), "loader exploded") {
+		t.Errorf("expected load error, got: %v", err)
+	}
+	tpl, err = env.FromString("{% include 'index.html' %}")
+	if err == nil {
+		_, err = tpl.Execute(nil)
+	}
+	if err == nil || !strings.Contains(err.Error(), "loader exploded") {
+		t.Errorf("expected load error, got: %v", err)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	source := "{{ 1 + }}\n" +
+		"{% unknown %}\n" +
+		"{% for x in %}{{ x }}{% endfor %}\n" +
+		"{% if true %}{{ a b }}{% endif %}\n" +
+		"{{ ok }}{% endblock %}"
+	_, err := gonja.FromString(source)
+	serrs, ok := err.(errors.TemplateSyntaxErrors)
+	if !ok {
+		t.Fatalf("expected multiple syntax errors, got: %v", err)
+	}
+
+	expected := []string{
+		"string:1:8: expected a number, string, keyword or identifier",
+		"string:2:4: statement 'unknown' not found",
+		"string:3:13: expected a number, string, keyword or identifier",
+		"string:4:19: unexpected 'b' , expected '}}'",
+		"string:5:12: statement 'endblock' not found",
+	}
+	if len(serrs.Errors()) != len(expected) {
+		t.Fatalf("expected %d errors, got: %v", len(expected), err)
+	}
+	for i, serr := range serrs.Errors() {
+		if !strings.HasPrefix(serr.Error(), expected[i]) {
+			t.Errorf("expected error %d to start with '%s', got: %v", i, expected[i], serr)
+		}
+	}
+
+	// the combined error behaves like its first error
+	if serrs.Token().Line != 1 || serrs.Message() != "expected a number, string, keyword or identifier" {
+		t.Errorf("unexpected first error: %v", serrs.Token())
+	}
+	if report := errors.NewReport(err); len(report.Errors) != len(expected) {
+		t.Errorf("expected report of %d errors, got: %s", len(expected), report)
+	}
+
+	// a single error is returned as is
+	_, err = gonja.FromString("{{ 1 + }}")
+	if _, ok := err.(errors.TemplateSyntaxErrors); ok || err == nil {
+		t.Errorf("expected a single syntax error, got: %v", err)
+	}
+
+	// the intermediate tags of a failed block cause no follow-up errors
+	for _, source := range []string{
+		"{% if %}a{% else %}b{% endif %}",
+		"{% if %}a{% elif x %}b{% else %}c{% endif %}",
+		"{% for %}a{% else %}b{% endfor %}",
+		"{% if %}{% for x in y %}a{% else %}b{% endfor %}{% else %}c{% endif %}",
+	} {
+		_, err = gonja.FromString(source)
+		if _, ok := err.(errors.TemplateSyntaxErrors); ok || err == nil {
+			t.Errorf("expected exactly one syntax error for '%s', got: %v", source, err)
+		}
+	}
+}
+
+func TestContextualAutoescape(t *testing.T) {
+	data := map[string]any{
+		"html":  "<b>",
+		"quote": `a"b'`,
+		"space": "a b",
+		"js":    "javascript:alert(1)",
+		"link":  "https://example.org/?a=1&b=2",
+		"query": "a&b c",
+		"css":   "red;background:url(x)",
+		"list":  []any{1, "</script>"},
+		"attr":  "onclick=alert(1)",
+	}
+	cases := []struct {
+		name   string
+		source string
+		output string
+	}{
+		{"text", "<p>{{ html }}</p>", "<p>&lt;b&gt;</p>"},
+		{"safe text", "<p>{{ html|safe }}</p>", "<p><b></p>"},
+		{"textarea", "<textarea>{{ html }}</textarea>", "<textarea>&lt;b&gt;</textarea>"},
+		{"quoted attribute", `<a title="{{ quote }}">`, `<a title="a&#34;b&#39;">`},
+		{"unquoted attribute", "<a title={{ space }}>", "<a title=a&#32;b>"},
+		{"unsafe url", `<a href="{{ js }}">`, `<a href="#ZgotmplZ">`},
+		{"url", `<a href="{{ link }}">`, `<a href="https://example.org/?a=1&amp;b=2">`},
+		{"url query", `<a href="/search?q={{ query }}">`, `<a href="/search?q=a%26b%20c">`},
+		{"safe url", `<a href="{{ js|safe_url }}">`, `<a href="javascript:alert(1)">`},
+		{"event handler", `<button onclick="f({{ html }})">`, `<button onclick="f(&#34;\u003cb\u003e&#34;)">`},
+		{"script", "<script>var l = {{ list }};</script>", `<script>var l = [1,"\u003c/script\u003e"];</script>`},
+		{"script literal", "<script>var l = {{ [1, html] }};</script>", `<script>var l = [1,"\u003cb\u003e"];</script>`},
+		{"script string", "<script>var s = '{{ quote }}';</script>", `<script>var s = 'a\u0022b\u0027';</script>`},
+		{"script safe", "<script>{{ html|safe }}</script>", `<script>"\u003cb\u003e"</script>`},
+		{"script safe_js", "<script>{{ 'f(1)'|safe_js }}</script>", "<script>f(1)</script>"},
+		{"script tojson", "<script>var l = {{ list|tojson }};</script>", `<script>var l = [1,"\u003c/script\u003e"];</script>`},
+		{"after script", "<script>x = 1;</script>{{ html }}", "<script>x = 1;</script>&lt;b&gt;"},
+		{"loop in script", "<script>{% for i in [1] %}f({{ html }});{% endfor %}</script>", `<script>f("\u003cb\u003e");</script>`},
+		{"style attribute", `<p style="color: {{ css }}">`, `<p style="color: red\3b background\3a url\28 x\29 ">`},
+		{"safe_css", `<p style="{{ 'color: red'|safe_css }}">`, `<p style="color: red">`},
+		{"attribute name", "<p {{ attr }}>", "<p ZgotmplZ>"},
+		{"safe_attr", `<p {{ 'dir="ltr"'|safe_attr }}>`, `<p dir="ltr">`},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			env := testutils.TestEnv("./testdata", gonja.OptContextualAutoescape())
+			tpl, err := env.FromString(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tpl.Execute(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.output {
+				t.Errorf("expected output '%s', got '%s'", test.output, out)
+			}
+		})
+	}
+}
+
+func TestAutoescapeFn(t *testing.T) {
+	env := gonja.NewEnvironment(
+		gonja.OptAutoescapeFn(exec.SelectEscaper(map[string]exec.EscapeFn{
+			".html": nil,
+			".sh":   exec.EscapeShell,
+			".yaml": exec.EscapeYAML,
+		})),
+		gonja.OptLoader(loaders.NewDictLoader(map[string]string{
+			"page.html":  "{{ s }}|{% include 'part.txt' %}|{{ s|safe }}",
+			"part.txt":   "{{ s }}",
+			"run.sh":     "echo {{ s }} {{ q }}",
+			"conf.yaml":  "key: {{ s }}\nlist: {{ l }}\nnum: {{ n }}",
+			"lists.yaml": "literal: {{ [s, 1] }}\nsplit: {{ 'a b'.split() }}\nfilter: {{ l|list }}\ndict: {{ {'k': [n]} }}",
+		})),
+	)
+	data := map[string]any{"s": "<b> & 'c'", "q": "it's", "l": []any{"a", 1}, "n": 2}
+	cases := []struct {
+		name   string
+		output string
+	}{
+		{"page.html", "&lt;b&gt; &amp; &#39;c&#39;|<b> & 'c'|<b> & 'c'"},
+		{"part.txt", "<b> & 'c'"},
+		{"run.sh", `echo '<b> & '\''c'\''' 'it'\''s'`},
+		{"conf.yaml", "key: \"\\u003cb\\u003e \\u0026 'c'\"\nlist: [\"a\",1]\nnum: 2"},
+		{"lists.yaml", "literal: [\"\\u003cb\\u003e \\u0026 'c'\",1]\nsplit: [\"a\",\"b\"]\nfilter: [\"a\",1]\ndict: {\"k\":[2]}"},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := env.FromFile(test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tpl.Execute(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.output {
+				t.Errorf("expected output '%s', got '%s'", test.output, out)
+			}
+		})
+	}
+
+	t.Run("string", func(t *testing.T) {
+		env := gonja.NewEnvironment(gonja.OptAutoescapeFn(exec.SelectAutoescape(".html")))
+		tpl, err := env.FromString("<p>{{ x }}</p>")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := tpl.Execute(map[string]any{"x": "<script>"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != "<p>&lt;script&gt;</p>" {
+			t.Errorf("expected string templates to be escaped, got '%s'", out)
+		}
+	})
+
+	t.Run("selector", func(t *testing.T) {
+		fn := exec.EscaperSelector{
+			Escapers: map[string]exec.EscapeFn{".sh": exec.EscapeShell},
+			Disabled: []string{".txt"},
+			Default:  true,
+		}.Fn()
+		for name, want := range map[string]bool{
+			"run.sh":                true,
+			"part.txt":              false,
+			"page.html":             true,
+			"other":                 true,
+			exec.StringTemplateName: false,
+		} {
+			if got, _ := fn(name); got != want {
+				t.Errorf("expected autoescape of '%s' to be %t", name, want)
+			}
+		}
+		if _, escaper := fn("run.SH"); escaper == nil {
+			t.Error("expected the shell escaper for 'run.SH'")
+		}
+	})
+}
+
+// countingIterator yields the integers up to max and counts the pulled items.
+type countingIterator struct {
+	pulled, max int
+}
+
+func (it *countingIterator) Next() (any, bool) {
+	if it.pulled >= it.max {
+		return nil, false
+	}
+	it.pulled++
+	return it.pulled - 1, true
+}
+
+func TestLazyIterators(t *testing.T) {
+	it := &countingIterator{max: 1000000}
+	pairs := func(yield func(string, int) bool) {
+		for i, key := range []string{"a", "b", "c"} {
+			if !yield(key, i) {
+				return
+			}
+		}
+	}
+	data := map[string]any{"it": it, "pairs": pairs, "empty": &countingIterator{}}
+	cases := []struct {
+		name   string
+		source string
+		output string
+	}{
+		{"break", "{% for i in it %}{% if i == 3 %}{% break %}{% endif %}{{ i }}{% endfor %}", "012"},
+		{"seq2", "{% for k, v in pairs %}{{ k }}={{ v }}{% if not loop.last %},{% endif %}{% endfor %}", "a=0,b=1,c=2"},
+		{"seq2 break", "{% for k, v in pairs %}{{ k }}{% break %}{% endfor %}", "a"},
+		{"unknown length", "{% for k, v in pairs %}{{ loop.length is defined }}{% endfor %}", "FalseFalseFalse"},
+		{"list", "{{ pairs|list }}", "['a', 'b', 'c']"},
+		{"empty", "{% for i in empty %}{{ i }}{% else %}empty{% endfor %}", "empty"},
+		{"range", "{{ range(3) }}: {% for i in range(3) %}{{ i }}/{{ loop.length }} {% endfor %}", "range(0, 3): 0/3 1/3 2/3 "},
+		{"negative step", "{{ range(5, 0, -2)|join(',') }}|{{ range(10, 0, -3)|length }}", "5,3,1|4"},
+		{"range break", "{% for i in range(1000000000) %}{% if i == 2 %}{% break %}{% endif %}{{ i }}{% endfor %}", "01"},
+		{"first", "{{ range(5, 10)|first }}", "5"},
+		{"range contains", "{{ 3 in range(5) }} {{ 5 in range(5) }} {{ 4 in range(0, 10, 2) }} {{ 3 in range(0, 10, 2) }} {{ 2 in range(5, 0, -3) }} {{ 'a' in range(5) }}", "True False True False True False"},
+		{"seq contains", "{{ 'b' in pairs }} {{ 'x' in pairs }}", "True False"},
+		{"range tojson", "{{ range(3)|tojson }} {{ range(0)|tojson }}", "[0,1,2] []"},
+		{"seq tojson", "{{ pairs|tojson }}", `{"a":0,"b":1,"c":2}`},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := gonja.FromString(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tpl.Execute(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.output {
+				t.Errorf("expected output '%s', got '%s'", test.output, out)
+			}
+		})
+	}
+	// the item following the break has been pulled to provide loop.nextitem
+	if it.pulled != 5 {
+		t.Errorf("expected the iterator to be consumed lazily, pulled %d items", it.pulled)
+	}
+
+	if _, err := gonja.Must(gonja.FromString("{{ range(1, 2, 0)|list }}")).Execute(nil); err == nil {
+		t.Error("expected an error for a zero step")
+	}
+}
+
+// orderedMap is a minimal ordered map with string keys, like the ones used to
+// decode JSON or YAML in order.
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func newOrderedMap(kv ...any) *orderedMap {
+	m := &orderedMap{values: map[string]any{}}
+	for i := 0; i < len(kv); i += 2 {
+		m.Store(kv[i], kv[i+1])
+	}
+	return m
+}
+
+func (m *orderedMap) Len() int { return len(m.keys) }
+
+func (m *orderedMap) Range(fn func(key, value any) bool) {
+	for _, key := range m.keys {
+		if !fn(key, m.values[key]) {
+			return
+		}
+	}
+}
+
+func (m *orderedMap) Load(key any) (any, bool) {
+	k, _ := key.(string)
+	value, ok := m.values[k]
+	return value, ok
+}
+
+func (m *orderedMap) Store(key, value any) {
+	k := key.(string)
+	if _, ok := m.values[k]; !ok {
+		m.keys = append(m.keys, k)
+	}
+	m.values[k] = value
+}
+
+func (m *orderedMap) Delete(key any) bool {
+	k, _ := key.(string)
+	if _, ok := m.values[k]; !ok {
+		return false
+	}
+	delete(m.values, k)
+	for i, existing := range m.keys {
+		if existing == k {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func TestOrderedMap(t *testing.T) {
+	var _ exec.OrderedMap = (*orderedMap)(nil)
+	newData := func() map[string]any {
+		return map[string]any{
+			"m":     newOrderedMap("zulu", 1, "alpha", 2, "mike", newOrderedMap("y", true, "x", nil)),
+			"empty": newOrderedMap(),
+		}
+	}
+	cases := []struct {
+		name   string
+		source string
+		output string
+	}{
+		{"for", "{% for k, v in m %}{{ k }}{% if not loop.last %},{% endif %}{% endfor %}", "zulu,alpha,mike"},
+		{"items", "{% for k, v in m|items %}{{ k }}={{ v }};{% endfor %}", "zulu=1;alpha=2;mike={'y': True, 'x': None};"},
+		{"reverse", "{{ m|reverse|join(',') }}", "mike,alpha,zulu"},
+		{"list", "{{ m|list }}", "['zulu', 'alpha', 'mike']"},
+		{"get item", "{{ m.alpha }} {{ m['zulu'] }} {{ m.mike.y }} {{ m.missing is defined }}", "2 1 True False"},
+		{"contains", "{{ 'mike' in m }} {{ 'x' in m }}", "True False"},
+		{"length", "{{ m|length }} {{ empty|length }} {{ m is mapping }}", "3 0 True"},
+		{"empty", "{% if empty %}full{% else %}empty{% endif %}", "empty"},
+		{"dictsort", "{{ m|dictsort }}", "[['alpha', 2], ['mike', {'y': True, 'x': None}], ['zulu', 1]]"},
+		{"dictsort by value", "{% for k, v in {'b': 1, 'a': 0, 'c': 1}|dictsort(by='value') %}{{ k }}{% endfor %}", "abc"},
+		{"tojson", "{{ m|tojson }}", `{"zulu":1,"alpha":2,"mike":{"y":true,"x":null}}`},
+		{"literal", `{{ {"b": 1, "a": [2, {"d": 3, "c": 4}]}|tojson }}`, `{"b":1,"a":[2,{"d":3,"c":4}]}`},
+		{"dict", "{{ dict(b=1, a=2)|tojson }} {{ dict(b=1, a=2) }}", `{"b":1,"a":2} {'b': 1, 'a': 2}`},
+		{"set", "{% set _ = m.update(beta=3) %}{% set _ = m.pop('zulu') %}{{ m|list }}", "['alpha', 'mike', 'beta']"},
+		{"setdefault", "{{ m.setdefault('new', 4) }}{{ m.setdefault('alpha', 5) }}{{ m|list|last }}", "42new"},
+	}
+	for _, c := range cases {
+		test := c
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := gonja.FromString(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := tpl.Execute(newData())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.output {
+				t.Errorf("expected output '%s', got '%s'", test.output, out)
+			}
+		})
+	}
+}
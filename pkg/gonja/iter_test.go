@@ -0,0 +1,24 @@
+//go:build go1.23
+
+package gonja_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aisbergg/gonja/pkg/gonja"
+)
+
+func TestGoIterators(t *testing.T) {
+	tpl := gonja.Must(gonja.FromString("{% for v in values %}{{ v }}{% endfor %}|{% for i, v in all %}{{ i }}{{ v }}{% endfor %}"))
+	out, err := tpl.Execute(map[string]any{
+		"values": slices.Values([]string{"a", "b"}),
+		"all":    slices.All([]string{"a", "b"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "ab|0a1b"; out != expected {
+		t.Errorf("expected output '%s', got '%s'", expected, out)
+	}
+}
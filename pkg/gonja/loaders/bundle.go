@@ -0,0 +1,205 @@
+package loaders
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"github.com/aisbergg/gonja/pkg/gonja/parse"
+)
+
+// bundleVersion is the version of the bundle format. It must be increased on
+// incompatible changes of the format or the parsed nodes.
+const bundleVersion = 2
+
+// bundle is the serialized form of a template bundle.
+type bundle struct {
+	Version   int
+	Templates map[string]*bundledTemplate
+}
+
+// bundledTemplate is a precompiled template in a bundle.
+type bundledTemplate struct {
+	Root         *parse.TemplateNode
+	Dependencies []string
+	// Source is kept for the source excerpts of error reports.
+	Source string
+}
+
+// WriteBundle writes the given templates as bundle of precompiled templates,
+// which can be loaded with a [BundleLoader]. The templates are stored by their
+// names, including their resolved parents and statically included templates.
+//
+// Statements of extensions must be registered with [gob.Register] to be
+// written into a bundle.
+func WriteBundle(w io.Writer, templates ...*exec.Template) error {
+	b := bundle{
+		Version:   bundleVersion,
+		Templates: make(map[string]*bundledTemplate, len(templates)),
+	}
+	for _, tpl := range templates {
+		b.Templates[cleanTemplatePath(tpl.Name)] = &bundledTemplate{
+			Root:         tpl.Root,
+			Dependencies: tpl.Dependencies,
+			Source:       tpl.Source,
+		}
+	}
+	if err := gob.NewEncoder(w).Encode(&b); err != nil {
+		return errors.NewTemplateLoadError("", "failed to write bundle: %s", err)
+	}
+	return nil
+}
+
+// BundleLoader represents a loader for precompiled templates, that have been
+// written with [WriteBundle]. Loading templates from a bundle doesn't require
+// lexing and parsing them.
+//
+// Example:
+//
+//	//go:embed templates.bundle
+//	var templates []byte
+//	loader := loaders.MustNewBundleLoader(bytes.NewReader(templates))
+type BundleLoader struct {
+	templates map[string]*bundledTemplate
+
+	// prefixed caches the copies of templates loaded with a name prefix (see
+	// [exec.EvalConfig.NamePrefix])
+	mu       sync.Mutex
+	prefixed map[prefixedName]*bundledTemplate
+}
+
+// prefixedName is the name of a template loaded with a name prefix.
+type prefixedName struct {
+	prefix, name string
+}
+
+// MustNewBundleLoader creates a new BundleLoader. It panics if an error occurs.
+func MustNewBundleLoader(r io.Reader) *BundleLoader {
+	loader, err := NewBundleLoader(r)
+	if err != nil {
+		panic(err)
+	}
+	return loader
+}
+
+// NewBundleLoader creates a new [BundleLoader], that reads the bundle from r.
+func NewBundleLoader(r io.Reader) (*BundleLoader, error) {
+	var b bundle
+	if err := gob.NewDecoder(r).Decode(&b); err != nil {
+		return nil, errors.NewTemplateLoadError("", "failed to read bundle: %s", err)
+	}
+	if b.Version != bundleVersion {
+		return nil, errors.NewTemplateLoadError("", "unsupported bundle version %d, expected %d", b.Version, bundleVersion)
+	}
+	return &BundleLoader{
+		templates: b.Templates,
+	}, nil
+}
+
+// Load returns a template by name.
+func (bl *BundleLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Template, error) {
+	key := cleanTemplatePath(name)
+	bundled, ok := bl.templates[key]
+	if !ok {
+		return nil, errors.NewTemplateNotFoundError(name)
+	}
+	if cfg.NamePrefix != "" {
+		var err error
+		if bundled, err = bl.withPrefix(cfg.NamePrefix, key, bundled); err != nil {
+			return nil, errors.NewTemplateLoadError(name, "failed to load template '%s': %s", name, err)
+		}
+	}
+	return &exec.Template{
+		Name:         cfg.NamePrefix + name,
+		Env:          cfg,
+		Root:         bundled.Root,
+		Dependencies: bundled.Dependencies,
+		Source:       bundled.Source,
+	}, nil
+}
+
+// withPrefix returns a copy of the bundled template with the prefix prepended
+// to the template names of its nodes and dependencies, like the template had
+// been parsed by its full name.
+func (bl *BundleLoader) withPrefix(prefix, name string, bundled *bundledTemplate) (*bundledTemplate, error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	key := prefixedName{prefix, name}
+	if cpy, ok := bl.prefixed[key]; ok {
+		return cpy, nil
+	}
+
+	var (
+		buf bytes.Buffer
+		cpy *bundledTemplate
+	)
+	if err := gob.NewEncoder(&buf).Encode(bundled); err != nil {
+		return nil, err
+	}
+	if err := gob.NewDecoder(&buf).Decode(&cpy); err != nil {
+		return nil, err
+	}
+	prefixTokens(reflect.ValueOf(cpy.Root), prefix)
+	cpy.Root.Name = prefix + cpy.Root.Name
+	for i, dep := range cpy.Dependencies {
+		cpy.Dependencies[i] = prefix + dep
+	}
+
+	if bl.prefixed == nil {
+		bl.prefixed = map[prefixedName]*bundledTemplate{}
+	}
+	bl.prefixed[key] = cpy
+	return cpy, nil
+}
+
+// rtToken is the type of the tokens referenced by the nodes.
+var rtToken = reflect.TypeOf(parse.Token{})
+
+// prefixTokens prepends the prefix to the template names of all tokens
+// reachable from val. The nodes of a decoded bundle form a tree, so each
+// token is reached once.
+func prefixTokens(val reflect.Value, prefix string) {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !val.IsNil() {
+			prefixTokens(val.Elem(), prefix)
+		}
+	case reflect.Struct:
+		if val.Type() == rtToken {
+			if val.CanSet() && val.FieldByName("Template").String() != "" {
+				tpl := val.FieldByName("Template")
+				tpl.SetString(prefix + tpl.String())
+			}
+			return
+		}
+		for i := 0; i < val.NumField(); i++ {
+			if val.Type().Field(i).IsExported() {
+				prefixTokens(val.Field(i), prefix)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			prefixTokens(val.Index(i), prefix)
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+		for iter.Next() {
+			prefixTokens(iter.Value(), prefix)
+		}
+	}
+}
+
+// Names returns the sorted names of the templates in the bundle.
+func (bl *BundleLoader) Names() []string {
+	names := make([]string, 0, len(bl.templates))
+	for name := range bl.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -9,45 +9,145 @@ import (
 
 // CachedLoader represents a cached loader for templates. It wraps another
 // loader and caches the loaded templates.
+//
+// With auto reload enabled, the cache checks the version of a template source
+// on every load and reloads the template, if the source has changed. Templates
+// depending on a changed template (e.g. using `extends`, `include` or
+// `import`) are reloaded as well. Auto reload requires the wrapped loader to
+// be a [VersionedLoader].
 type CachedLoader struct {
 	loader     Loader
-	cache      map[string]*exec.Template
+	autoReload bool
+	cache      map[string]*cacheEntry
+	// dependents maps a template name to the names of the cached templates
+	// depending on it
+	dependents map[string]map[string]struct{}
 	cacheMutex sync.Mutex
 }
 
+// cacheEntry is a cached template.
+type cacheEntry struct {
+	tpl          *exec.Template
+	version      string
+	dependencies []string
+}
+
 // NewCachedLoader creates a new [CachedLoader].
 func NewCachedLoader(loader Loader) *CachedLoader {
+	return NewCachedLoaderWithOptions(loader, false)
+}
+
+// NewCachedLoaderWithOptions creates a new [CachedLoader] with the given
+// options.
+//
+//   - loader: The loader to load the templates with.
+//   - autoReload: If true, changed templates are reloaded. This has no
+//     effect, if the loader doesn't implement [VersionedLoader].
+func NewCachedLoaderWithOptions(loader Loader, autoReload bool) *CachedLoader {
+	_, versioned := loader.(VersionedLoader)
 	return &CachedLoader{
-		loader: loader,
-		cache:  make(map[string]*exec.Template),
+		loader:     loader,
+		autoReload: autoReload && versioned,
+		cache:      make(map[string]*cacheEntry),
+		dependents: make(map[string]map[string]struct{}),
 	}
 }
 
 // Load returns a template by name.
 func (fs *CachedLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Template, error) {
 	name = filepath.Clean(name)
-	fs.cacheMutex.Lock()
-	defer fs.cacheMutex.Unlock()
+	if fs.autoReload {
+		fs.refresh(name, map[string]bool{})
+	}
 
 	// check if template is cached
-	if t, ok := fs.cache[name]; ok {
-		return t, nil
+	fs.cacheMutex.Lock()
+	entry, ok := fs.cache[name]
+	fs.cacheMutex.Unlock()
+	if ok {
+		return entry.tpl, nil
+	}
+
+	// get the version before loading, so changes made while loading are
+	// detected on the next load
+	var version string
+	if fs.autoReload {
+		var err error
+		if version, err = fs.loader.(VersionedLoader).Version(name); err != nil {
+			return nil, err
+		}
 	}
 
-	// load template from wrapped loader
+	// load template from wrapped loader; the lock must not be held, since
+	// dependencies of the template are loaded through the cache as well
 	tpl, err := fs.loader.Load(name, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	// cache template for later use
-	fs.cache[name] = tpl
+	entry = &cacheEntry{tpl: tpl, version: version}
+	fs.cacheMutex.Lock()
+	defer fs.cacheMutex.Unlock()
+	fs.cache[name] = entry
+	for _, dependency := range tpl.Dependencies {
+		dependency = filepath.Clean(dependency)
+		entry.dependencies = append(entry.dependencies, dependency)
+		if fs.dependents[dependency] == nil {
+			fs.dependents[dependency] = map[string]struct{}{}
+		}
+		fs.dependents[dependency][name] = struct{}{}
+	}
 	return tpl, nil
 }
 
+// refresh invalidates a cached template, if its source or the source of one
+// of its dependencies has changed.
+func (fs *CachedLoader) refresh(name string, visited map[string]bool) {
+	if visited[name] {
+		return
+	}
+	visited[name] = true
+
+	fs.cacheMutex.Lock()
+	entry, ok := fs.cache[name]
+	fs.cacheMutex.Unlock()
+	if !ok {
+		return
+	}
+	version, err := fs.loader.(VersionedLoader).Version(name)
+	if err != nil || version != entry.version {
+		fs.Invalidate(name)
+		return
+	}
+	for _, dependency := range entry.dependencies {
+		fs.refresh(dependency, visited)
+	}
+}
+
+// Invalidate removes a template and all templates depending on it from the
+// cache.
+func (fs *CachedLoader) Invalidate(name string) {
+	fs.cacheMutex.Lock()
+	defer fs.cacheMutex.Unlock()
+	fs.invalidate(filepath.Clean(name))
+}
+
+// invalidate removes a template and its dependents transitively from the
+// cache. The caller must hold the lock.
+func (fs *CachedLoader) invalidate(name string) {
+	delete(fs.cache, name)
+	dependents := fs.dependents[name]
+	delete(fs.dependents, name)
+	for dependent := range dependents {
+		fs.invalidate(dependent)
+	}
+}
+
 // Clear clears the cache of the loader.
 func (fs *CachedLoader) Clear() {
 	fs.cacheMutex.Lock()
 	defer fs.cacheMutex.Unlock()
-	fs.cache = make(map[string]*exec.Template)
+	fs.cache = make(map[string]*cacheEntry)
+	fs.dependents = make(map[string]map[string]struct{})
 }
@@ -0,0 +1,64 @@
+package loaders
+
+import (
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+)
+
+// ChoiceLoader represents a loader that tries multiple loaders in order and
+// returns the first found template. Unlike the [MergedLoader], it only falls
+// through to the next loader, if a template is not found. Other errors (e.g.
+// syntax errors) are returned unchanged.
+//
+// Example:
+//
+//	loader := loaders.NewChoiceLoader(
+//		loaders.MustNewFileSystemLoader("overrides"),
+//		loaders.MustNewFileSystemLoader("defaults"),
+//	)
+type ChoiceLoader struct {
+	loaders []Loader
+}
+
+// NewChoiceLoader creates a new [ChoiceLoader].
+func NewChoiceLoader(loaders ...Loader) *ChoiceLoader {
+	return &ChoiceLoader{
+		loaders: loaders,
+	}
+}
+
+// Load returns a template by name.
+func (cl *ChoiceLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Template, error) {
+	for _, loader := range cl.loaders {
+		tpl, err := loader.Load(name, cfg)
+		if err == nil {
+			return tpl, nil
+		}
+		if _, ok := err.(errors.TemplateNotFoundError); !ok {
+			return nil, err
+		}
+	}
+
+	return nil, errors.NewTemplateNotFoundError(name)
+}
+
+// Version returns the version of a template source reported by the first
+// loader that knows the template. Loaders that don't provide versions are
+// skipped.
+func (cl *ChoiceLoader) Version(name string) (string, error) {
+	for _, loader := range cl.loaders {
+		versioned, ok := loader.(VersionedLoader)
+		if !ok {
+			continue
+		}
+		version, err := versioned.Version(name)
+		if err == nil {
+			return version, nil
+		}
+		if _, ok := err.(errors.TemplateNotFoundError); !ok {
+			return "", err
+		}
+	}
+
+	return "", errors.NewTemplateNotFoundError(name)
+}
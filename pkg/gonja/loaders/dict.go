@@ -0,0 +1,52 @@
+package loaders
+
+import (
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+)
+
+// DictLoader represents a loader for templates kept in memory. The templates
+// are given as map of template names to template sources.
+//
+// Example:
+//
+//	loader := loaders.NewDictLoader(map[string]string{
+//		"base.html":  "<body>{% block content %}{% endblock %}</body>",
+//		"index.html": "{% extends 'base.html' %}{% block content %}Hello{% endblock %}",
+//	})
+type DictLoader struct {
+	templates map[string]string
+}
+
+// NewDictLoader creates a new [DictLoader]. The template names are cleaned
+// like paths, so `index.html` and `./index.html` refer to the same template.
+func NewDictLoader(templates map[string]string) *DictLoader {
+	cleaned := make(map[string]string, len(templates))
+	for name, source := range templates {
+		cleaned[cleanTemplatePath(name)] = source
+	}
+	return &DictLoader{
+		templates: cleaned,
+	}
+}
+
+// Load returns a template by name.
+func (dl *DictLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Template, error) {
+	source, ok := dl.templates[cleanTemplatePath(name)]
+	if !ok {
+		return nil, errors.NewTemplateNotFoundError(name)
+	}
+	return loadTemplate(name, strings.NewReader(source), cfg)
+}
+
+// Version returns the version of a template source, which is a hash of the
+// source.
+func (dl *DictLoader) Version(name string) (string, error) {
+	source, ok := dl.templates[cleanTemplatePath(name)]
+	if !ok {
+		return "", errors.NewTemplateNotFoundError(name)
+	}
+	return hashSource([]byte(source)), nil
+}
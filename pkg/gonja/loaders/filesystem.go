@@ -1,9 +1,8 @@
 package loaders
 
 import (
-	"bufio"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -111,23 +110,38 @@ func (fs *FilesystemLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Templ
 		return nil, err
 	}
 	defer reader.Close()
-	buf, err := ioutil.ReadAll(reader)
+	return loadTemplate(name, reader, cfg)
+}
+
+// Version returns the version of a template source, which is derived from the
+// modification time and size of the file.
+func (fs *FilesystemLoader) Version(name string) (string, error) {
+	_, stat, err := fs.findFile(name)
 	if err != nil {
-		return nil, errors.NewTemplateLoadError(name, "error loading template: %s", err)
+		return "", err
 	}
+	return fmt.Sprintf("%d-%d", stat.ModTime().UnixNano(), stat.Size()), nil
+}
 
-	// parse template
-	tpl, err := exec.NewTemplate(name, string(buf), cfg)
+// loadFile goes through the search paths and returns the contents of the first
+// file that is found.
+func (fs *FilesystemLoader) loadFile(path string) (io.ReadCloser, error) {
+	absPath, _, err := fs.findFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return tpl, nil
+	// open file
+	file, err := os.Open(absPath)
+	if err != nil {
+		return nil, errors.NewTemplateLoadError(path, "failed to open file '%s': %s", path, err)
+	}
+	return decodeFile(file, fs.encoding), nil
 }
 
-// loadFile goes through the search paths and returns the contents of the first
-// file that is found.
-func (fs *FilesystemLoader) loadFile(path string) (io.ReadCloser, error) {
+// findFile goes through the search paths and returns the absolute path and
+// file info of the first file that is found.
+func (fs *FilesystemLoader) findFile(path string) (string, os.FileInfo, error) {
 	// clean path to prevent directory traversal
 	cleanedPath := filepath.Join("/", path)
 
@@ -135,7 +149,7 @@ func (fs *FilesystemLoader) loadFile(path string) (io.ReadCloser, error) {
 		absPath := filepath.Join(searchPath, cleanedPath)
 		// check if path exists
 		stat, err := os.Stat(absPath)
-		if os.IsNotExist(err) {
+		if err != nil {
 			continue
 		}
 		// check if path is a file
@@ -146,25 +160,8 @@ func (fs *FilesystemLoader) loadFile(path string) (io.ReadCloser, error) {
 		if !fs.followingLinks && stat.Mode()&os.ModeSymlink != 0 {
 			continue
 		}
-
-		// open file
-		file, err := os.Open(absPath)
-		if err != nil {
-			return nil, errors.NewTemplateLoadError(cleanedPath, "failed to open file '%s': %s", path, err)
-		}
-		reader := bufio.NewReader(file)
-		if fs.encoding != nil {
-			// use configured encoding
-			decoder := fs.encoding.NewDecoder()
-			reader = bufio.NewReader(decoder.Reader(reader))
-		}
-
-		// return a ReadCloser that wraps the reader and the file
-		return struct {
-			io.Reader
-			io.Closer
-		}{reader, file}, nil
+		return absPath, stat, nil
 	}
 
-	return nil, errors.NewTemplateNotFoundError(path)
+	return "", nil, errors.NewTemplateNotFoundError(path)
 }
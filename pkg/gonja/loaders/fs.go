@@ -0,0 +1,193 @@
+package loaders
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"golang.org/x/text/encoding"
+)
+
+// FSLoader represents a loader for templates stored in a [fs.FS], e.g. an
+// [embed.FS] or a [fstest.MapFS].
+//
+// Search paths are directories inside the file system. Like the
+// [FilesystemLoader], the loader prevents access to files outside of the
+// search paths.
+//
+// Example:
+//
+//	//go:embed templates
+//	var templates embed.FS
+//	loader := loaders.MustNewFSLoader(templates, "templates")
+type FSLoader struct {
+	fsys           fs.FS
+	searchPaths    []string
+	encoding       encoding.Encoding
+	followingLinks bool
+}
+
+// MustNewFSLoader creates a new FSLoader. It panics if an error occurs.
+func MustNewFSLoader(fsys fs.FS, searchPaths ...string) *FSLoader {
+	loader, err := NewFSLoader(fsys, searchPaths...)
+	if err != nil {
+		panic(err)
+	}
+	return loader
+}
+
+// NewFSLoader creates a new [FSLoader]. If no search paths are given, the
+// templates are searched in the root of the file system.
+func NewFSLoader(fsys fs.FS, searchPaths ...string) (*FSLoader, error) {
+	return NewFSLoaderWithOptions(fsys, nil, false, searchPaths...)
+}
+
+// MustNewFSLoaderWithOptions creates a new FSLoader with the given options. It
+// panics if an error occurs.
+func MustNewFSLoaderWithOptions(
+	fsys fs.FS,
+	encoding encoding.Encoding,
+	followLinks bool,
+	searchPaths ...string,
+) *FSLoader {
+	loader, err := NewFSLoaderWithOptions(fsys, encoding, followLinks, searchPaths...)
+	if err != nil {
+		panic(err)
+	}
+	return loader
+}
+
+// NewFSLoaderWithOptions creates a new [FSLoader] with the given options. Mind
+// that the files are searched in the order of the given search paths.
+//
+//   - fsys: The file system containing the templates.
+//   - encoding: The encoding of the template files. If nil, the default
+//     encoding is used.
+//   - followLinks: If true, symlinks are followed.
+//   - searchPaths: The directories to search for templates. The paths are
+//     relative to the root of the file system.
+func NewFSLoaderWithOptions(
+	fsys fs.FS,
+	encoding encoding.Encoding,
+	followLinks bool,
+	searchPaths ...string,
+) (*FSLoader, error) {
+	if fsys == nil {
+		return nil, errors.NewTemplateLoadError("", "no file system given")
+	}
+	if len(searchPaths) == 0 {
+		searchPaths = []string{"."}
+	}
+
+	// clean search paths and remove duplicates
+	cleaned := make([]string, 0, len(searchPaths))
+	seen := map[string]struct{}{}
+	for _, searchPath := range searchPaths {
+		cleanedPath := path.Clean(strings.TrimPrefix(searchPath, "/"))
+		if !fs.ValidPath(cleanedPath) {
+			return nil, errors.NewTemplateLoadError(searchPath, "invalid search path '%s'", searchPath)
+		}
+		if _, ok := seen[cleanedPath]; ok {
+			continue
+		}
+		seen[cleanedPath] = struct{}{}
+		cleaned = append(cleaned, cleanedPath)
+	}
+
+	return &FSLoader{
+		fsys:           fsys,
+		searchPaths:    cleaned,
+		encoding:       encoding,
+		followingLinks: followLinks,
+	}, nil
+}
+
+// Load returns a template by name.
+func (fl *FSLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Template, error) {
+	reader, err := fl.loadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return loadTemplate(name, reader, cfg)
+}
+
+// Version returns the version of a template source. It is derived from the
+// modification time and size of the file or, if the file system provides no
+// modification times (e.g. [embed.FS]), from a hash of the contents.
+func (fl *FSLoader) Version(name string) (string, error) {
+	fullPath, stat, err := fl.findFile(name)
+	if err != nil {
+		return "", err
+	}
+	if !stat.ModTime().IsZero() {
+		return fmt.Sprintf("%d-%d", stat.ModTime().UnixNano(), stat.Size()), nil
+	}
+	source, err := fs.ReadFile(fl.fsys, fullPath)
+	if err != nil {
+		return "", errors.NewTemplateLoadError(name, "failed to read file '%s': %s", name, err)
+	}
+	return hashSource(source), nil
+}
+
+// loadFile goes through the search paths and returns the contents of the first
+// file that is found.
+func (fl *FSLoader) loadFile(name string) (io.ReadCloser, error) {
+	fullPath, _, err := fl.findFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// open file
+	file, err := fl.fsys.Open(fullPath)
+	if err != nil {
+		return nil, errors.NewTemplateLoadError(name, "failed to open file '%s': %s", name, err)
+	}
+	return decodeFile(file, fl.encoding), nil
+}
+
+// findFile goes through the search paths and returns the path and file info of
+// the first file that is found.
+func (fl *FSLoader) findFile(name string) (string, fs.FileInfo, error) {
+	// clean path to prevent directory traversal
+	cleanedPath := cleanTemplatePath(name)
+
+	for _, searchPath := range fl.searchPaths {
+		fullPath := path.Join(searchPath, cleanedPath)
+		// check if path exists and is a file
+		stat, err := fs.Stat(fl.fsys, fullPath)
+		if err != nil || !stat.Mode().IsRegular() {
+			continue
+		}
+		// check if path is a symlink
+		if !fl.followingLinks && fl.isSymlink(fullPath) {
+			continue
+		}
+		return fullPath, stat, nil
+	}
+
+	return "", nil, errors.NewTemplateNotFoundError(name)
+}
+
+// isSymlink reports whether the file at the given path is a symlink. Since
+// fs.Stat follows symlinks, the type is looked up in the parent directory.
+func (fl *FSLoader) isSymlink(name string) bool {
+	dir, file := path.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := fs.ReadDir(fl.fsys, path.Clean(dir))
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.Name() == file {
+			return entry.Type()&fs.ModeSymlink != 0
+		}
+	}
+	return false
+}
@@ -1,7 +1,16 @@
 package loaders
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
 	"github.com/aisbergg/gonja/pkg/gonja/exec"
+	"golang.org/x/text/encoding"
 )
 
 // Loader is an interface for loading templates by name.
@@ -9,3 +18,64 @@ type Loader interface {
 	// Load returns a template by name.
 	Load(name string, cfg *exec.EvalConfig) (*exec.Template, error)
 }
+
+// VersionedLoader is a loader that can tell the current version of a template
+// source. It allows caches to detect changed templates.
+type VersionedLoader interface {
+	Loader
+
+	// Version returns the current version of a template source, e.g. derived
+	// from the modification time or a hash of the contents. The version
+	// changes, whenever the source changes.
+	Version(name string) (string, error)
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// cleanTemplatePath cleans a template name to a path relative to the root,
+// which can't escape the root (e.g. using `../`).
+func cleanTemplatePath(name string) string {
+	return strings.TrimPrefix(path.Join("/", name), "/")
+}
+
+// decodeFile wraps a file in a reader, which decodes the contents using the
+// given encoding.
+func decodeFile(file io.ReadCloser, enc encoding.Encoding) io.ReadCloser {
+	reader := bufio.NewReader(file)
+	if enc != nil {
+		// use configured encoding
+		decoder := enc.NewDecoder()
+		reader = bufio.NewReader(decoder.Reader(reader))
+	}
+
+	// return a ReadCloser that wraps the reader and the file
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}
+}
+
+// loadTemplate reads the template source from the reader and parses it.
+func loadTemplate(name string, reader io.Reader, cfg *exec.EvalConfig) (*exec.Template, error) {
+	buf, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.NewTemplateLoadError(name, "error loading template: %s", err)
+	}
+
+	// parse template
+	tpl, err := exec.NewTemplate(name, string(buf), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return tpl, nil
+}
+
+// hashSource returns a hash of a template source, which can be used as
+// version.
+func hashSource(source []byte) string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
@@ -8,7 +8,8 @@ import (
 )
 
 // MergedLoader represents a merged loader for templates. It wraps multiple
-// loaders and returns the first found template.
+// loaders and returns the first found template. Any error of a loader is
+// ignored, including syntax errors; use a [ChoiceLoader] to get those reported.
 type MergedLoader struct {
 	loaders []Loader
 }
@@ -34,3 +35,22 @@ func (fs *MergedLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Template,
 
 	return nil, errors.NewTemplateNotFoundError(name)
 }
+
+// Version returns the version of a template source reported by the first
+// loader that knows the template. Loaders that don't provide versions are
+// skipped.
+func (fs *MergedLoader) Version(name string) (string, error) {
+	name = filepath.Clean(name)
+
+	for _, loader := range fs.loaders {
+		versioned, ok := loader.(VersionedLoader)
+		if !ok {
+			continue
+		}
+		if version, err := versioned.Version(name); err == nil {
+			return version, nil
+		}
+	}
+
+	return "", errors.NewTemplateNotFoundError(name)
+}
@@ -0,0 +1,98 @@
+package loaders
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aisbergg/gonja/pkg/gonja/errors"
+	"github.com/aisbergg/gonja/pkg/gonja/exec"
+)
+
+// PrefixLoader represents a loader that delegates to other loaders based on
+// the prefix of the template name. The prefix is removed from the name before
+// it is passed to the loader. If multiple prefixes match, the longest one is
+// used.
+//
+// Example:
+//
+//	loader := loaders.NewPrefixLoader(map[string]loaders.Loader{
+//		"admin/": loaders.MustNewFileSystemLoader("admin/templates"),
+//		"mail/":  loaders.MustNewFileSystemLoader("mail/templates"),
+//	})
+//	// "mail/welcome.html" is loaded as "welcome.html" from "mail/templates"
+type PrefixLoader struct {
+	prefixes []prefixEntry
+}
+
+// prefixEntry maps a prefix to a loader.
+type prefixEntry struct {
+	prefix string
+	loader Loader
+}
+
+// NewPrefixLoader creates a new [PrefixLoader] with the given mapping of
+// prefixes to loaders.
+func NewPrefixLoader(mapping map[string]Loader) *PrefixLoader {
+	prefixes := make([]prefixEntry, 0, len(mapping))
+	for prefix, loader := range mapping {
+		prefixes = append(prefixes, prefixEntry{prefix: prefix, loader: loader})
+	}
+	// longest prefixes first
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i].prefix) != len(prefixes[j].prefix) {
+			return len(prefixes[i].prefix) > len(prefixes[j].prefix)
+		}
+		return prefixes[i].prefix < prefixes[j].prefix
+	})
+	return &PrefixLoader{
+		prefixes: prefixes,
+	}
+}
+
+// Load returns a template by name.
+func (pl *PrefixLoader) Load(name string, cfg *exec.EvalConfig) (*exec.Template, error) {
+	entry, localName, ok := pl.lookup(name)
+	if !ok {
+		return nil, errors.NewTemplateNotFoundError(name)
+	}
+
+	// the template is loaded by its local name, but named by the full name, so
+	// that names referenced by it are joined and errors are reported with the
+	// prefix
+	cfg = cfg.Inherit()
+	cfg.NamePrefix += entry.prefix
+
+	tpl, err := entry.loader.Load(localName, cfg)
+	if err != nil {
+		if _, ok := err.(errors.TemplateNotFoundError); ok {
+			return nil, errors.NewTemplateNotFoundError(name)
+		}
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// Version returns the version of a template source as reported by the loader
+// of the matching prefix.
+func (pl *PrefixLoader) Version(name string) (string, error) {
+	entry, localName, ok := pl.lookup(name)
+	if !ok {
+		return "", errors.NewTemplateNotFoundError(name)
+	}
+	versioned, ok := entry.loader.(VersionedLoader)
+	if !ok {
+		return "", errors.NewTemplateLoadError(name, "loader for prefix '%s' does not provide versions", entry.prefix)
+	}
+	return versioned.Version(localName)
+}
+
+// lookup returns the entry matching the name and the name without the prefix.
+func (pl *PrefixLoader) lookup(name string) (prefixEntry, string, bool) {
+	name = cleanTemplatePath(name)
+	for _, entry := range pl.prefixes {
+		if strings.HasPrefix(name, entry.prefix) {
+			return entry, strings.TrimPrefix(name, entry.prefix), true
+		}
+	}
+	return prefixEntry{}, "", false
+}
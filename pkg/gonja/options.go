@@ -29,6 +29,30 @@ func OptLoader(loader loaders.Loader) Option {
 	}
 }
 
+// OptAutoReload caches the loaded templates and reloads them, whenever their
+// source changes. Templates depending on a changed template (e.g. using
+// `extends` or `include`) are reloaded as well. This requires a loader that
+// provides source versions (see [loaders.VersionedLoader]), otherwise the
+// templates are cached forever.
+func OptAutoReload() Option {
+	return func(cfg *Environment) {
+		cfg.autoReload = true
+	}
+}
+
+// OptJoinPath sets the function that joins the name of a referenced template
+// (e.g. in `include`, `import` or `extends`) with the name of the referencing
+// template. Use [exec.JoinPathRelative] to reference templates relative to the
+// current one:
+//
+//	env := gonja.NewEnvironment(gonja.OptJoinPath(exec.JoinPathRelative))
+//	// in "mail/welcome.html": {% include "./footer.html" %} -> "mail/footer.html"
+func OptJoinPath(fn exec.JoinPathFn) Option {
+	return func(cfg *Environment) {
+		cfg.JoinPath = fn
+	}
+}
+
 // -----------------------------------------------------------------------------
 //
 // Parser Options
@@ -171,6 +195,95 @@ func OptNoAutoescape() Option {
 	}
 }
 
+// OptContextualAutoescape enables the autoescaping feature with escapers chosen
+// by the HTML context of the values, e.g. JavaScript escaping within `<script>`
+// or URL filtering within `href` attributes. Values can be marked as safe for a
+// context using the filters `safe_js`, `safe_css`, `safe_url` and `safe_attr`.
+func OptContextualAutoescape() Option {
+	return func(cfg *Environment) {
+		cfg.Autoescape = true
+		cfg.ContextualAutoescape = true
+	}
+}
+
+// OptEscaper sets the escaper used by the autoescaping feature, e.g.
+// [exec.EscapeShell]. It enables autoescaping as well.
+func OptEscaper(escaper exec.EscapeFn) Option {
+	return func(cfg *Environment) {
+		cfg.Autoescape = true
+		cfg.Escaper = escaper
+	}
+}
+
+// OptAutoescapeFn sets a function selecting the autoescaping of each template
+// by its name, e.g. [exec.SelectAutoescape].
+//
+// Example:
+//
+//	gonja.OptAutoescapeFn(exec.SelectAutoescape(".html", ".htm", ".xml"))
+func OptAutoescapeFn(fn exec.AutoescapeFn) Option {
+	return func(cfg *Environment) {
+		cfg.AutoescapeFn = fn
+	}
+}
+
+// OptMaxLoopIterations limits the total number of loop iterations during a
+// single render. Zero (default) means no limit.
+func OptMaxLoopIterations(n int) Option {
+	return func(cfg *Environment) {
+		cfg.MaxLoopIterations = n
+	}
+}
+
+// OptMaxMacroDepth limits the depth of nested macro calls. Zero (default)
+// means no limit.
+func OptMaxMacroDepth(n int) Option {
+	return func(cfg *Environment) {
+		cfg.MaxMacroDepth = n
+	}
+}
+
+// OptMaxIncludeDepth limits the depth of nested includes. Zero (default) means
+// no limit.
+func OptMaxIncludeDepth(n int) Option {
+	return func(cfg *Environment) {
+		cfg.MaxIncludeDepth = n
+	}
+}
+
+// OptMaxOutputSize limits the size of the rendered output in bytes. Buffers of
+// macros and the like are limited to the same size each. Zero (default) means
+// no limit.
+func OptMaxOutputSize(n int) Option {
+	return func(cfg *Environment) {
+		cfg.MaxOutputSize = n
+	}
+}
+
+// OptSandbox enables the sandboxed mode, in which the access to Go values is
+// restricted by the given policy and the size of ranges and repeated strings is
+// limited. If policy is nil, the [exec.DefaultSandboxPolicy] is used, which
+// denies calling methods of Go values.
+func OptSandbox(policy exec.SandboxPolicy) Option {
+	return func(cfg *Environment) {
+		cfg.Sandbox = exec.NewSandbox(policy)
+	}
+}
+
+// OptSandboxLimits sets the maximum number of items produced by `range()` and
+// the maximum length of strings created by repetition or by string methods such
+// as `center` and `replace` in sandboxed mode. Zero
+// means no limit. It has no effect unless the sandbox is enabled with
+// [OptSandbox].
+func OptSandboxLimits(maxRange, maxRepeatLength int) Option {
+	return func(cfg *Environment) {
+		if cfg.Sandbox != nil {
+			cfg.Sandbox.MaxRange = maxRange
+			cfg.Sandbox.MaxRepeatLength = maxRepeatLength
+		}
+	}
+}
+
 // OptUndefined sets the behavior for undefined variables.
 func OptUndefined(undefined exec.UndefinedFunc) Option {
 	return func(cfg *Environment) {
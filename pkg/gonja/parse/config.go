@@ -48,5 +48,7 @@ func (cfg Config) Inherit() *Config {
 		VariableEndString:   cfg.VariableEndString,
 		CommentStartString:  cfg.CommentStartString,
 		CommentEndString:    cfg.CommentEndString,
+		LineStatementPrefix: cfg.LineStatementPrefix,
+		LineCommentPrefix:   cfg.LineCommentPrefix,
 	}
 }
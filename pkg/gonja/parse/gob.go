@@ -0,0 +1,34 @@
+package parse
+
+import "encoding/gob"
+
+// The node types are registered with encoding/gob, so parsed templates can be
+// serialized (e.g. into template bundles). Nodes defined outside of this
+// package, like statements, must be registered by their packages.
+func init() {
+	gob.Register(&TemplateNode{})
+	gob.Register(&DataNode{})
+	gob.Register(&CommentNode{})
+	gob.Register(&OutputNode{})
+	gob.Register(&FilteredExpression{})
+	gob.Register(&TestExpression{})
+	gob.Register(&StringNode{})
+	gob.Register(&IntegerNode{})
+	gob.Register(&FloatNode{})
+	gob.Register(&BoolNode{})
+	gob.Register(&NameNode{})
+	gob.Register(&ListNode{})
+	gob.Register(&TupleNode{})
+	gob.Register(&DictNode{})
+	gob.Register(&PairNode{})
+	gob.Register(&CallNode{})
+	gob.Register(&GetItemNode{})
+	gob.Register(&NegationNode{})
+	gob.Register(&UnaryExpressionNode{})
+	gob.Register(&BinaryExpressionNode{})
+	gob.Register(&BinOperatorNode{})
+	gob.Register(&InlineIfExpressionNode{})
+	gob.Register(&StatementBlockNode{})
+	gob.Register(&WrapperNode{})
+	gob.Register(&MacroNode{})
+}
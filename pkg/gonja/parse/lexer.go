@@ -23,6 +23,7 @@ type lexFn func() lexFn
 
 // Lexer holds the state of the scanner.
 type Lexer struct {
+	Name  string // the name of the template being scanned.
 	Input string // the string being scanned.
 	Start int    // start position of this item.
 	Pos   int    // current position in the input.
@@ -35,6 +36,7 @@ type Lexer struct {
 	delimiters    []rune
 	RawStatements rawStmt
 	rawEnd        *regexp.Regexp
+	lineStmt      bool // whether a line statement is being scanned
 }
 
 // TODO: set from env
@@ -47,15 +49,33 @@ func NewLexer(input string, cfg *Config) *Lexer {
 		Tokens: make(chan *Token),
 		Config: cfg,
 		RawStatements: rawStmt{
-			"raw":     regexp.MustCompile(fmt.Sprintf(`%s\s*endraw`, cfg.BlockStartString)),
-			"comment": regexp.MustCompile(fmt.Sprintf(`%s\s*endcomment`, cfg.BlockStartString)),
+			"raw":     rawEndRegexp(cfg, "endraw"),
+			"comment": rawEndRegexp(cfg, "endcomment"),
 		},
 	}
 }
 
+// rawEndRegexp returns a regular expression matching the closing statement of
+// a raw statement. If line statements are enabled, the closing statement may
+// also be given as a line statement, which is captured by the first group.
+func rawEndRegexp(cfg *Config, name string) *regexp.Regexp {
+	expr := fmt.Sprintf(`%s\s*%s`, cfg.BlockStartString, name)
+	if cfg.LineStatementPrefix != "" {
+		expr = fmt.Sprintf(`(?m)%s|^[ \t]*(%s)\s*%s`, expr, regexp.QuoteMeta(cfg.LineStatementPrefix), name)
+	}
+	return regexp.MustCompile(expr)
+}
+
 // Lex lexes the input and returns a stream of tokens.
 func Lex(input string, cfg *Config) *Stream {
+	return LexTemplate("", input, cfg)
+}
+
+// LexTemplate lexes the input of the named template and returns a stream of
+// tokens. The tokens carry the name of the template for error reporting.
+func LexTemplate(name, input string, cfg *Config) *Stream {
 	l := NewLexer(input, cfg)
+	l.Name = name
 	go l.Run()
 	return NewStream(l.Tokens)
 }
@@ -65,9 +85,10 @@ func Lex(input string, cfg *Config) *Stream {
 // state, terminating Lexer.Run.
 func (l *Lexer) errorf(format string, args ...any) lexFn {
 	l.Tokens <- &Token{
-		Type: TokenError,
-		Val:  fmt.Sprintf(format, args...),
-		Pos:  l.Pos,
+		Type:     TokenError,
+		Val:      fmt.Sprintf(format, args...),
+		Pos:      l.Pos,
+		Template: l.Name,
 	}
 	return nil
 }
@@ -122,11 +143,12 @@ func (l *Lexer) processAndEmit(t TokenType, fn func(string) string) {
 		val = fn(val)
 	}
 	l.Tokens <- &Token{
-		Type: t,
-		Val:  val,
-		Pos:  l.Start,
-		Line: line,
-		Col:  col,
+		Type:     t,
+		Val:      val,
+		Pos:      l.Start,
+		Line:     line,
+		Col:      col,
+		Template: l.Name,
 	}
 	l.Start = l.Pos
 }
@@ -224,6 +246,28 @@ func (l *Lexer) lexData() lexFn {
 			return l.lexBlock
 		}
 
+		if n := l.lineStatementIndent(); n >= 0 {
+			// whitespace in front of a line statement is removed
+			if l.Pos > l.Start {
+				l.emit(TokenData)
+			}
+			l.Pos += n
+			l.ignore()
+			return l.lexLineStatement
+		}
+
+		if l.Config.LineCommentPrefix != "" && l.hasPrefix(l.Config.LineCommentPrefix) {
+			// whitespace in front of a line comment is removed
+			pos := l.Pos
+			l.Pos = l.Start + len(strings.TrimRight(l.Current(), " \t"))
+			if l.Pos > l.Start {
+				l.emit(TokenData)
+			}
+			l.Pos = pos
+			l.ignore()
+			return l.lexLineComment
+		}
+
 		if l.next() == rEOF {
 			break
 		}
@@ -241,15 +285,44 @@ func (l *Lexer) remaining() string {
 	return l.Input[l.Pos:]
 }
 
+// lineStatementIndent returns the length of the indentation in front of a line
+// statement, if the current position is at the start of a line and the line
+// contains a line statement. Otherwise -1 is returned.
+func (l *Lexer) lineStatementIndent() int {
+	prefix := l.Config.LineStatementPrefix
+	if prefix == "" || (l.Pos > 0 && l.Input[l.Pos-1] != '\n') {
+		return -1
+	}
+	rem := l.remaining()
+	line := strings.TrimLeft(rem, " \t")
+	if !strings.HasPrefix(line, prefix) {
+		return -1
+	}
+	// the longer prefix wins if line comments and statements start alike
+	comment := l.Config.LineCommentPrefix
+	if len(comment) > len(prefix) && strings.HasPrefix(line, comment) {
+		return -1
+	}
+	return len(rem) - len(line)
+}
+
 // lexRaw scans a raw statement.
 func (l *Lexer) lexRaw() lexFn {
-	loc := l.rawEnd.FindStringIndex(l.remaining())
+	loc := l.rawEnd.FindStringSubmatchIndex(l.remaining())
 	if loc == nil {
 		return l.errorf("unable to find raw closing statement")
 	}
+	l.rawEnd = nil
+	if len(loc) > 2 && loc[2] >= 0 {
+		// closed by a line statement, drop its indentation
+		l.Pos += loc[0]
+		l.emit(TokenData)
+		l.Pos += loc[2] - loc[0]
+		l.ignore()
+		return l.lexLineStatement
+	}
 	l.Pos += loc[0]
 	l.emit(TokenData)
-	l.rawEnd = nil
 	return l.lexBlock
 }
 
@@ -268,6 +341,21 @@ func (l *Lexer) lexComment() lexFn {
 	return l.lexData
 }
 
+// lexLineComment scans a line comment. The comment ends with the line, but the
+// line break itself is kept.
+func (l *Lexer) lexLineComment() lexFn {
+	l.Pos += len(l.Config.LineCommentPrefix)
+	l.emit(TokenLinecommentBegin)
+	i := strings.IndexAny(l.remaining(), "\r\n")
+	if i < 0 {
+		i = len(l.remaining())
+	}
+	l.Pos += i
+	l.emit(TokenData)
+	l.emit(TokenLinecommentEnd)
+	return l.lexData
+}
+
 // lexVariable scans a variable.
 func (l *Lexer) lexVariable() lexFn {
 	l.Pos += len(l.Config.VariableStartString)
@@ -315,10 +403,48 @@ func (l *Lexer) lexBlockEnd() lexFn {
 	return l.lexData
 }
 
+// lexLineStatement lexes a line statement.
+func (l *Lexer) lexLineStatement() lexFn {
+	l.Pos += len(l.Config.LineStatementPrefix)
+	l.emit(TokenLinestatementBegin)
+	for isSpace(l.peek()) {
+		l.next()
+	}
+	if len(l.Current()) > 0 {
+		l.emit(TokenWhitespace)
+	}
+	stmt := l.nextIdentifier()
+	l.emit(TokenName)
+	re, exists := l.RawStatements[stmt]
+	if exists {
+		l.rawEnd = re
+	}
+	l.lineStmt = true
+	return l.lexExpression
+}
+
+// lexLineStatementEnd scans the end of a line statement, which includes the
+// line break.
+func (l *Lexer) lexLineStatementEnd() lexFn {
+	l.accept("\r")
+	l.accept("\n")
+	l.emit(TokenLinestatementEnd)
+	l.lineStmt = false
+	if l.rawEnd != nil {
+		return l.lexRaw
+	}
+	return l.lexData
+}
+
 // lexExpression scans the next token of the input.
 func (l *Lexer) lexExpression() lexFn {
 	for {
-		if !l.expectDelimiter(l.peek()) {
+		if l.lineStmt {
+			// line statements may span multiple lines inside of delimiters
+			if r := l.peek(); len(l.delimiters) == 0 && (r == rEOF || isEndOfLine(r)) {
+				return l.lexLineStatementEnd
+			}
+		} else if !l.expectDelimiter(l.peek()) {
 			if l.hasPrefix(l.Config.VariableEndString) { // && l.expectDelimiter(l.peek()) {
 				return l.lexVariableEnd
 			}
@@ -340,7 +466,7 @@ func (l *Lexer) lexExpression() lexFn {
 		r := l.next()
 		// remaining := l.Input[l.Pos:]
 		switch {
-		case isSpace(r):
+		case isSpace(r), isEndOfLine(r):
 			return l.lexSpace
 		case isNumeric(r):
 			return l.lexNumber
@@ -363,7 +489,9 @@ func (l *Lexer) lexExpression() lexFn {
 		case '+':
 			l.emit(TokenAdd)
 		case '-':
-			if l.hasPrefix(l.Config.BlockEndString) {
+			if l.lineStmt {
+				l.emit(TokenSub)
+			} else if l.hasPrefix(l.Config.BlockEndString) {
 				l.backup()
 				return l.lexBlockEnd
 			} else if l.hasPrefix(l.Config.VariableEndString) {
@@ -326,6 +326,83 @@ func TestStreamSlice(t *testing.T) {
 	}
 }
 
+var (
+	lineBegin        = tok{parse.TokenLinestatementBegin, "#"}
+	lineEnd          = tok{parse.TokenLinestatementEnd, "\n"}
+	lineCommentBegin = tok{parse.TokenLinecommentBegin, "##"}
+	lineCommentEnd   = tok{parse.TokenLinecommentEnd, ""}
+)
+
+var lineLexerCases = []struct {
+	name     string
+	input    string
+	expected []tok
+}{
+	{"line statement", "# if true\nyes\n# endif", []tok{
+		lineBegin, space, name("if"), space, name("true"), lineEnd,
+		data("yes\n"),
+		lineBegin, space, name("endif"), {parse.TokenLinestatementEnd, ""},
+		EOF,
+	}},
+	{"indented line statement", "a\n  # for x in y:\n", []tok{
+		data("a\n"),
+		lineBegin, space, name("for"), space, name("x"), space, name("in"), space, name("y"),
+		{parse.TokenColon, ":"}, lineEnd,
+		EOF,
+	}},
+	{"prefix not at line start", "a # b", []tok{
+		data("a # b"),
+		EOF,
+	}},
+	{"multiline line statement", "# set x = [1,\n2]\n", []tok{
+		lineBegin, space, name("set"), space, name("x"), space,
+		{parse.TokenAssign, "="}, space,
+		lBracket, {parse.TokenInteger, "1"}, {parse.TokenComma, ","},
+		{parse.TokenWhitespace, "\n"}, {parse.TokenInteger, "2"}, rBracket, lineEnd,
+		EOF,
+	}},
+	{"line comment", "## comment\na  ## comment\n", []tok{
+		lineCommentBegin, data(" comment"), lineCommentEnd,
+		data("\na"),
+		lineCommentBegin, data(" comment"), lineCommentEnd,
+		data("\n"),
+		EOF,
+	}},
+	{"raw line statement", "# raw\n# if\n# endraw\n", []tok{
+		lineBegin, space, name("raw"), lineEnd,
+		data("# if\n"),
+		lineBegin, space, name("endraw"), lineEnd,
+		EOF,
+	}},
+	{"raw block", "{% raw %}# if\n{% endraw %}", []tok{
+		blockBegin, space, name("raw"), space, blockEnd,
+		data("# if\n"),
+		blockBegin, space, name("endraw"), space, blockEnd,
+		EOF,
+	}},
+}
+
+func TestLineLexer(t *testing.T) {
+	cfg := parse.NewConfig()
+	cfg.LineStatementPrefix = "#"
+	cfg.LineCommentPrefix = "##"
+	for _, lc := range lineLexerCases {
+		test := lc
+		t.Run(test.name, func(t *testing.T) {
+			lexer := parse.NewLexer(test.input, cfg)
+			go lexer.Run()
+			toks := tokenSlice(lexer.Tokens)
+
+			assert := testutils.NewAssert(t)
+			actual := []tok{}
+			for _, token := range toks {
+				actual = append(actual, tok{token.Type, token.Val})
+			}
+			assert.Equal(test.expected, actual)
+		})
+	}
+}
+
 const positionsCase = `Hello
 {#
     Multiline comment
@@ -340,11 +417,11 @@ func TestLexerPosition(t *testing.T) {
 	go lexer.Run()
 	toks := tokenSlice(lexer.Tokens)
 	assert.Equal([]*parse.Token{
-		{parse.TokenData, "Hello\n", 0, 1, 1},
-		{parse.TokenCommentBegin, "{#", 6, 2, 1},
-		{parse.TokenData, "\n    Multiline comment\n", 8, 2, 3},
-		{parse.TokenCommentEnd, "#}", 31, 4, 1},
-		{parse.TokenData, "\nWorld\n", 33, 4, 3},
-		{parse.TokenEOF, "", 40, 6, 1},
+		{parse.TokenData, "Hello\n", 0, 1, 1, ""},
+		{parse.TokenCommentBegin, "{#", 6, 2, 1, ""},
+		{parse.TokenData, "\n    Multiline comment\n", 8, 2, 3, ""},
+		{parse.TokenCommentEnd, "#}", 31, 4, 1, ""},
+		{parse.TokenData, "\nWorld\n", 33, 4, 3, ""},
+		{parse.TokenEOF, "", 40, 6, 1, ""},
 	}, toks)
 }
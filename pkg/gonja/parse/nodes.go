@@ -310,12 +310,18 @@ func (c *CallNode) String() string {
 // -----------------------------------------------------------------------------
 
 // GetItemNode represents a node for looking up items from a list or dictionary
-// `{{ obj[key] }}`.
+// `{{ obj.attr }}`, `{{ obj[key] }}` or slicing a list or string
+// `{{ obj[start:stop:step] }}`.
 type GetItemNode struct {
 	Location *Token
 	Node     Node
-	Arg      string
-	Index    int
+	Arg      string     // attribute name of `obj.attr`
+	Index    int        // index of `obj.0`
+	Key      Expression // subscript of `obj[key]`
+	IsSlice  bool       // whether this is a slice `obj[start:stop:step]`
+	Start    Expression // optional start of the slice
+	Stop     Expression // optional stop of the slice
+	Step     Expression // optional step of the slice
 }
 
 // Position returns the start token of the Node.
@@ -324,9 +330,14 @@ func (g *GetItemNode) Position() *Token { return g.Location }
 func (g *GetItemNode) String() string {
 	t := g.Position()
 	var param string
-	if g.Arg != "" {
+	switch {
+	case g.IsSlice:
+		param = fmt.Sprintf("Start=%s Stop=%s Step=%s", g.Start, g.Stop, g.Step)
+	case g.Key != nil:
+		param = fmt.Sprintf("Key=%s", g.Key)
+	case g.Arg != "":
 		param = fmt.Sprintf("Arg=%s", g.Arg)
-	} else {
+	default:
 		param = fmt.Sprintf("Index=%s", strconv.Itoa(g.Index))
 	}
 	return fmt.Sprintf("GetItem(Node=%s %s Line=%d Col=%d)", g.Node, param, t.Line, t.Col)
@@ -514,6 +525,9 @@ type MacroNode struct {
 	Args     []string
 	Kwargs   []*PairNode
 	Wrapper  *WrapperNode
+	// Caller is true, if the macro body references the special `caller`
+	// variable and therefore can be used in a call block.
+	Caller bool
 }
 
 // Position returns the start token of the Node.
@@ -16,6 +16,17 @@ type Parser struct {
 	Statements      map[string]StatementParser
 	Level           int8
 	TemplateParseFn TemplateParseFn
+
+	// LoopLevel is the number of loops enclosing the current position. It is
+	// used to reject loop controls (e.g. `break`) outside of loops.
+	LoopLevel int8
+
+	// syntaxErrors are the syntax errors the parser has recovered from
+	syntaxErrors []errors.TemplateSyntaxError
+	// failedBlocks are the names of the statements that failed to parse before
+	// their body; their end and intermediate tags are skipped to avoid follow-up
+	// errors
+	failedBlocks []string
 }
 
 // NewParser creates a new parser for the given token stream.
@@ -126,7 +137,7 @@ func (p *Parser) WrapUntil(names ...string) (*WrapperNode, *Parser) {
 
 	for !p.Stream.End() {
 		// New tag, check whether we have to stop wrapping here
-		if begin := p.Match(TokenBlockBegin); begin != nil {
+		if begin := p.Match(TokenBlockBegin, TokenLinestatementBegin); begin != nil {
 			ident := p.Peek(TokenName)
 
 			if ident != nil {
@@ -144,16 +155,15 @@ func (p *Parser) WrapUntil(names ...string) (*WrapperNode, *Parser) {
 				if found {
 					// Okay, endtag found.
 					p.Consume() // '{%' tagname
-					wrapper.Trim.Left = begin.Val[len(begin.Val)-1] == '-'
-					wrapper.LStrip = begin.Val[len(begin.Val)-1] == '+'
+					wrapper.Trim.Left = beginModifier(begin) == '-'
+					wrapper.LStrip = beginModifier(begin) == '+'
 
 					for {
-						if end := p.Match(TokenBlockEnd); end != nil {
+						if end := p.Match(TokenBlockEnd, TokenLinestatementEnd); end != nil {
 							// Okay, end the wrapping here
 							wrapper.EndTag = ident.Val
-							wrapper.Trim.Right = end.Val[0] == '-'
-							stream := NewStream(args)
-							return wrapper, NewParser(p.Config, stream)
+							wrapper.Trim.Right = endModifier(end) == '-'
+							return wrapper, NewParser(p.Config, newArgsStream(begin, end, args))
 						}
 						t := p.Next()
 						// p.Consume()
@@ -168,8 +178,9 @@ func (p *Parser) WrapUntil(names ...string) (*WrapperNode, *Parser) {
 		}
 
 		// Otherwise process next element to be wrapped
-		node := p.parseDocElement()
-		wrapper.Nodes = append(wrapper.Nodes, node)
+		if node := p.parseDocElement(); node != nil {
+			wrapper.Nodes = append(wrapper.Nodes, node)
+		}
 	}
 
 	errors.ThrowSyntaxError(p.Current().ErrorToken(), "unexpected EOF, expected any of '%s'", strings.Join(names, " or "))
@@ -181,7 +192,7 @@ func (p *Parser) WrapUntil(names ...string) (*WrapperNode, *Parser) {
 func (p *Parser) SkipUntil(names ...string) {
 	for !p.End() {
 		// New tag, check whether we have to stop wrapping here
-		if p.Match(TokenBlockBegin) != nil {
+		if p.Match(TokenBlockBegin, TokenLinestatementBegin) != nil {
 			ident := p.Peek(TokenName)
 
 			if ident != nil {
@@ -201,7 +212,7 @@ func (p *Parser) SkipUntil(names ...string) {
 					p.Consume() // '{%' tagname
 
 					for {
-						if p.Match(TokenBlockEnd) != nil {
+						if p.Match(TokenBlockEnd, TokenLinestatementEnd) != nil {
 							// Done skipping, exit.
 							return
 						}
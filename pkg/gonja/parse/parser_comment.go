@@ -13,7 +13,7 @@ func (p *Parser) ParseComment() *CommentNode {
 	}
 	debug.Print("parse: %s", p.Current())
 
-	tok := p.Match(TokenCommentBegin)
+	tok := p.Match(TokenCommentBegin, TokenLinecommentBegin)
 	if tok == nil {
 		errors.ThrowSyntaxError(p.Current().ErrorToken(), "unexpected '%s' , expected '%s'", p.Current(), p.Config.CommentStartString)
 	}
@@ -30,7 +30,7 @@ func (p *Parser) ParseComment() *CommentNode {
 		comment.Text = tok.Val
 	}
 
-	tok = p.Match(TokenCommentEnd)
+	tok = p.Match(TokenCommentEnd, TokenLinecommentEnd)
 	if tok == nil {
 		errors.ThrowSyntaxError(p.Current().ErrorToken(), "unexpected '%s' , expected '%s'", p.Current(), p.Config.CommentEndString)
 	}
@@ -7,6 +7,26 @@ import (
 
 type StatementParser func(parser, args *Parser) Statement
 
+// StatementParserWithError is a statement parser that returns an error instead
+// of throwing it. Use [ParserWithError] to register it.
+type StatementParserWithError func(parser, args *Parser) (Statement, error)
+
+// ParserWithError converts a [StatementParserWithError] into a
+// [StatementParser]. Returned errors are reported as syntax errors with the
+// position of the arguments parser.
+func ParserWithError(fn StatementParserWithError) StatementParser {
+	return func(parser, args *Parser) Statement {
+		stmt, err := fn(parser, args)
+		if err != nil {
+			if _, ok := err.(errors.TemplateError); ok {
+				panic(err)
+			}
+			panic(errors.WrapSyntaxError(err, args.Current().ErrorToken(), "%s", err))
+		}
+		return stmt
+	}
+}
+
 // Tag = "{%" IDENT ARGS "%}"
 func (p *Parser) ParseStatement() Statement {
 	if debug.Enabled {
@@ -15,7 +35,7 @@ func (p *Parser) ParseStatement() Statement {
 	}
 	debug.Print("parse: %s", p.Current())
 
-	if p.Match(TokenBlockBegin) == nil {
+	if p.Match(TokenBlockBegin, TokenLinestatementBegin) == nil {
 		errors.ThrowSyntaxError(p.Current().ErrorToken(), "unexpected '%s' , expected '{%%'", p.Current().Val)
 	}
 
@@ -37,7 +57,7 @@ func (p *Parser) ParseStatement() Statement {
 	// }
 
 	var args []*Token
-	for !p.Stream.End() && p.Peek(TokenBlockEnd) == nil {
+	for !p.Stream.End() && p.Peek(TokenBlockEnd, TokenLinestatementEnd) == nil {
 		// Add token to args
 		args = append(args, p.Next())
 		// p.Consume() // next token
@@ -48,7 +68,7 @@ func (p *Parser) ParseStatement() Statement {
 	// 	return nil, p.Error("Unexpectedly reached EOF, no statement end found.", p.lastToken)
 	// }
 
-	if p.Match(TokenBlockEnd) == nil {
+	if p.Match(TokenBlockEnd, TokenLinestatementEnd) == nil {
 		errors.ThrowSyntaxError(p.Current().ErrorToken(), "expected end of block '%s'", p.Config.BlockEndString)
 	}
 
@@ -73,7 +93,7 @@ func (p *Parser) ParseStatementBlock() *StatementBlockNode {
 	}
 	debug.Print("parse: %s", p.Current())
 
-	begin := p.Match(TokenBlockBegin)
+	begin := p.Match(TokenBlockBegin, TokenLinestatementBegin)
 	if begin == nil {
 		errors.ThrowSyntaxError(p.Current().ErrorToken(), "unexpected '%s', expected '%s'", p.Current(), p.Config.BlockStartString)
 	}
@@ -86,6 +106,12 @@ func (p *Parser) ParseStatementBlock() *StatementBlockNode {
 	// Check for the existing statement
 	stmtParser, exists := p.Statements[name.Val]
 	if !exists {
+		if p.skipFailedBlockTag(name.Val) {
+			for !p.Stream.End() && p.Match(TokenBlockEnd, TokenLinestatementEnd) == nil {
+				p.Consume()
+			}
+			return nil
+		}
 		// Does not exists
 		errors.ThrowSyntaxError(name.ErrorToken(), "statement '%s' not found (or beginning not provided)", name.Val)
 	}
@@ -97,7 +123,7 @@ func (p *Parser) ParseStatementBlock() *StatementBlockNode {
 
 	debug.Print("find args token")
 	var args []*Token
-	for !p.Stream.End() && p.Peek(TokenBlockEnd) == nil {
+	for !p.Stream.End() && p.Peek(TokenBlockEnd, TokenLinestatementEnd) == nil {
 		args = append(args, p.Next())
 	}
 
@@ -106,12 +132,12 @@ func (p *Parser) ParseStatementBlock() *StatementBlockNode {
 	// 	return nil, p.Error("Unexpectedly reached EOF, no statement end found.", p.lastToken)
 	// }
 
-	end := p.Match(TokenBlockEnd)
+	end := p.Match(TokenBlockEnd, TokenLinestatementEnd)
 	if end == nil {
 		errors.ThrowSyntaxError(p.Current().ErrorToken(), "expected end of block '%s'", p.Config.BlockEndString)
 	}
 
-	stream := NewStream(args)
+	stream := newArgsStream(begin, end, args)
 	debug.Print("argparser")
 	argParser := NewParser(p.Config, stream)
 	// argParser := newParser(p.name, argsToken, p.template)
@@ -120,18 +146,68 @@ func (p *Parser) ParseStatementBlock() *StatementBlockNode {
 	// 	argParser.lastToken = tokenName
 	// }
 
-	// p.template.level++
-	// defer func() { p.template.level-- }()
+	p.Level++
+	defer func() { p.Level-- }()
 	stmt := stmtParser(p, argParser)
 	debug.Print("parsed expression: %s", stmt)
 	return &StatementBlockNode{
 		Location: begin,
 		Name:     name.Val,
 		Stmt:     stmt,
-		LStrip:   begin.Val[len(begin.Val)-1] == '+',
+		LStrip:   beginModifier(begin) == '+',
 		Trim: &Trim{
-			Left:  begin.Val[len(begin.Val)-1] == '-',
-			Right: end.Val[0] == '-',
+			Left:  beginModifier(begin) == '-',
+			Right: endModifier(end) == '-',
 		},
 	}
 }
+
+// beginModifier returns the whitespace control modifier ('-' or '+') of a
+// block begin token or 0 if there is none. Line statements have no modifiers.
+func beginModifier(t *Token) byte {
+	if t.Type != TokenBlockBegin {
+		return 0
+	}
+	return t.Val[len(t.Val)-1]
+}
+
+// endModifier returns the whitespace control modifier ('-') of a block end
+// token or 0 if there is none. Line statements have no modifiers.
+func endModifier(t *Token) byte {
+	if t.Type != TokenBlockEnd {
+		return 0
+	}
+	return t.Val[0]
+}
+
+// newArgsStream creates the stream of the arguments of a tag. The stream ends
+// with an EOF token at the end of the tag, so that errors about missing
+// arguments point into the tag.
+func newArgsStream(begin, end *Token, args []*Token) *Stream {
+	args = trimLineStatementColon(begin, args)
+	return NewStream(append(args, &Token{
+		Type:     TokenEOF,
+		Pos:      end.Pos,
+		Line:     end.Line,
+		Col:      end.Col,
+		Template: end.Template,
+	}))
+}
+
+// trimLineStatementColon removes an optional trailing colon from the arguments
+// of a line statement (e.g. `# for item in seq:`).
+func trimLineStatementColon(begin *Token, args []*Token) []*Token {
+	if begin.Type != TokenLinestatementBegin {
+		return args
+	}
+	for i := len(args) - 1; i >= 0; i-- {
+		switch args[i].Type {
+		case TokenWhitespace:
+			continue
+		case TokenColon:
+			return append(args[:i], args[i+1:]...)
+		}
+		break
+	}
+	return args
+}
@@ -27,28 +27,99 @@ func (p *Parser) parseDocElement() Node {
 	case TokenEOF:
 		p.Consume()
 		return nil
-	case TokenCommentBegin:
+	case TokenCommentBegin, TokenLinecommentBegin:
 		return p.ParseComment()
 	case TokenVariableBegin:
-		return p.ParseExpressionNode()
-	case TokenBlockBegin:
-		return p.ParseStatementBlock()
+		return p.recoverElement(func() Node {
+			return p.ParseExpressionNode()
+		}, TokenVariableEnd)
+	case TokenBlockBegin, TokenLinestatementBegin:
+		return p.recoverElement(func() Node {
+			if block := p.ParseStatementBlock(); block != nil {
+				return block
+			}
+			return nil
+		}, TokenBlockEnd, TokenLinestatementEnd)
 	}
 	errors.ThrowSyntaxError(p.Current().ErrorToken(), "unexpected token (only HTML/tags/filters in templates allowed)")
 	return nil
 }
 
-// ParseTemplate parses a template and returns the root node of the AST.
+// recoverElement parses an element of the document (an expression or a
+// statement). A syntax error is recorded and the rest of the element's tag is
+// skipped up to one of the given end tokens, so that parsing continues with
+// the next element.
+func (p *Parser) recoverElement(parse func() Node, ends ...TokenType) (node Node) {
+	consumed := len(p.Stream.tokens)
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+		serr, ok := rec.(errors.TemplateSyntaxError)
+		if !ok {
+			panic(rec)
+		}
+		p.syntaxErrors = append(p.syntaxErrors, serr)
+		node = nil
+
+		// skip the rest of the tag, unless the tag has been parsed completely
+		isEnd := func(tok *Token) bool {
+			for _, typ := range ends {
+				if tok.Type == typ {
+					return true
+				}
+			}
+			return false
+		}
+		tokens := p.Stream.tokens[consumed:]
+		for _, tok := range tokens {
+			if isEnd(tok) {
+				// the tag has been parsed, but not its body
+				if len(tokens) > 1 && tokens[1].Type == TokenName && p.Stream.previous == tok {
+					p.failedBlocks = append(p.failedBlocks, tokens[1].Val)
+				}
+				return
+			}
+		}
+		for !p.Stream.End() && p.Match(ends...) == nil {
+			p.Consume()
+		}
+	}()
+	return parse()
+}
+
+// skipFailedBlockTag reports whether name is an unknown tag of a statement that
+// failed to parse, i.e. its end tag or an intermediate tag such as 'else'. The
+// tag is skipped in that case.
+func (p *Parser) skipFailedBlockTag(name string) bool {
+	for i := len(p.failedBlocks) - 1; i >= 0; i-- {
+		if "end"+p.failedBlocks[i] == name {
+			p.failedBlocks = append(p.failedBlocks[:i], p.failedBlocks[i+1:]...)
+			return true
+		}
+	}
+	return len(p.failedBlocks) > 0
+}
+
+// ParseTemplate parses a template and returns the root node of the AST. The
+// parser recovers from syntax errors at the boundaries of expressions and
+// statements. If there are multiple errors, a [errors.TemplateSyntaxErrors]
+// is returned.
 func (p *Parser) ParseTemplate() (tpl *TemplateNode, err error) {
 	// catch all syntax errors and rethrow others
 	defer func() {
 		if r := recover(); r != nil {
 			if rerr, ok := r.(errors.TemplateSyntaxError); ok {
-				err = rerr
+				p.syntaxErrors = append(p.syntaxErrors, rerr)
 			} else {
 				panic(r)
 			}
 		}
+		if len(p.syntaxErrors) > 0 {
+			tpl = nil
+			err = errors.NewTemplateSyntaxErrors(p.syntaxErrors)
+		}
 	}()
 
 	if debug.Enabled {
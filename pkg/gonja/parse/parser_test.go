@@ -322,6 +322,30 @@ var testCases = []struct {
 			"Arg": val{"attr"},
 		}},
 	}}},
+	{"variable subscript", "{{ a_var[key] }}", specs{parse.OutputNode{}, attrs{
+		"Expression": specs{parse.GetItemNode{}, attrs{
+			"Node": specs{parse.NameNode{}, attrs{
+				"Name": _token("a_var"),
+			}},
+			"Key": specs{parse.NameNode{}, attrs{
+				"Name": _token("key"),
+			}},
+		}},
+	}}},
+	{"variable slice", "{{ a_var[1:-1:2] }}", specs{parse.OutputNode{}, attrs{
+		"Expression": specs{parse.GetItemNode{}, attrs{
+			"Node": specs{parse.NameNode{}, attrs{
+				"Name": _token("a_var"),
+			}},
+			"IsSlice": val{true},
+			"Start":   _literal(parse.IntegerNode{}, int64(1)),
+			"Stop": specs{parse.UnaryExpressionNode{}, attrs{
+				"Negative": val{true},
+				"Term":     _literal(parse.IntegerNode{}, int64(1)),
+			}},
+			"Step": _literal(parse.IntegerNode{}, int64(2)),
+		}},
+	}}},
 	{"variable and filter", "{{ a_var|safe }}", specs{parse.OutputNode{}, attrs{
 		"Expression": specs{parse.FilteredExpression{}, attrs{
 			"Expression": specs{parse.NameNode{}, attrs{
@@ -431,6 +455,21 @@ var testCases = []struct {
 			"Args": slice{_literal(parse.IntegerNode{}, int64(42))},
 		}},
 	}}},
+	{"literal method", "{{ ', '.join(items) }}", specs{parse.OutputNode{}, attrs{
+		"Expression": specs{parse.CallNode{}, attrs{
+			"Func": specs{parse.GetItemNode{}, attrs{
+				"Node": _literal(parse.StringNode{}, ", "),
+				"Arg":  val{"join"},
+			}},
+			"Args": slice{specs{parse.NameNode{}, attrs{"Name": _token("items")}}},
+		}},
+	}}},
+	{"literal subscript", "{{ [1, 2][0] }}", specs{parse.OutputNode{}, attrs{
+		"Expression": specs{parse.GetItemNode{}, attrs{
+			"Node": specs{parse.ListNode{}, attrs{}},
+			"Key":  _literal(parse.IntegerNode{}, int64(0)),
+		}},
+	}}},
 	{"function with filtered args", "{{ a_func(42|safe) }}", specs{parse.OutputNode{}, attrs{
 		"Expression": specs{parse.CallNode{}, attrs{
 			"Func": specs{parse.NameNode{}, attrs{"Name": _token("a_func")}},
@@ -238,8 +238,12 @@ func (p *Parser) ParseVariable() Expression {
 		return br
 	}
 
-	var variable Node = &NameNode{t}
+	return p.parsePostfix(&NameNode{t})
+}
 
+// parsePostfix parses the attribute accesses, subscripts and calls following
+// a variable or literal, e.g. `user.name`, `"a,b".split(",")` or `items[0]`.
+func (p *Parser) parsePostfix(variable Node) Expression {
 	for !p.Stream.EOF() {
 		if dot := p.Match(TokenDot); dot != nil {
 			getitem := &GetItemNode{
@@ -266,30 +270,7 @@ func (p *Parser) ParseVariable() Expression {
 			continue
 
 		} else if bracket := p.Match(TokenLbracket); bracket != nil {
-			getitem := &GetItemNode{
-				Location: bracket,
-				Node:     variable,
-			}
-			tok := p.Match(TokenString, TokenInteger)
-			if tok == nil {
-				errors.ThrowSyntaxError(p.Current().ErrorToken(), "expected a string or an integer")
-			}
-			switch tok.Type {
-			case TokenString:
-				getitem.Arg = tok.Val
-			case TokenInteger:
-				i, err := strconv.Atoi(tok.Val)
-				if err != nil {
-					errors.ThrowSyntaxError(p.Current().ErrorToken(), err.Error())
-				}
-				getitem.Index = i
-			default:
-				panic(fmt.Errorf("[BUG] token '%s' not allowed here", p.Current()))
-			}
-			variable = getitem
-			if p.Match(TokenRbracket) == nil {
-				errors.ThrowSyntaxError(p.Current().ErrorToken(), "unbalanced bracket '[]'")
-			}
+			variable = p.parseSubscript(bracket, variable)
 			continue
 
 		} else if lparen := p.Match(TokenLparen); lparen != nil {
@@ -326,6 +307,47 @@ func (p *Parser) ParseVariable() Expression {
 	return variable
 }
 
+// parseSubscript parses a subscript `[key]` or a slice `[start:stop:step]`
+// following the given node. The opening bracket is already consumed.
+func (p *Parser) parseSubscript(bracket *Token, node Node) *GetItemNode {
+	if debug.Enabled {
+		fm := debug.FuncMarker()
+		defer fm.End()
+	}
+	debug.Print("parse: %s", p.Current())
+
+	getitem := &GetItemNode{
+		Location: bracket,
+		Node:     node,
+	}
+
+	var expr Expression
+	if p.Peek(TokenColon) == nil {
+		expr = p.ParseExpression()
+	}
+
+	if p.Match(TokenColon) == nil {
+		if expr == nil {
+			errors.ThrowSyntaxError(p.Current().ErrorToken(), "expected a subscript")
+		}
+		getitem.Key = expr
+	} else {
+		getitem.IsSlice = true
+		getitem.Start = expr
+		if p.Peek(TokenColon, TokenRbracket) == nil {
+			getitem.Stop = p.ParseExpression()
+		}
+		if p.Match(TokenColon) != nil && p.Peek(TokenRbracket) == nil {
+			getitem.Step = p.ParseExpression()
+		}
+	}
+
+	if p.Match(TokenRbracket) == nil {
+		errors.ThrowSyntaxError(p.Current().ErrorToken(), "unbalanced bracket '[]'")
+	}
+	return getitem
+}
+
 // ParseVariableOrLiteral parses a variable or a literal.
 func (p *Parser) ParseVariableOrLiteral() Expression {
 	if debug.Enabled {
@@ -345,10 +367,10 @@ func (p *Parser) ParseVariableOrLiteral() Expression {
 		return p.parseNumber()
 
 	case TokenString:
-		return p.parseString()
+		return p.parsePostfix(p.parseString())
 
 	case TokenLparen, TokenLbrace, TokenLbracket:
-		return p.parseCollection()
+		return p.parsePostfix(p.parseCollection())
 
 	case TokenName:
 		return p.ParseVariable()
@@ -105,6 +105,7 @@ func (s *Stream) nonIgnored() *Token {
 // consume consumes the current token and returns it.
 func (s *Stream) consume() *Token {
 	s.previous = s.current
+	s.tokens = append(s.tokens, s.current)
 	s.current = s.next
 	if s.backup != nil {
 		s.next = s.backup
@@ -156,4 +157,17 @@ func (s *Stream) Backup() {
 	s.next = s.current
 	s.current = s.previous
 	s.previous = nil
+	s.tokens = s.tokens[:len(s.tokens)-1]
+}
+
+// Consumed returns the number of tokens consumed so far. It can be used
+// together with ConsumedSince to inspect the tokens consumed in between.
+func (s *Stream) Consumed() int {
+	return len(s.tokens)
+}
+
+// ConsumedSince returns the tokens consumed since the given number of consumed
+// tokens (see Consumed).
+func (s *Stream) ConsumedSince(n int) []*Token {
+	return s.tokens[n:]
 }
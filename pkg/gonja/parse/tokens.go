@@ -130,6 +130,8 @@ type Token struct {
 	Pos  int
 	Line int
 	Col  int
+	// Template is the name of the template the token belongs to.
+	Template string
 }
 
 func (t Token) String() string {
@@ -143,9 +145,10 @@ func (t Token) String() string {
 // ErrorToken converts the Token into an [errors.Token].
 func (t Token) ErrorToken() *errors.Token {
 	return &errors.Token{
-		Val:  t.Val,
-		Pos:  t.Pos,
-		Line: t.Line,
-		Col:  t.Col,
+		Template: t.Template,
+		Val:      t.Val,
+		Pos:      t.Pos,
+		Line:     t.Line,
+		Col:      t.Col,
 	}
 }
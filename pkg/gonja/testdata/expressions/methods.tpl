@@ -0,0 +1,34 @@
+{{ "hello world".upper() }} {{ "Hello World".lower() }} {{ "hello wORLD".capitalize() }} {{ "hello wORLD".title() }} {{ "Hello".swapcase() }}
+{{ "/usr/bin".startswith("/") }} {{ "/usr/bin".startswith(("x", "/u")) }} {{ "file.txt".endswith(".md") }} {{ "abcabc".startswith("c", 2) }}
+{{ "a,b,,c".split(",") }} {{ "  a  b c ".split() }} {{ "a b c".split(None, 1) }} {{ "a,b,c".rsplit(",", 1) }} {{ " a b  c ".rsplit(maxsplit=1) }}
+{{ "one\ntwo\r\nthree".splitlines() }} {{ "k=v=w".partition("=") }} {{ "k=v=w".rpartition("=") }} {{ "kv".partition("=") }}
+{{ "banana".find("an") }} {{ "banana".rfind("an") }} {{ "banana".find("x") }} {{ "banana".index("na", 3) }} {{ "banana".count("a") }} {{ "héllo".find("l") }}
+[{{ "  pad  ".strip() }}] [{{ "xxpadxx".lstrip("x") }}] [{{ "xxpadxx".rstrip("x") }}] [{{ "ab".center(6, "*") }}] [{{ "ab".ljust(4) }}] [{{ "ab".rjust(4, "0") }}] [{{ "-42".zfill(5) }}]
+{{ "-".join(["a", "b", "c"]) }} {{ "aaa".replace("a", "b", 2) }} {{ "v1.2".removeprefix("v") }} {{ "main.go".removesuffix(".go") }}
+{{ "{} {}".format(1, 2) }} {{ "{1}-{0}".format("a", "b") }} {{ "{name}: {n:>6.2f}".format(name="pi", n=3.14159) }} {{ "{0[1]}{0[0]}".format(["a", "b"]) }} {{ "{:,}".format(1234567) }} [{{ "{:+08.3f}".format(-3.5) }}] [{{ "{:#x} {:08b} {:^7} {:*<4}".format(255, 5, "mid", 1) }}] {{ "{!r} {{}}".format("q")|safe }} [{{ "{:{w}}".format("ab", w=4) }}] {{ "{:.1%} {:.2e} {:g}".format(0.256, 12345.678, 0.5) }} [{{ "{:05}|{:<05}".format(-1, "ab") }}]
+[{{ "a\tbc\td".expandtabs() }}] [{{ "ab\tc\n\tx".expandtabs(4) }}] {{ "Straße".casefold() }}
+{{ "abc1".isalnum() }} {{ "abc".isalpha() }} {{ "123".isdigit() }} {{ "".isdigit() }} {{ "abc1".islower() }} {{ "ABC".isupper() }} {{ " \t".isspace() }}
+{{ ("<b>"|safe).join(["<i>", "x"|safe]) }}
+{{- "x".nomethod is defined }}
+{%- set list = [3, 1, 2] %}
+{%- do list.insert(0, 4) %}
+{%- do list.remove(1) %}
+{{ list }} {{ list.index(2) }} {{ list.count(3) }} {{ list.copy() }}
+{%- do list.sort() %}
+{{ list }}
+{%- do list.sort(reverse=true) %}
+{{ list }}
+{%- do list.reverse() %}
+{{ list }}
+{%- do list.clear() %}
+{{ list }}
+{%- set sorted = simple.unsorted_int_list.copy() %}
+{%- do sorted.sort() %}
+{{ sorted }} {{ simple.unsorted_int_list[0] }} {{ simple.multiple_item_list.index(2) }}
+{%- set d = {'b': 1, 'a': 2} %}
+{{ d.keys() }} {{ d.values() }} {{ d.items() }} {{ d.get('a') }} {{ d.get('x', 0) }} {{ d.get('x') }}
+{%- for k, v in d.items() %} {{ k }}={{ v }}{% endfor %}
+{%- set c = d.copy() %}
+{%- do d.clear() %}
+{{ d }} {{ c }}
+{{ simple.strmap.keys() }} {{ simple.strmap.get('gh') }} {{ simple.strmap.items()|first }}
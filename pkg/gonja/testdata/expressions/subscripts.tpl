@@ -0,0 +1,20 @@
+{% set key = "str" %}{% set i = 2 %}
+{{ simple[key] }}
+{{ simple["s" ~ "tr"] }}
+{{ simple.multiple_item_list[i + 1] }}
+{{ simple.multiple_item_list[-2] }}
+{{ simple.intmap[i] }}
+{{ simple.strmap[simple.strmap.abc[:0] ~ "gh"] }}
+{{ simple.str[0] }}{{ simple.chinese_hello_world[-1] }}
+{{ simple.multiple_item_list[1:3] }}
+{{ simple.multiple_item_list[-2:] }}
+{{ simple.multiple_item_list[:2] }}
+{{ simple.multiple_item_list[::3] }}
+{{ simple.multiple_item_list[::-1] }}
+{{ simple.multiple_item_list[7:2:-2] }}
+{{ simple.multiple_item_list[none:none] }}
+{{ simple.multiple_item_list[100:] }}
+{{ simple.str[::-1] }}
+{{ simple.str[1:-1] }}
+{{ simple.chinese_hello_world[::-1] }}
+{{ simple.str[-100:100:2] }}
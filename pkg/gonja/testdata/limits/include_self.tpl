@@ -0,0 +1 @@
+{% set name = "include_self.tpl" %}{% include name %}
\ No newline at end of file
@@ -0,0 +1,17 @@
+## line comments are removed, the line break is kept
+<ul>
+# for item in [1, 2, 3]:
+    # if item is odd
+    <li>{{ item }}</li>  ## trailing comment
+    # else
+    <li>even</li>
+    # endif
+# endfor
+</ul>
+# set items = [
+    "a",
+    "b",
+]
+{{ items|join(",") }}
+a # is not a line statement
+{# block comments ## still work #}{% if true %}blocks still work{% endif %}
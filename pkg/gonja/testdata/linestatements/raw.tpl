@@ -0,0 +1,5 @@
+# raw
+# for x in xs
+{{ x }} ## not a comment
+# endraw
+{% raw %}# if true{% endraw %}
@@ -0,0 +1,12 @@
+{% macro dialog(title) -%}
+<div class="dialog"><h2>{{ title }}</h2>{{ caller() }}</div>
+{%- endmacro -%}
+{% macro list_users(users) -%}
+<ul>{% for user in users %}<li>{{ caller(user, loop.index) }}</li>{% endfor %}</ul>
+{%- endmacro -%}
+{% macro plain() %}plain{% endmacro -%}
+{% call dialog('Hello') %}Body of {{ simple.name }}{% endcall %}
+{% call(user, index) list_users(['alice', 'bob']) %}{{ index }}: {{ user|upper }}{% endcall %}
+{% call(greeting='Hi') dialog('Defaults') %}{{ greeting }}{% endcall %}
+{% call dialog('Outer') %}{% call dialog('Inner') %}nested{% endcall %}{% endcall %}
+{{ dialog.name }} {{ dialog.caller }} {{ plain.caller }} {{ list_users.arguments }}
@@ -0,0 +1,19 @@
+{%- set list = [1, 2] %}
+{%- do list.append(3) %}
+{%- do list.extend([4, 5]) %}
+{%- set alias = list %}
+{%- do alias.append(6) %}
+list: {{ list }} popped: {{ list.pop() }} {{ list.pop(0) }} {{ list }}
+{%- set nested = {'items': []} %}
+{%- for i in range(3) %}{% do nested['items'].append(i * 10) %}{% endfor %}
+nested: {{ nested['items'] }}
+{%- set d = {'a': 1} %}
+{%- do d.update({'b': 2}, c=3) %}
+dict: {{ d }}
+setdefault: {{ d.setdefault('a', 10) }} {{ d.setdefault('z', 26) }} {{ d['z'] }}
+pop: {{ d.pop('a') }} {{ d.pop('missing', 'default') }} {{ d }}
+{%- set ns = namespace(count=0) %}
+{%- for i in range(4) %}{% do ns.update({'count': ns.count + i}) %}{% endfor %}
+namespace: {{ ns.count }}
+{%- set keys = {'pop': 'item'} %}
+item before method: {{ keys.pop }}
@@ -0,0 +1 @@
+{% if simple.bool_false %}{% extends "inheritance/base.tpl" %}{% endif %}Own {% block content %}content{% endblock %}
@@ -0,0 +1,4 @@
+{%- set layouts = ["inheritance/missing.tpl", "inheritance/base.tpl"] -%}
+{%- if simple.bool_true %}{% extends layouts %}{% endif %}
+This is discarded.
+{% block content %}Dynamic content{% endblock %}
@@ -0,0 +1,29 @@
+{%- set tree = [
+    {'name': 'a', 'children': [
+        {'name': 'a1', 'children': []},
+        {'name': 'a2', 'children': [{'name': 'a2x', 'children': []}]},
+    ]},
+    {'name': 'b', 'children': []},
+] -%}
+Tree
+{%- for item in tree recursive %}
+{{ loop.depth }}/{{ loop.depth0 }} {{ item.name }} {{ loop.index }}/{{ loop.length }} {{ loop.cycle('odd', 'even') }}
+{%- if item.children %}{{ loop(item.children) }}{% endif %}
+{%- endfor %}
+
+Prev/Next items
+{%- for item in tree recursive %}
+{{ item.name }}: prev: {{ loop.previtem.name if not loop.first else none }} next: {{ loop.nextitem.name if not loop.last else none }}
+{%- if item.children %}{{ loop(item.children) }}{% endif %}
+{%- endfor %}
+
+Changed
+{%- for item in [[1, 1, 2], [3, 3]] recursive %}
+{%- if item is iterable %}{{ loop(item) }}{% else %} {{ item }}:{{ loop.changed(item) }}{% endif %}
+{%- endfor %}
+
+Filtered
+{%- for item in tree if item.name != 'a1' recursive %}
+{{ item.name }}
+{%- if item.children %}{{ loop(item.children) }}{% endif %}
+{%- endfor %}
@@ -0,0 +1,7 @@
+{% with number=3, what_am_i="first" -%}
+Start '{% include ["includes.helper.not_exists", "includes.helper", "with.helper"] %}' End
+{%- endwith %}
+Start '{% include ["includes.helper.not_exists", "includes.helper.not_exists2"] ignore missing %}' End
+{% import "macro.not_exists" ignore missing as m -%}
+{% from "macro.not_exists" ignore missing import input -%}
+'{{ m.input is defined }}' '{{ input is defined }}'
@@ -0,0 +1,28 @@
+Break
+{%- for i in range(10) %}
+{%- if i == 3 %}{% break %}{% endif %} {{ i }}
+{%- endfor %}
+
+Continue
+{%- for i in range(6) %}
+{%- if i is odd %}{% continue %}{% endif %} {{ i }}
+{%- endfor %}
+
+Nested blocks
+{%- for i in range(5) %}
+{%- with j = i * 2 %}{% filter upper %}{% if j > 4 %}{% break %}{% endif %} x{{ j }}{% endfilter %}{% endwith %}
+{%- endfor %}
+
+Nested loops
+{%- for i in range(3) %}
+{%- for j in range(3) %}{% if j > i %}{% break %}{% endif %} {{ i }}{{ j }}{% endfor %}
+{%- if i == 1 %}{% continue %}{% endif %} |
+{%- endfor %}
+
+Else
+{%- for i in range(3) %}{% break %}{% else %} empty{% endfor %}
+
+Recursive
+{%- for item in [1, [2, 3, 4], 5, 6] recursive %}
+{%- if item is iterable %}{{ loop(item) }}{% elif item == 3 or item == 6 %}{% break %}{% else %} {{ item }}{% endif %}
+{%- endfor %}